@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"storage/configuration"
+	"storage/models"
+	"storage/services/hall"
+	"storage/services/imaging"
+	"storage/services/receipt"
+	"time"
+)
+
+// Cleanup command
+var CleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Delete stored receipts and hall images that nothing refers to anymore",
+	Long: "Delete stored receipts and hall images that nothing refers to anymore.\n\n" +
+		"Archived halls keep their images. With --archived-before, the images of halls archived\n" +
+		"before that date are deleted as well; restoring such a hall brings it back without images.",
+	Run: func(cmd *cobra.Command, args []string) {
+		conf, err := configuration.Init()
+		if err != nil {
+			fmt.Println("Failed to initialize configuration:", err)
+			return
+		}
+		ctx := context.Background()
+
+		// Archived halls keep their images for reports until they are purged explicitly.
+		if cleanupArchivedBefore != "" {
+			before, err := time.Parse("2006-01-02", cleanupArchivedBefore)
+			if err != nil {
+				fmt.Println("Invalid --archived-before date, use YYYY-MM-DD:", err)
+				return
+			}
+			if err := purgeArchivedImages(ctx, conf, before); err != nil {
+				fmt.Println("Failed to purge images of archived halls:", err)
+				return
+			}
+		}
+
+		// Receipts are orphaned once their reservation is gone.
+		var reservationIDs []uint
+		if err := conf.Db.Model(&models.Reservation{}).Pluck("id", &reservationIDs).Error; err != nil {
+			fmt.Println("Failed to retrieve reservations:", err)
+			return
+		}
+		knownReservations := make(map[uint]bool, len(reservationIDs))
+		for _, id := range reservationIDs {
+			knownReservations[id] = true
+		}
+
+		receipts, err := conf.Storage.List(ctx, receipt.ReceiptPrefix)
+		if err != nil {
+			fmt.Println("Failed to list receipts:", err)
+			return
+		}
+		var orphans []string
+		// Files are written before the row that refers to them, so recent files may belong to
+		// an upload or booking that is still in progress.
+		cutoff := time.Now().Add(-cleanupMinAge)
+
+		for _, r := range receipts {
+			if id, ok := receipt.ParseKey(r.Key); ok && !knownReservations[id] && r.ModTime.Before(cutoff) {
+				orphans = append(orphans, r.Key)
+			}
+		}
+
+		// Hall images are orphaned once no HallImage row points at them.
+		var imageNames []string
+		if err := conf.Db.Model(&models.HallImage{}).Pluck("image_name", &imageNames).Error; err != nil {
+			fmt.Println("Failed to retrieve hall images:", err)
+			return
+		}
+		knownImages := make(map[string]bool, len(imageNames))
+		for _, name := range imageNames {
+			for _, variant := range imaging.Variants {
+				knownImages[hall.ImageKey(variant.FileName(name))] = true
+			}
+		}
+
+		images, err := conf.Storage.List(ctx, hall.ImagePrefix)
+		if err != nil {
+			fmt.Println("Failed to list hall images:", err)
+			return
+		}
+		for _, img := range images {
+			if !knownImages[img.Key] && img.ModTime.Before(cutoff) {
+				orphans = append(orphans, img.Key)
+			}
+		}
+
+		// Receipts written before the storage backend existed sit in a local directory. The
+		// stored receipts replaced them, so they are all removed.
+		legacy, err := legacyReceipts()
+		if err != nil {
+			fmt.Println("Failed to list legacy receipts:", err)
+			return
+		}
+		for _, path := range legacy {
+			if cleanupDryRun {
+				fmt.Println("Would delete legacy receipt:", path)
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				fmt.Println("Failed to delete", path+":", err)
+				continue
+			}
+			fmt.Println("Deleted legacy receipt:", path)
+		}
+		if len(legacy) > 0 && !cleanupDryRun {
+			// Only succeeds once the directory is empty, so unrelated files are left alone.
+			os.Remove(legacyReceiptDir)
+		}
+
+		if len(orphans) == 0 {
+			if len(legacy) == 0 {
+				fmt.Println("No orphaned files found")
+			}
+			return
+		}
+
+		for _, key := range orphans {
+			if cleanupDryRun {
+				fmt.Println("Would delete:", key)
+				continue
+			}
+			if err := conf.Storage.Delete(ctx, key); err != nil {
+				fmt.Println("Failed to delete", key+":", err)
+				continue
+			}
+			fmt.Println("Deleted:", key)
+		}
+
+		if cleanupDryRun {
+			fmt.Printf("Found %d orphaned file(s) (dry run)\n", len(orphans))
+		} else {
+			fmt.Printf("Processed %d orphaned file(s)\n", len(orphans))
+		}
+	},
+}
+
+var (
+	cleanupDryRun         bool
+	cleanupArchivedBefore string
+	cleanupMinAge         time.Duration
+)
+
+// purgeArchivedImages deletes the images of halls archived before the given date: their
+// HallImage rows first, so nothing refers to a missing file, then the files of every variant.
+func purgeArchivedImages(ctx context.Context, conf *configuration.Dependencies, before time.Time) error {
+	archived := conf.Db.Model(&models.Hall{}).Select("id").Where("archived_at < ?", before)
+	var images []models.HallImage
+	if err := conf.Db.Where("hall_id IN (?)", archived).Order("hall_id, id").Find(&images).Error; err != nil {
+		return err
+	}
+
+	for _, img := range images {
+		if cleanupDryRun {
+			fmt.Printf("Would delete image %s of archived hall %d\n", img.ImageName, img.HallID)
+			continue
+		}
+		if err := conf.Db.Delete(&img).Error; err != nil {
+			return err
+		}
+		for _, variant := range imaging.Variants {
+			key := hall.ImageKey(variant.FileName(img.ImageName))
+			if err := conf.Storage.Delete(ctx, key); err != nil {
+				fmt.Println("Failed to delete", key+":", err)
+			}
+		}
+		fmt.Printf("Deleted image %s of archived hall %d\n", img.ImageName, img.HallID)
+	}
+	return nil
+}
+
+// legacyReceiptDir is where receipts were written, relative to the working directory,
+// before they were kept in the configured storage.
+const legacyReceiptDir = "receipt"
+
+// legacyReceipts lists the receipt files in legacyReceiptDir.
+func legacyReceipts() ([]string, error) {
+	entries, err := os.ReadDir(legacyReceiptDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		if _, ok := receipt.ParseKey(e.Name()); ok && e.Type().IsRegular() {
+			paths = append(paths, filepath.Join(legacyReceiptDir, e.Name()))
+		}
+	}
+	return paths, nil
+}
+
+func init() {
+	CleanupCmd.Flags().BoolVar(&cleanupDryRun, "dry-run", false, "Only list orphaned files")
+	CleanupCmd.Flags().DurationVar(&cleanupMinAge, "min-age", time.Hour, "Only delete orphaned files last modified longer ago than this")
+	CleanupCmd.Flags().StringVar(&cleanupArchivedBefore, "archived-before", "", "Also delete the images of halls archived before this date (YYYY-MM-DD)")
+	rootCmd.AddCommand(CleanupCmd)
+}
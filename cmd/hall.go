@@ -3,8 +3,11 @@ package cmd
 import (
 	"fmt"
 	"github.com/spf13/cobra"
+	"gorm.io/gorm"
 	"storage/configuration"
 	"storage/models"
+	"storage/services/amenity"
+	hallsvc "storage/services/hall"
 	"time"
 )
 
@@ -33,6 +36,21 @@ var createHallCmd = &cobra.Command{
 			AvailableTo:   parseTime(hallAvailableTo),
 		}
 
+		amenities, err := amenity.Resolve(conf.Db, amenity.ParseCodes(hallAmenities))
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		hall.Amenities = amenities
+
+		if cmd.Flags().Changed("lat") || cmd.Flags().Changed("lng") {
+			hall.Latitude, hall.Longitude = &hallLatitude, &hallLongitude
+		}
+		if err := hallsvc.ValidateCoordinates(hall.Latitude, hall.Longitude); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+
 		// Check if hall already exists (to prevent duplicate primary key errors)
 		var existingHall models.Hall
 		if err := conf.Db.First(&existingHall, hall.ID).Error; err == nil {
@@ -59,7 +77,7 @@ var listHallsCmd = &cobra.Command{
 		}
 
 		var halls []models.Hall
-		if err := conf.Db.Find(&halls).Error; err != nil {
+		if err := conf.Db.Where("archived_at IS NULL").Find(&halls).Error; err != nil {
 			fmt.Println("Failed to retrieve halls:", err)
 			return
 		}
@@ -78,7 +96,7 @@ var listHallsCmd = &cobra.Command{
 
 var deleteHallCmd = &cobra.Command{
 	Use:   "delete",
-	Short: "Delete a hall",
+	Short: "Archive a hall",
 	Run: func(cmd *cobra.Command, args []string) {
 		conf, err := configuration.Init()
 		if err != nil {
@@ -86,10 +104,21 @@ var deleteHallCmd = &cobra.Command{
 			return
 		}
 
-		if err := conf.Db.Delete(&models.Hall{}, hallID).Error; err != nil {
+		var upcoming int64
+		conf.Db.Model(&models.Reservation{}).Scopes(models.NotCancelled).Where("hall_id = ? AND end_date > ?", hallID, time.Now()).Count(&upcoming)
+		if upcoming > 0 {
+			fmt.Printf("Error: Hall has %d upcoming reservations. Use the API to cancel or reassign them first.\n", upcoming)
+			return
+		}
+
+		// Halls are archived rather than removed, so past reservations keep their hall.
+		if err := conf.Db.Model(&models.Hall{}).Where("id = ?", hallID).Updates(map[string]interface{}{
+			"archived_at": time.Now(),
+			"version":     gorm.Expr("version + 1"),
+		}).Error; err != nil {
 			fmt.Println("Failed to delete hall:", err)
 		} else {
-			fmt.Println("Hall deleted successfully")
+			fmt.Println("Hall archived successfully")
 		}
 	},
 }
@@ -99,6 +128,8 @@ var hallID int
 var hallCapacity int
 var hallCost float64
 var hallAvailableFrom, hallAvailableTo string
+var hallAmenities string
+var hallLatitude, hallLongitude float64
 
 func init() {
 	HallCmd.AddCommand(createHallCmd)
@@ -111,6 +142,9 @@ func init() {
 	createHallCmd.Flags().Float64VarP(&hallCost, "cost", "p", 0, "Cost Per Day")
 	createHallCmd.Flags().StringVarP(&hallAvailableFrom, "from", "f", "", "Available From (YYYY-MM-DD)")
 	createHallCmd.Flags().StringVarP(&hallAvailableTo, "to", "t", "", "Available To (YYYY-MM-DD)")
+	createHallCmd.Flags().StringVarP(&hallAmenities, "amenities", "a", "", "Comma-separated amenity codes (e.g. wifi,stage)")
+	createHallCmd.Flags().Float64Var(&hallLatitude, "lat", 0, "Latitude in degrees (set together with --lng)")
+	createHallCmd.Flags().Float64Var(&hallLongitude, "lng", 0, "Longitude in degrees (set together with --lat)")
 	createHallCmd.MarkFlagRequired("capacity")
 	createHallCmd.MarkFlagRequired("cost")
 
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"storage/services/signing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Key management command group
+var KeysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage token signing keys",
+}
+
+// Generate a signing key
+var generateKeyCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a private key for signing access tokens",
+	Long: "Writes a new RS256 or EdDSA private key to <dir>/<kid>.pem and prints the entry to add\n" +
+		"to auth.signing_keys. Schedule a rotation by giving the new key an active_from in the future\n" +
+		"and the old key a retire_at after the last token it signed has expired.",
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := signing.GenerateKey(keyAlgorithm)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+
+		if err := os.MkdirAll(keyDir, 0o700); err != nil {
+			fmt.Println("Failed to create key directory:", err)
+			return
+		}
+		path := filepath.Join(keyDir, keyID+".pem")
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			fmt.Println("Failed to write key:", err)
+			return
+		}
+		defer file.Close()
+		if _, err := file.Write(key); err != nil {
+			fmt.Println("Failed to write key:", err)
+			return
+		}
+
+		fmt.Println("Key written to", path)
+		fmt.Println("Add it to auth.signing_keys in configuration/config.json:")
+		fmt.Printf("  {\"kid\": %q, \"file\": %q, \"active_from\": %q}\n", keyID, path, time.Now().UTC().Format(time.RFC3339))
+	},
+}
+
+// CLI flags
+var keyAlgorithm, keyID, keyDir string
+
+func init() {
+	KeysCmd.AddCommand(generateKeyCmd)
+
+	generateKeyCmd.Flags().StringVarP(&keyAlgorithm, "alg", "a", signing.EdDSA, "Algorithm: RS256 or EdDSA")
+	generateKeyCmd.Flags().StringVarP(&keyID, "kid", "k", "", "Key ID, e.g. 2026-10")
+	generateKeyCmd.Flags().StringVarP(&keyDir, "dir", "d", "keys", "Directory to write the key to")
+	generateKeyCmd.MarkFlagRequired("kid")
+
+	rootCmd.AddCommand(KeysCmd)
+}
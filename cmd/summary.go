@@ -16,7 +16,7 @@ var SummaryCmd = &cobra.Command{
 
 		// Retrieve reservations
 		var reservations []models.Reservation
-		if err := conf.Db.Find(&reservations).Error; err != nil {
+		if err := conf.Db.Scopes(models.NotCancelled).Find(&reservations).Error; err != nil {
 			fmt.Println("Failed to retrieve reservations:", err)
 			return
 		}
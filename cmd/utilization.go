@@ -37,7 +37,7 @@ var UtilizationCmd = &cobra.Command{
 		startDate := time.Now().AddDate(0, 0, -30)
 		endDate := time.Now()
 
-		conf.Db.Where("hall_id = ? AND start_date <= ? AND end_date >= ?", hallID, endDate, startDate).Find(&reservations)
+		conf.Db.Scopes(models.NotCancelled).Where("hall_id = ? AND start_date <= ? AND end_date >= ?", hallID, endDate, startDate).Find(&reservations)
 
 		bookedDays := 0
 		for _, r := range reservations {
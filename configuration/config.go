@@ -2,11 +2,18 @@ package configuration
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"os"
+	"storage/services/blob"
+	"storage/services/lockout"
+	"storage/services/mail"
+	"storage/services/payment/provider"
+	"storage/services/signing"
 	"time"
 )
 
@@ -36,12 +43,121 @@ func Init() (*Dependencies, error) {
 		return nil, err
 	}
 
+	payments, err := newPaymentProvider(&usedConfig.Payments)
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := newStorage(&usedConfig.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	mailer, err := newMailer(&usedConfig.Mail)
+	if err != nil {
+		return nil, err
+	}
+
+	logins, resets, err := newGuards(&usedConfig.Auth.Lockout, db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse to run without valid key material rather than issue tokens nobody can trust.
+	keys, err := signing.Load(usedConfig.Auth.SigningKeys, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("signing keys: %w", err)
+	}
+
 	return &Dependencies{
-		Cfg: &usedConfig,
-		Db:  db,
+		Cfg:      &usedConfig,
+		Db:       db,
+		Payments: payments,
+		Storage:  storage,
+		Keys:     keys,
+		Mailer:   mailer,
+		Logins:   logins,
+		Resets:   resets,
 	}, nil
 }
 
+// resetPolicy throttles password reset requests per address and per client IP. Every
+// request counts, so a few go through at once and later ones have to wait.
+var resetPolicy = lockout.Policy{
+	MaxFailures:       10,
+	LockMinutes:       60,
+	FreeAttempts:      3,
+	IPFreeAttempts:    10,
+	MaxBackoffSeconds: 300,
+}
+
+// newGuards creates the login guard and the password reset guard. They share one store,
+// which the login guard's pruner keeps bounded, so the reset counters are forgotten after
+// the same ResetMinutes.
+func newGuards(cfg *Lockout, db *gorm.DB) (logins, resets *lockout.Guard, err error) {
+	var store lockout.Store
+	switch cfg.Store {
+	case "", "memory":
+		store = lockout.NewMemory()
+	case "db":
+		store = lockout.NewDB(db)
+	default:
+		return nil, nil, fmt.Errorf("unknown lockout store %q", cfg.Store)
+	}
+
+	policy := resetPolicy
+	policy.ResetMinutes = cfg.ResetMinutes
+	return lockout.NewGuard(store, cfg.Policy), lockout.NewGuard(lockout.Prefixed(store, "reset:"), policy), nil
+}
+
+func newMailer(cfg *Mail) (mail.Sender, error) {
+	switch cfg.Driver {
+	case "", "log":
+		return mail.NewLog(), nil
+	case "smtp":
+		return mail.NewSMTP(cfg.SMTP)
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+func newStorage(cfg *Storage) (blob.Store, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return blob.NewLocal(cfg.LocalRoot), nil
+	case "s3":
+		return blob.NewS3(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+// WebhookSecretEnv names the environment variable holding the payment webhook secret.
+const WebhookSecretEnv = "PAYMENTS_WEBHOOK_SECRET"
+
+func newPaymentProvider(cfg *Payments) (provider.Provider, error) {
+	if cfg.Currency == "" {
+		cfg.Currency = "BGN"
+	}
+
+	// The secret is kept out of the committed config; the environment overrides the file.
+	if secret := os.Getenv(WebhookSecretEnv); secret != "" {
+		cfg.WebhookSecret = secret
+	}
+
+	// Webhooks are public and authenticated only by their signature; an empty key would accept forged callbacks.
+	if cfg.WebhookSecret == "" {
+		return nil, errors.New("payments: webhook_secret must be set, e.g. through " + WebhookSecretEnv)
+	}
+
+	switch cfg.Provider {
+	case "", "memory":
+		return provider.NewMemory(cfg.WebhookSecret, cfg.AutoConfirm), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", cfg.Provider)
+	}
+}
+
 func KeepConnectionsAlive(db *gorm.DB, interval time.Duration) {
 	for {
 		db.Exec("SELECT 1")
@@ -73,7 +189,9 @@ func loadCfg() (*MainConfig, error) {
 
 func connectDb(cfg *Database) (*gorm.DB, error) {
 	dsn := cfg.User + "@tcp(" + cfg.Host + ":" + cfg.Port + ")/" + cfg.DatabaseName + "?charset=utf8mb4&parseTime=True&loc=Local"
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	// TranslateError turns unique key violations into gorm.ErrDuplicatedKey, so handlers can
+	// tell a lost race on a unique index from other failures.
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
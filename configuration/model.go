@@ -2,11 +2,24 @@ package configuration
 
 import (
 	"gorm.io/gorm"
+	"storage/services/blob"
+	"storage/services/imaging"
+	"storage/services/lockout"
+	"storage/services/mail"
+	"storage/services/payment/provider"
+	"storage/services/signing"
+	"time"
 )
 
 type Dependencies struct {
-	Cfg *EnvironmentConfig
-	Db  *gorm.DB
+	Cfg      *EnvironmentConfig
+	Db       *gorm.DB
+	Payments provider.Provider
+	Storage  blob.Store
+	Keys     *signing.KeySet
+	Mailer   mail.Sender
+	Logins   *lockout.Guard // Throttles failed logins
+	Resets   *lockout.Guard // Throttles password reset requests
 }
 
 type MainConfig struct {
@@ -15,9 +28,19 @@ type MainConfig struct {
 }
 
 type EnvironmentConfig struct {
-	EnvType  string   `json:"env_type" validate:"required"`
-	Port     string   `json:"port" validate:"required,min=2,max=5,numeric"`
-	Database Database `json:"database" validate:"required"`
+	EnvType   string         `json:"env_type" validate:"required"`
+	Port      string         `json:"port" validate:"required,min=2,max=5,numeric"`
+	Database  Database       `json:"database" validate:"required"`
+	Payments  Payments       `json:"payments"`
+	Invoicing Invoicing      `json:"invoicing"`
+	Receipts  Receipts       `json:"receipts"`
+	Storage   Storage        `json:"storage"`
+	Images    imaging.Limits `json:"images"`
+	Auth      Auth           `json:"auth"`
+	Mail      Mail           `json:"mail"`
+	// Reverse proxies, as addresses or CIDRs, whose X-Forwarded-For header gives the client IP.
+	// With none, the client IP is the address of the connection; login throttling keys on it.
+	TrustedProxies []string `json:"trusted_proxies" validate:"omitempty,dive,ip|cidr"`
 }
 
 type Database struct {
@@ -28,6 +51,112 @@ type Database struct {
 	Host         string `json:"host"`
 }
 
+type Payments struct {
+	Provider      string `json:"provider"` // Only "memory" is built in
+	Currency      string `json:"currency"`
+	WebhookSecret string `json:"webhook_secret"`
+	AutoConfirm   bool   `json:"auto_confirm"`
+}
+
+type Invoicing struct {
+	Company              string  `json:"company"`
+	VATID                string  `json:"vat_id"`
+	Address              string  `json:"address"`
+	VATRate              float64 `json:"vat_rate" validate:"gte=0,lt=1"` // Prices are VAT inclusive
+	FiscalYearStartMonth int     `json:"fiscal_year_start_month" validate:"gte=0,lte=12"`
+}
+
+type Receipts struct {
+	LogoPath    string `json:"logo_path"`    // JPEG, PNG or GIF shown on PDF receipts
+	TemplateDir string `json:"template_dir"` // Holds one folder of templates per locale
+}
+
+type Storage struct {
+	Driver    string        `json:"driver" validate:"omitempty,oneof=local s3"`
+	LocalRoot string        `json:"local_root"`
+	S3        blob.S3Config `json:"s3"`
+}
+
+type Mail struct {
+	Driver string          `json:"driver" validate:"omitempty,oneof=log smtp"` // "log" only writes mail to the application log
+	SMTP   mail.SMTPConfig `json:"smtp"`
+	// Links sent in password reset and address verification emails; %s is replaced by the token.
+	PasswordResetURL     string `json:"password_reset_url"`
+	EmailVerificationURL string `json:"email_verification_url"`
+}
+
+type Auth struct {
+	Issuer                 string `json:"issuer"`                                    // iss of issued tokens; defaults to DefaultIssuer
+	Audience               string `json:"audience"`                                  // aud of issued tokens; defaults to DefaultAudience
+	AccessTokenMinutes     int    `json:"access_token_minutes" validate:"gte=0"`     // Defaults to 60
+	RefreshTokenDays       int    `json:"refresh_token_days" validate:"gte=0"`       // Defaults to 30; a session ends this long after its last refresh
+	PasswordResetMinutes   int    `json:"password_reset_minutes" validate:"gte=0"`   // Defaults to 30
+	EmailVerificationHours int    `json:"email_verification_hours" validate:"gte=0"` // Defaults to 48
+	// Private keys for signing access tokens (RS256 or EdDSA). The newest active key signs;
+	// all unretired keys verify and are published at /.well-known/jwks.json.
+	SigningKeys []signing.KeyConfig `json:"signing_keys"`
+	Lockout     Lockout             `json:"lockout"`
+}
+
+type Lockout struct {
+	Store string `json:"store" validate:"omitempty,oneof=memory db"` // "db" shares counters between instances
+	lockout.Policy
+}
+
+// Defaults for the iss and aud claims of access tokens.
+const (
+	DefaultIssuer   = "hall-reservations"
+	DefaultAudience = "hall-reservations-api"
+)
+
+// TokenIssuer returns the configured iss claim.
+func (a *Auth) TokenIssuer() string {
+	if a.Issuer == "" {
+		return DefaultIssuer
+	}
+	return a.Issuer
+}
+
+// TokenAudience returns the configured aud claim.
+func (a *Auth) TokenAudience() string {
+	if a.Audience == "" {
+		return DefaultAudience
+	}
+	return a.Audience
+}
+
+// AccessTokenTTL is how long an access token is valid.
+func (a *Auth) AccessTokenTTL() time.Duration {
+	if a.AccessTokenMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(a.AccessTokenMinutes) * time.Minute
+}
+
+// RefreshTokenTTL is how long a refresh token, and the session it belongs to, stays valid without use.
+func (a *Auth) RefreshTokenTTL() time.Duration {
+	if a.RefreshTokenDays <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return time.Duration(a.RefreshTokenDays) * 24 * time.Hour
+}
+
+// PasswordResetTTL is how long a password reset token can be used.
+func (a *Auth) PasswordResetTTL() time.Duration {
+	if a.PasswordResetMinutes <= 0 {
+		return 30 * time.Minute
+	}
+	return time.Duration(a.PasswordResetMinutes) * time.Minute
+}
+
+// EmailVerificationTTL is how long an address verification link can be used.
+func (a *Auth) EmailVerificationTTL() time.Duration {
+	if a.EmailVerificationHours <= 0 {
+		return 48 * time.Hour
+	}
+	return time.Duration(a.EmailVerificationHours) * time.Hour
+}
+
 //type DB struct {
 //	Server          string        `json:"server" validate:"required,hostname|ip4_addr"`
 //	Port            string        `json:"port" validate:"required,min=2,max=5,numeric"`
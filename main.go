@@ -9,6 +9,14 @@ import (
 	"os/signal"
 	"storage/configuration"
 	"storage/models"
+	"storage/services/account"
+	"storage/services/amenity"
+	"storage/services/audit"
+	"storage/services/lockout"
+	"storage/services/login"
+	"storage/services/mail"
+	"storage/services/session"
+	"storage/services/twofactor"
 	"storage/services/user"
 	"syscall"
 	"time"
@@ -40,7 +48,23 @@ func main() {
 
 	go configuration.KeepConnectionsAlive(d.Db, time.Minute*5)
 
-	d.Db.AutoMigrate(user.User{}, user.UserRoles{}, user.Role{}, models.Hall{}, models.HallImage{}, models.Reservation{})
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	d.Db.AutoMigrate(user.User{}, user.UserRoles{}, user.Role{}, models.Hall{}, models.HallImage{}, models.Reservation{}, models.Payment{}, models.Invoice{}, models.InvoiceLine{}, models.InvoiceSequence{},
+		models.Amenity{}, models.HallAmenity{}, models.Site{}, models.Building{}, models.Floor{},
+		models.OpeningHours{}, models.ScheduleException{}, models.Notification{},
+		models.Review{}, session.Session{}, session.RefreshToken{},
+		account.PasswordResetToken{}, mail.OutboxMessage{}, lockout.Counter{}, audit.AuthEvent{},
+		twofactor.Credential{}, twofactor.RecoveryCode{}, login.LoginChallenge{},
+		audit.LoginRecord{}, account.EmailVerificationToken{})
+
+	if err := amenity.SeedDefaults(d.Db); err != nil {
+		log.Printf("Failed to seed amenities: %v", err)
+	}
+
+	go mail.RunOutbox(backgroundCtx, d.Db, d.Mailer, 30*time.Second)
+	go d.Logins.RunPruner(backgroundCtx, time.Hour)
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
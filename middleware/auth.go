@@ -2,16 +2,18 @@ package middleware
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"net/http"
-	"os"
 	"storage/configuration"
+	"storage/services/auth"
+	"storage/services/login"
+	"storage/services/session"
+	"storage/services/twofactor"
 	"storage/services/user"
 	"strings"
+	"time"
 )
 
 func AuthMiddleware(conf *configuration.Dependencies) gin.HandlerFunc {
-	jwtKey := []byte(os.Getenv("JWT_SECRET_KEY"))
 	return func(c *gin.Context) {
 		// Get the token from the "Authorization" header
 		tokenString := c.GetHeader("Authorization")
@@ -30,33 +32,44 @@ func AuthMiddleware(conf *configuration.Dependencies) gin.HandlerFunc {
 			return
 		}
 
-		// Parse the JWT token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Ensure the signing method is HMAC
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return jwtKey, nil
-		})
-
-		if err != nil || !token.Valid {
+		// Verify the signature and the claims: algorithm, issuer, audience, expiry, subject.
+		claims, err := login.ParseToken(&conf.Cfg.Auth, conf.Keys, tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		// Extract claims from the token
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		// The jti names the session; tokens of revoked or expired sessions are refused.
+		sess, err := session.Find(conf.Db, claims.ID)
+		if err != nil || !sess.Active(time.Now()) || sess.UserID != claims.UserID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
 			c.Abort()
 			return
 		}
-		username := claims["username"]
-		var dbUser user.User
 
-		if err := conf.Db.Preload("Roles").Where("lower(username) = ?", username).First(&dbUser).Error; err != nil {
+		var dbUser user.User
+		if err := conf.Db.Preload("Roles").Where("id = ?", claims.UserID).First(&dbUser).Error; err != nil ||
+			!strings.EqualFold(dbUser.Username, claims.Username) || !dbUser.IsActive {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			c.Abort()
+			return
+		}
+
+		// Members of roles requiring 2FA can do nothing but set it up until they have.
+		if twofactor.Required(dbUser.Roles) && !twoFactorSetupRoute(c.FullPath()) {
+			enrolled, err := twofactor.Enrolled(conf.Db, dbUser.UserID)
+			if err != nil || !enrolled {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication must be set up first", "two_factor_setup_required": true})
+				c.Abort()
+				return
+			}
+		}
+
+		// Until they confirm their email address, users can only read and manage their own account.
+		if dbUser.NeedsVerification() && !readOnlyRequest(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email address first", "email_verification_required": true})
+			c.Abort()
 			return
 		}
 
@@ -65,41 +78,45 @@ func AuthMiddleware(conf *configuration.Dependencies) gin.HandlerFunc {
 			roleNames = append(roleNames, role.RoleName)
 		}
 
-		c.Set("username", username)
-		c.Set("user_id", claims["user_id"])
-		c.Set("roles", roleNames)
+		auth.SetPrincipal(c, &auth.Principal{
+			UserID:    dbUser.UserID,
+			Username:  strings.ToLower(dbUser.Username),
+			Roles:     roleNames,
+			SessionID: sess.ID,
+		})
 
 		c.Next()
 	}
 }
 
+// twoFactorSetupRoute reports whether a route stays open to users who still have to set up 2FA.
+func twoFactorSetupRoute(route string) bool {
+	return route == "/api/me" || route == "/api/logout" || strings.HasPrefix(route, "/api/me/2fa")
+}
+
+// readOnlyRequest reports whether a request is allowed for users with an unverified email address.
+func readOnlyRequest(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	route := c.FullPath()
+	return route == "/api/logout" || route == "/api/me" || strings.HasPrefix(route, "/api/me/")
+}
+
 func AllowedRoles(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userRoles, exists := c.Get("roles")
+		principal, exists := auth.CurrentPrincipal(c)
 		if !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User is not authenticated"})
 			c.Abort()
 			return
 		}
 
-		print(userRoles)
-
-		roles, ok := userRoles.([]string)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid roles format"})
-			c.Abort()
-			return
-		}
-
 		roleAllowed := false
 		for _, allowedRole := range allowedRoles {
-			for _, userRole := range roles {
-				if strings.ToLower(userRole) == strings.ToLower(allowedRole) {
-					roleAllowed = true
-					break
-				}
-			}
-			if roleAllowed {
+			if principal.HasRole(allowedRole) {
+				roleAllowed = true
 				break
 			}
 		}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Amenity is a feature a hall can offer, such as Wi-Fi or wheelchair access.
+type Amenity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Code      string    `gorm:"not null;size:50;uniqueIndex" json:"code"` // Stable identifier used in filters
+	Name      string    `gorm:"not null;size:255" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HallAmenity links halls and amenities.
+type HallAmenity struct {
+	HallID    uint `gorm:"primaryKey"`
+	AmenityID uint `gorm:"primaryKey"`
+}
+
+// DefaultAmenities seeds the catalog on first start.
+var DefaultAmenities = []Amenity{
+	{Code: "wifi", Name: "Wi-Fi"},
+	{Code: "stage", Name: "Stage"},
+	{Code: "av", Name: "Audio/visual equipment"},
+	{Code: "wheelchair_access", Name: "Wheelchair access"},
+	{Code: "natural_light", Name: "Natural light"},
+	{Code: "kitchen", Name: "Kitchen"},
+}
+
+func (Amenity) TableName() string {
+	return "hall_res_project.amenities"
+}
+
+func (HallAmenity) TableName() string {
+	return "hall_res_project.halls_amenities"
+}
@@ -1,29 +1,56 @@
 package models
 
 import (
+	"errors"
+	"fmt"
 	"time"
 )
 
 // Hall represents a venue that can be reserved.
 type Hall struct {
-	ID         uint    `gorm:"primaryKey" json:"id"`
-	Capacity   int     `gorm:"not null" json:"capacity"`
-	Location   string  `gorm:"not null;size:255" json:"location"`
-	Available  bool    `gorm:"default:true" json:"available"`
-	CostPerDay float64 `gorm:"not null" json:"cost_per_day"`
+	ID         uint     `gorm:"primaryKey" json:"id"`
+	Capacity   int      `gorm:"not null" json:"capacity"`
+	Location   string   `gorm:"not null;size:255" json:"location"`
+	FloorID    *uint    `gorm:"index" json:"floor_id"`
+	Floor      *Floor   `gorm:"foreignKey:FloorID" json:"floor,omitempty"`
+	Latitude   *float64 `gorm:"index:idx_halls_coordinates" json:"latitude"`
+	Longitude  *float64 `gorm:"index:idx_halls_coordinates" json:"longitude"`
+	Available  bool     `gorm:"default:true" json:"available"`
+	CostPerDay float64  `gorm:"not null" json:"cost_per_day"`
 	// New fields for available dates:
-	AvailableFrom time.Time     `json:"available_from"`
-	AvailableTo   time.Time     `json:"available_to"`
-	Reservations  []Reservation `gorm:"foreignKey:HallID" json:"reservations,omitempty"`
-	HallImages    []HallImage   `gorm:"foreignKey:HallID" json:"-"`
-	ImageURLs     []string      `gorm:"-" json:"images"`
+	AvailableFrom time.Time       `json:"available_from"`
+	AvailableTo   time.Time       `json:"available_to"`
+	ArchivedAt    *time.Time      `gorm:"index" json:"archived_at,omitempty"`       // Archived halls are hidden from search but kept for reports
+	Version       uint            `gorm:"not null;default:1" json:"version"`        // Incremented on every update, for optimistic concurrency
+	AverageRating float64         `gorm:"not null;default:0" json:"average_rating"` // Kept in sync with the visible reviews
+	ReviewCount   int             `gorm:"not null;default:0" json:"review_count"`
+	Reservations  []Reservation   `gorm:"foreignKey:HallID" json:"reservations,omitempty"`
+	HallImages    []HallImage     `gorm:"foreignKey:HallID" json:"-"`
+	Amenities     []Amenity       `gorm:"many2many:hall_res_project.halls_amenities;joinForeignKey:HallID;joinReferences:AmenityID" json:"amenities"`
+	AmenityCodes  []string        `gorm:"-" json:"amenity_codes,omitempty"` // Amenities to set when creating a hall
+	ImageURLs     []ImageVariants `gorm:"-" json:"images"`
+	CoverImage    *ImageVariants  `gorm:"-" json:"cover_image,omitempty"`
+	DistanceKm    *float64        `gorm:"-" json:"distance_km,omitempty"` // Set by the nearby search
 }
 
+// ImageVariants holds the URL of every size of a hall image.
+type ImageVariants struct {
+	Thumbnail string `json:"thumbnail"`
+	Medium    string `json:"medium"`
+	Original  string `json:"original"`
+}
+
+// HallImage is a photo of a hall. Images are shown in Position order; one of them may be the cover.
 type HallImage struct {
-	ID        uint   `gorm:"primaryKey"`
-	HallID    uint   `gorm:"not null"`
-	ImageName string `gorm:"size:255"`
-	Hall      Hall   `gorm:"foreignKey:HallID"`
+	ID        uint          `gorm:"primaryKey" json:"id"`
+	HallID    uint          `gorm:"not null;index" json:"hall_id"`
+	ImageName string        `gorm:"size:255" json:"image_name"`
+	Caption   string        `gorm:"size:255" json:"caption"`
+	AltText   string        `gorm:"size:255" json:"alt_text"`
+	Position  int           `gorm:"not null;default:0" json:"position"`
+	IsCover   bool          `gorm:"not null;default:false" json:"is_cover"`
+	Hall      Hall          `gorm:"foreignKey:HallID" json:"-"`
+	URLs      ImageVariants `gorm:"-" json:"urls"`
 }
 
 // TableName sets the table name for the Hall model in the database.
@@ -34,3 +61,28 @@ func (Hall) TableName() string {
 func (HallImage) TableName() string {
 	return "hall_res_project.halls_images"
 }
+
+var (
+	// ErrHallUnavailable is returned for bookings of a hall that has been deactivated.
+	ErrHallUnavailable = errors.New("hall is not available for booking")
+	// ErrHallArchived is returned for bookings of a hall that has been archived.
+	ErrHallArchived = errors.New("hall has been archived")
+)
+
+// CheckBookable verifies that the hall is active and not archived, and that start to end lies within its
+// AvailableFrom/AvailableTo window. A zero bound leaves that side of the window open.
+func (h *Hall) CheckBookable(start, end time.Time) error {
+	if h.ArchivedAt != nil {
+		return ErrHallArchived
+	}
+	if !h.Available {
+		return ErrHallUnavailable
+	}
+	if !h.AvailableFrom.IsZero() && start.Before(h.AvailableFrom) {
+		return fmt.Errorf("hall is only available from %s", h.AvailableFrom.Format("2006-01-02"))
+	}
+	if !h.AvailableTo.IsZero() && end.After(h.AvailableTo) {
+		return fmt.Errorf("hall is only available until %s", h.AvailableTo.Format("2006-01-02"))
+	}
+	return nil
+}
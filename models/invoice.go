@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Invoice document kinds.
+const (
+	InvoiceKindInvoice    = "invoice"
+	InvoiceKindCreditNote = "credit_note"
+)
+
+// ErrInvoiceImmutable is returned when something tries to change or remove an issued invoice.
+var ErrInvoiceImmutable = errors.New("issued invoices cannot be modified; issue a credit note instead")
+
+// Invoice is an issued invoice or credit note. Once stored it is never changed.
+type Invoice struct {
+	ID            uint   `gorm:"primaryKey" json:"id"`
+	Number        string `gorm:"not null;size:20;uniqueIndex" json:"number"`
+	FiscalYear    int    `gorm:"not null;uniqueIndex:idx_invoice_sequence" json:"fiscal_year"`
+	Sequence      int    `gorm:"not null;uniqueIndex:idx_invoice_sequence" json:"sequence"`
+	Kind          string `gorm:"not null;size:20" json:"kind"`
+	ReservationID uint   `gorm:"not null;index" json:"reservation_id"`
+	// CreditedInvoiceID links a credit note to the invoice it corrects.
+	CreditedInvoiceID *uint     `gorm:"index" json:"credited_invoice_id,omitempty"`
+	Reason            string    `gorm:"size:255" json:"reason,omitempty"`
+	IssuedAt          time.Time `gorm:"not null" json:"issued_at"`
+
+	SellerCompany string `gorm:"not null;size:255" json:"seller_company"`
+	SellerVATID   string `gorm:"size:50" json:"seller_vat_id"`
+	SellerAddress string `gorm:"size:255" json:"seller_address"`
+	BuyerCompany  string `gorm:"not null;size:255" json:"buyer_company"`
+	BuyerVATID    string `gorm:"size:50" json:"buyer_vat_id"`
+	BuyerAddress  string `gorm:"size:255" json:"buyer_address"`
+
+	Currency string        `gorm:"not null;size:3" json:"currency"`
+	NetTotal float64       `gorm:"not null" json:"net_total"`
+	VATTotal float64       `gorm:"not null" json:"vat_total"`
+	Total    float64       `gorm:"not null" json:"total"`
+	Lines    []InvoiceLine `gorm:"foreignKey:InvoiceID" json:"lines"`
+}
+
+// InvoiceLine is a single line item of an invoice.
+type InvoiceLine struct {
+	ID          uint    `gorm:"primaryKey" json:"-"`
+	InvoiceID   uint    `gorm:"not null;index" json:"-"`
+	Position    int     `gorm:"not null" json:"position"`
+	Description string  `gorm:"not null;size:255" json:"description"`
+	Quantity    float64 `gorm:"not null" json:"quantity"`
+	UnitPrice   float64 `gorm:"not null" json:"unit_price"` // Net of VAT
+	NetAmount   float64 `gorm:"not null" json:"net_amount"`
+	VATRate     float64 `gorm:"not null" json:"vat_rate"`
+	VATAmount   float64 `gorm:"not null" json:"vat_amount"`
+	GrossAmount float64 `gorm:"not null" json:"gross_amount"`
+}
+
+// InvoiceSequence holds the last invoice number used in a fiscal year.
+type InvoiceSequence struct {
+	FiscalYear int `gorm:"primaryKey;autoIncrement:false"`
+	LastNumber int `gorm:"not null"`
+}
+
+// TableName sets the table name for the Invoice model in the database.
+func (Invoice) TableName() string {
+	return "hall_res_project.invoices"
+}
+
+func (InvoiceLine) TableName() string {
+	return "hall_res_project.invoice_lines"
+}
+
+func (InvoiceSequence) TableName() string {
+	return "hall_res_project.invoice_sequences"
+}
+
+// FormatInvoiceNumber renders the printed invoice number, e.g. 2025-000042.
+func FormatInvoiceNumber(fiscalYear, sequence int) string {
+	return fmt.Sprintf("%d-%06d", fiscalYear, sequence)
+}
+
+// BeforeUpdate keeps issued invoices immutable.
+func (*Invoice) BeforeUpdate(tx *gorm.DB) error {
+	return ErrInvoiceImmutable
+}
+
+// BeforeDelete keeps issued invoices immutable.
+func (*Invoice) BeforeDelete(tx *gorm.DB) error {
+	return ErrInvoiceImmutable
+}
+
+// BeforeUpdate keeps line items of issued invoices immutable.
+func (*InvoiceLine) BeforeUpdate(tx *gorm.DB) error {
+	return ErrInvoiceImmutable
+}
+
+// BeforeDelete keeps line items of issued invoices immutable.
+func (*InvoiceLine) BeforeDelete(tx *gorm.DB) error {
+	return ErrInvoiceImmutable
+}
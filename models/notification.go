@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Notification is a message for a user about one of their reservations,
+// e.g. that it was cancelled or moved to another hall.
+type Notification struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        int64      `gorm:"not null;index" json:"user_id"`
+	ReservationID *uint      `gorm:"index" json:"reservation_id,omitempty"`
+	Message       string     `gorm:"not null;size:1000" json:"message"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ReadAt        *time.Time `json:"read_at,omitempty"`
+}
+
+func (Notification) TableName() string {
+	return "hall_res_project.notifications"
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"time"
+)
+
+// Payment kinds recorded in the ledger.
+const (
+	PaymentKindDeposit = "deposit"
+	PaymentKindPayment = "payment"
+	PaymentKindRefund  = "refund"
+)
+
+// Payment statuses reported by a provider.
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusConfirmed = "confirmed"
+	PaymentStatusFailed    = "failed"
+)
+
+// Reservation payment statuses derived from the ledger.
+const (
+	ReservationUnpaid      = "unpaid"
+	ReservationDepositPaid = "deposit_paid"
+	ReservationPaid        = "paid"
+	ReservationRefunded    = "refunded"
+)
+
+// Payment is a single entry in a reservation's payment ledger.
+type Payment struct {
+	ID            uint    `gorm:"primaryKey" json:"id"`
+	ReservationID uint    `gorm:"not null;index" json:"reservation_id"`
+	Kind          string  `gorm:"not null;size:20" json:"kind"`
+	Amount        float64 `gorm:"not null" json:"amount"`
+	Status        string  `gorm:"not null;size:20" json:"status"`
+	Provider      string  `gorm:"size:50" json:"provider"`
+	ProviderRef   string  `gorm:"size:255;index" json:"provider_ref"`
+	RefundOf      *uint   `json:"refund_of,omitempty"` // Payment being refunded
+	// IdempotencyKey is sent with the charge, so a charge can be repeated without collecting twice.
+	IdempotencyKey string     `gorm:"size:64;index" json:"-"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ConfirmedAt    *time.Time `json:"confirmed_at,omitempty"`
+}
+
+// TableName sets the table name for the Payment model in the database.
+func (Payment) TableName() string {
+	return "hall_res_project.payments"
+}
+
+// PaymentLedger summarizes all payments made against a reservation.
+type PaymentLedger struct {
+	ReservationID  uint      `json:"reservation_id"`
+	TotalCost      float64   `json:"total_cost"`
+	DepositAmount  float64   `json:"deposit_amount"`
+	DepositDueDate time.Time `json:"deposit_due_date"`
+	Paid           float64   `json:"paid"`
+	Refunded       float64   `json:"refunded"`
+	Pending        float64   `json:"pending"`
+	BalanceDue     float64   `json:"balance_due"`
+	Status         string    `json:"status"`
+	DepositOverdue bool      `json:"deposit_overdue"`
+	Payments       []Payment `json:"payments"`
+}
+
+// BuildLedger computes the balance and payment status of a reservation from its payments.
+// Only confirmed payments count towards the balance; pending ones are reported separately.
+func BuildLedger(r Reservation, payments []Payment, now time.Time) PaymentLedger {
+	ledger := PaymentLedger{
+		ReservationID:  r.ID,
+		TotalCost:      r.TotalCost,
+		DepositAmount:  r.DepositAmount,
+		DepositDueDate: r.DepositDueDate,
+		Payments:       payments,
+	}
+
+	for _, p := range payments {
+		switch {
+		case p.Status == PaymentStatusPending && p.Kind != PaymentKindRefund:
+			ledger.Pending += p.Amount
+		case p.Status != PaymentStatusConfirmed:
+			continue
+		case p.Kind == PaymentKindRefund:
+			ledger.Refunded += p.Amount
+		default:
+			ledger.Paid += p.Amount
+		}
+	}
+
+	net := ledger.Paid - ledger.Refunded
+	ledger.BalanceDue = r.TotalCost - net
+	if ledger.BalanceDue < 0 {
+		ledger.BalanceDue = 0
+	}
+
+	switch {
+	case ledger.Refunded > 0 && net <= 0:
+		ledger.Status = ReservationRefunded
+	case net >= r.TotalCost:
+		ledger.Status = ReservationPaid
+	case net >= r.DepositAmount && net > 0:
+		ledger.Status = ReservationDepositPaid
+	default:
+		ledger.Status = ReservationUnpaid
+	}
+
+	ledger.DepositOverdue = ledger.Status == ReservationUnpaid &&
+		!r.DepositDueDate.IsZero() && now.After(r.DepositDueDate)
+
+	return ledger
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildLedger(t *testing.T) {
+	now := time.Date(2030, 3, 10, 12, 0, 0, 0, time.UTC)
+	reservation := Reservation{ID: 1, TotalCost: 1000, DepositAmount: 300, DepositDueDate: now.AddDate(0, 0, 2)}
+	overdue := reservation
+	overdue.DepositDueDate = now.AddDate(0, 0, -1)
+
+	payment := func(kind, status string, amount float64) Payment {
+		return Payment{Kind: kind, Status: status, Amount: amount}
+	}
+
+	tests := []struct {
+		name        string
+		reservation Reservation
+		payments    []Payment
+		paid        float64
+		refunded    float64
+		pending     float64
+		balance     float64
+		status      string
+		overdue     bool
+	}{
+		{
+			name:        "nothing paid",
+			reservation: reservation,
+			balance:     1000,
+			status:      ReservationUnpaid,
+		},
+		{
+			name:        "deposit overdue",
+			reservation: overdue,
+			balance:     1000,
+			status:      ReservationUnpaid,
+			overdue:     true,
+		},
+		{
+			name:        "pending charges do not count",
+			reservation: overdue,
+			payments:    []Payment{payment(PaymentKindDeposit, PaymentStatusPending, 300)},
+			pending:     300,
+			balance:     1000,
+			status:      ReservationUnpaid,
+			overdue:     true,
+		},
+		{
+			name:        "deposit paid",
+			reservation: overdue,
+			payments:    []Payment{payment(PaymentKindDeposit, PaymentStatusConfirmed, 300)},
+			paid:        300,
+			balance:     700,
+			status:      ReservationDepositPaid,
+		},
+		{
+			name:        "partial payment below the deposit",
+			reservation: reservation,
+			payments:    []Payment{payment(PaymentKindPayment, PaymentStatusConfirmed, 100)},
+			paid:        100,
+			balance:     900,
+			status:      ReservationUnpaid,
+		},
+		{
+			name:        "failed payments are ignored",
+			reservation: reservation,
+			payments: []Payment{
+				payment(PaymentKindDeposit, PaymentStatusFailed, 300),
+				payment(PaymentKindPayment, PaymentStatusConfirmed, 1000),
+			},
+			paid:   1000,
+			status: ReservationPaid,
+		},
+		{
+			name:        "overpaid balance is not negative",
+			reservation: reservation,
+			payments:    []Payment{payment(PaymentKindPayment, PaymentStatusConfirmed, 1200)},
+			paid:        1200,
+			status:      ReservationPaid,
+		},
+		{
+			name:        "partly refunded",
+			reservation: reservation,
+			payments: []Payment{
+				payment(PaymentKindPayment, PaymentStatusConfirmed, 1000),
+				payment(PaymentKindRefund, PaymentStatusConfirmed, 600),
+			},
+			paid:     1000,
+			refunded: 600,
+			balance:  600,
+			status:   ReservationDepositPaid,
+		},
+		{
+			name:        "fully refunded",
+			reservation: overdue,
+			payments: []Payment{
+				payment(PaymentKindDeposit, PaymentStatusConfirmed, 300),
+				payment(PaymentKindRefund, PaymentStatusConfirmed, 300),
+				payment(PaymentKindRefund, PaymentStatusPending, 50),
+			},
+			paid:     300,
+			refunded: 300,
+			balance:  1000,
+			status:   ReservationRefunded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := BuildLedger(tt.reservation, tt.payments, now)
+			if l.Paid != tt.paid || l.Refunded != tt.refunded || l.Pending != tt.pending || l.BalanceDue != tt.balance {
+				t.Errorf("paid/refunded/pending/balance = %v/%v/%v/%v, want %v/%v/%v/%v",
+					l.Paid, l.Refunded, l.Pending, l.BalanceDue, tt.paid, tt.refunded, tt.pending, tt.balance)
+			}
+			if l.Status != tt.status {
+				t.Errorf("Status = %q, want %q", l.Status, tt.status)
+			}
+			if l.DepositOverdue != tt.overdue {
+				t.Errorf("DepositOverdue = %v, want %v", l.DepositOverdue, tt.overdue)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package models
 
 import (
+	"math"
 	"storage/services/user"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // Reservation represents a booking made for a hall.
@@ -17,16 +20,59 @@ type Reservation struct {
 	HallID    uint      `gorm:"not null" json:"hall_id"`
 	Hall      Hall      `gorm:"foreignKey:HallID" json:"hall,omitempty"`
 	User      user.User `gorm:"foreignKey:UserID" json:"user,omitempty"` // Use user.User instead of just User
+	// Payment tracking, kept in sync with the payments ledger.
+	DepositAmount  float64   `json:"deposit_amount"`
+	DepositDueDate time.Time `json:"deposit_due_date"`
+	AmountPaid     float64   `json:"amount_paid"`
+	PaymentStatus  string    `gorm:"size:20;default:unpaid" json:"payment_status"`
+	DepositOverdue bool      `gorm:"-" json:"deposit_overdue"`
+	// Cancelled reservations are kept, with their payments and invoices, but no longer block the hall.
+	Status      string     `gorm:"size:20;not null;default:active;index" json:"status"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+}
+
+// Reservation statuses.
+const (
+	ReservationStatusActive    = "active"
+	ReservationStatusCancelled = "cancelled"
+)
+
+// NotCancelled limits a reservation query to reservations that still stand.
+func NotCancelled(db *gorm.DB) *gorm.DB {
+	return db.Where("status <> ?", ReservationStatusCancelled)
+}
+
+// IsCancelled reports whether the reservation has been cancelled.
+func (r *Reservation) IsCancelled() bool {
+	return r.Status == ReservationStatusCancelled
 }
 
+// Deposit policy applied to new reservations.
+const (
+	DepositRate    = 0.30
+	DepositDueDays = 7
+)
+
 // TableName sets the table name for the Reservation model in the database.
 func (Reservation) TableName() string {
 	return "hall_res_project.reservations"
 }
 
-// CalculateTotalCost calculates the total cost of the reservation based on the hall's cost per day.
-// If the reservation lasts longer than 7 days, a 10% discount is applied.
-func (r *Reservation) CalculateTotalCost(costPerDay float64) {
+// CostBreakdown itemizes how the total cost of a reservation is made up.
+type CostBreakdown struct {
+	Days       float64 `json:"days"`
+	CostPerDay float64 `json:"cost_per_day"`
+	Subtotal   float64 `json:"subtotal"`
+	Discount   float64 `json:"discount"`
+	Total      float64 `json:"total"`
+}
+
+// LongStayDays is the reservation length after which the long-stay discount applies.
+const LongStayDays = 7
+
+// CostBreakdown computes the cost of the reservation based on the hall's cost per day.
+// If the reservation lasts longer than LongStayDays, a 10% discount is applied.
+func (r *Reservation) CostBreakdown(costPerDay float64) CostBreakdown {
 	// Calculate the number of days.
 	days := r.EndDate.Sub(r.StartDate).Hours() / 24
 	if days < 1 {
@@ -34,13 +80,45 @@ func (r *Reservation) CalculateTotalCost(costPerDay float64) {
 	}
 
 	// Calculate total cost without discount.
-	total := days * costPerDay
+	b := CostBreakdown{Days: days, CostPerDay: costPerDay, Subtotal: days * costPerDay}
 
 	// Apply a 10% discount for reservations longer than 7 days.
-	if days > 7 {
-		discount := total * 0.10
-		total -= discount
+	if days > LongStayDays {
+		b.Discount = b.Subtotal * 0.10
 	}
 
-	r.TotalCost = total
+	b.Total = b.Subtotal - b.Discount
+	return b
+}
+
+// CalculateTotalCost calculates the total cost of the reservation based on the hall's cost per day.
+func (r *Reservation) CalculateTotalCost(costPerDay float64) {
+	r.TotalCost = r.CostBreakdown(costPerDay).Total
+}
+
+// ApplyDepositPolicy sets the deposit amount and due date for a newly booked reservation.
+// The deposit is due DepositDueDays after booking, but never later than the start date.
+func (r *Reservation) ApplyDepositPolicy(bookedAt time.Time) {
+	r.DepositAmount = math.Round(r.TotalCost*DepositRate*100) / 100
+
+	due := bookedAt.AddDate(0, 0, DepositDueDays)
+	if r.StartDate.Before(due) {
+		due = r.StartDate
+	}
+	r.DepositDueDate = due
+	if r.PaymentStatus == "" {
+		r.PaymentStatus = ReservationUnpaid
+	}
+}
+
+// IsDepositOverdue reports whether the deposit is still unpaid past its due date.
+func (r *Reservation) IsDepositOverdue(now time.Time) bool {
+	return (r.PaymentStatus == "" || r.PaymentStatus == ReservationUnpaid) &&
+		!r.DepositDueDate.IsZero() && now.After(r.DepositDueDate)
+}
+
+// AfterFind flags reservations with an overdue deposit whenever they are loaded.
+func (r *Reservation) AfterFind(tx *gorm.DB) error {
+	r.DepositOverdue = r.IsDepositOverdue(time.Now())
+	return nil
 }
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Review is a rating of a hall left by the user who booked it, at most one per reservation.
+// Hidden reviews are kept for moderation but left out of ratings and listings.
+type Review struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	ReservationID uint       `gorm:"not null;uniqueIndex" json:"reservation_id"`
+	HallID        uint       `gorm:"not null;index" json:"hall_id"`
+	UserID        int64      `gorm:"not null;index" json:"user_id"`
+	Rating        int        `gorm:"not null" json:"rating"` // 1 to 5
+	Comment       string     `gorm:"size:2000" json:"comment"`
+	Hidden        bool       `gorm:"not null;default:false" json:"hidden"`
+	HiddenReason  string     `gorm:"size:255" json:"hidden_reason,omitempty"`
+	HiddenAt      *time.Time `json:"hidden_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Bounds of Review.Rating.
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+func (Review) TableName() string {
+	return "hall_res_project.reviews"
+}
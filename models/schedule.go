@@ -0,0 +1,32 @@
+package models
+
+// OpeningHours is the time range a hall is open on one day of the week.
+// Times are "HH:MM" in the hall's local timezone; Closes may be "24:00".
+// A hall without any opening hours is open around the clock.
+type OpeningHours struct {
+	ID      uint   `gorm:"primaryKey" json:"id"`
+	HallID  uint   `gorm:"not null;uniqueIndex:idx_opening_hours_hall_weekday" json:"hall_id"`
+	Weekday int    `gorm:"not null;uniqueIndex:idx_opening_hours_hall_weekday" json:"weekday"` // 0 is Sunday, as in time.Weekday
+	Opens   string `gorm:"not null;size:5" json:"opens"`
+	Closes  string `gorm:"not null;size:5" json:"closes"`
+}
+
+// ScheduleException overrides the weekly opening hours of a hall on one date,
+// either closing it for the day or opening it with special hours.
+type ScheduleException struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	HallID uint   `gorm:"not null;uniqueIndex:idx_schedule_exceptions_hall_date" json:"hall_id"`
+	Date   string `gorm:"not null;size:10;uniqueIndex:idx_schedule_exceptions_hall_date" json:"date"` // YYYY-MM-DD
+	Closed bool   `gorm:"not null;default:false" json:"closed"`
+	Opens  string `gorm:"size:5" json:"opens,omitempty"`
+	Closes string `gorm:"size:5" json:"closes,omitempty"`
+	Reason string `gorm:"size:255" json:"reason"`
+}
+
+func (OpeningHours) TableName() string {
+	return "hall_res_project.halls_opening_hours"
+}
+
+func (ScheduleException) TableName() string {
+	return "hall_res_project.halls_schedule_exceptions"
+}
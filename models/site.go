@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// DefaultTimezone applies to sites created without a timezone and to halls not placed in a building.
+const DefaultTimezone = "Europe/Sofia"
+
+// Site is a campus or venue grouping one or more buildings.
+type Site struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	Name      string     `gorm:"not null;size:255" json:"name"`
+	Address   string     `gorm:"size:255" json:"address"`
+	City      string     `gorm:"size:100" json:"city"`
+	Country   string     `gorm:"size:100" json:"country"`
+	Timezone  string     `gorm:"not null;size:64;default:Europe/Sofia" json:"timezone"` // IANA name, e.g. Europe/Sofia
+	Buildings []Building `gorm:"foreignKey:SiteID" json:"buildings,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Building belongs to a site and is split into floors.
+type Building struct {
+	ID       uint    `gorm:"primaryKey" json:"id"`
+	SiteID   uint    `gorm:"not null;index" json:"site_id"`
+	Name     string  `gorm:"not null;size:255" json:"name"`
+	Address  string  `gorm:"size:255" json:"address"`
+	Timezone string  `gorm:"size:64" json:"timezone"` // Overrides the site timezone when set
+	Site     *Site   `gorm:"foreignKey:SiteID" json:"site,omitempty"`
+	Floors   []Floor `gorm:"foreignKey:BuildingID" json:"floors,omitempty"`
+}
+
+// Floor is a level of a building on which halls are located.
+type Floor struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	BuildingID uint      `gorm:"not null;index" json:"building_id"`
+	Level      int       `gorm:"not null" json:"level"` // 0 is the ground floor, negative levels are underground
+	Name       string    `gorm:"size:100" json:"name"`
+	Building   *Building `gorm:"foreignKey:BuildingID" json:"building,omitempty"`
+}
+
+func (Site) TableName() string {
+	return "hall_res_project.sites"
+}
+
+func (Building) TableName() string {
+	return "hall_res_project.buildings"
+}
+
+func (Floor) TableName() string {
+	return "hall_res_project.floors"
+}
+
+// EffectiveTimezone returns the building's timezone, falling back to its site's.
+func (b *Building) EffectiveTimezone() string {
+	if b.Timezone != "" {
+		return b.Timezone
+	}
+	if b.Site != nil {
+		return b.Site.Timezone
+	}
+	return ""
+}
+
+// Timezone returns the IANA timezone of the hall's building, or DefaultTimezone
+// if the hall is not placed in one. The Floor.Building.Site chain must be preloaded.
+func (h *Hall) Timezone() string {
+	if h.Floor != nil && h.Floor.Building != nil {
+		if tz := h.Floor.Building.EffectiveTimezone(); tz != "" {
+			return tz
+		}
+	}
+	return DefaultTimezone
+}
@@ -2,18 +2,34 @@ package main
 
 import (
 	"github.com/gin-gonic/gin"
+	"log"
 	"net/http"
 	"storage/configuration"
 	. "storage/middleware"
+	"storage/services/account"
+	"storage/services/amenity"
+	"storage/services/audit"
 	"storage/services/hall" // Import Hall service
+	"storage/services/invoice"
 	login "storage/services/login"
+	"storage/services/notification"
+	"storage/services/payment"
+	"storage/services/receipt"
 	register "storage/services/register"
 	"storage/services/reservation" // Import Reservation service
+	"storage/services/review"
+	"storage/services/schedule"
+	"storage/services/site"
+	"storage/services/twofactor"
 	"storage/services/user"
 )
 
 func Routes(d *configuration.Dependencies) *gin.Engine {
 	r := gin.New()
+	// Gin trusts every proxy by default, which would let clients choose their own IP.
+	if err := r.SetTrustedProxies(d.Cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
 	r.Use(gin.Recovery())
 	r.Use(LoggingMiddleware)
 	r.Use(CORSandCSP())
@@ -22,12 +38,46 @@ func Routes(d *configuration.Dependencies) *gin.Engine {
 		c.String(http.StatusOK, "This is version 2.0 - updates:LOGIN authentication JWT added.")
 	})
 
+	// Public keys for verifying access tokens
+	r.GET("/.well-known/jwks.json", login.JWKSHandler(d))
+
 	apiGroup := r.Group("/api")
 	{
 		// Public routes
 		apiGroup.POST("/login", login.LoginHandler(d))
+		// Second login step for users with two-factor authentication
+		apiGroup.POST("/login/2fa", login.TwoFactorHandler(d))
 		// Register route
 		apiGroup.POST("/register", register.RegisterHandler(d))
+		// Forgotten password: email a reset link, then set the new password with its token
+		apiGroup.POST("/password/reset", account.RequestPasswordReset(d))
+		apiGroup.POST("/password/reset/confirm", account.ConfirmPasswordReset(d))
+		// Confirm an email address with the token from the verification email
+		apiGroup.POST("/email/verify", account.VerifyEmail(d))
+		// Exchange a refresh token for a new token pair
+		apiGroup.POST("/token/refresh", login.RefreshHandler(d))
+		// Revoke the current session; open to every authenticated user
+		apiGroup.POST("/logout", AuthMiddleware(d), login.LogoutHandler(d))
+		// Payment provider callbacks, authenticated by signature
+		apiGroup.POST("/payments/webhook/:provider", payment.PaymentWebhook(d))
+
+		{ // Own Account Routes, open to every authenticated user
+			meGroup := apiGroup.Group("/me")
+			meGroup.Use(AuthMiddleware(d))
+
+			meGroup.GET("", account.GetProfile(d))                               // Profile of the current user
+			meGroup.PUT("/password", account.ChangePassword(d))                  // Change password
+			meGroup.GET("/sessions", account.GetSessions(d))                     // Active sessions
+			meGroup.DELETE("/sessions/:id", account.RevokeSession(d))            // Revoke a session
+			meGroup.GET("/logins", audit.GetMyLogins(d))                         // Login history
+			meGroup.POST("/email/verification", account.ResendMyVerification(d)) // Resend the verification email
+
+			meGroup.GET("/2fa", twofactor.GetStatus(d))                               // Two-factor status
+			meGroup.POST("/2fa/enroll", twofactor.Enroll(d))                          // New TOTP secret and provisioning URI
+			meGroup.POST("/2fa/confirm", twofactor.Confirm(d))                        // Enable 2FA, returns recovery codes
+			meGroup.POST("/2fa/recovery-codes", twofactor.RegenerateRecoveryCodes(d)) // Replace recovery codes
+			meGroup.DELETE("/2fa", twofactor.Disable(d))                              // Disable 2FA
+		}
 
 		// Routes requiring authentication
 		protected := apiGroup.Group("/")
@@ -43,18 +93,90 @@ func Routes(d *configuration.Dependencies) *gin.Engine {
 			usersGroup.POST("/update-role", user.HandlerUpdateRole(d))
 			usersGroup.POST("/assign-role", user.HandlerAssignRole(d))
 			usersGroup.POST("/revoke-role", user.HandlerRevokeRole(d))
+			usersGroup.POST("/users/:id/unlock", login.UnlockAccount(d))                     // Lift a failed-login lockout
+			usersGroup.DELETE("/users/:id/2fa", twofactor.ResetUser(d))                      // Remove a lost 2FA setup
+			usersGroup.POST("/users/:id/deactivate", user.HandlerDeactivateUser(d))          // Block logins and end sessions
+			usersGroup.POST("/users/:id/reactivate", user.HandlerReactivateUser(d))          // Allow logins again
+			usersGroup.GET("/users/:id/logins", audit.GetUserLogins(d))                      // Login history
+			usersGroup.POST("/users/:id/resend-verification", account.ResendVerification(d)) // Resend the verification email
+			usersGroup.PUT("/roles/:id/2fa", twofactor.SetRoleRequirement(d))                // Make 2FA mandatory for a role
+			usersGroup.GET("/audit/auth", audit.GetAuthEvents(d))                            // Authentication audit log
+		}
+
+		{ // Amenity Catalog Routes
+			amenityGroup := protected.Group("/amenities")
+
+			amenityGroup.GET("", amenity.GetAmenities(d))         // List the amenity catalog
+			amenityGroup.POST("", amenity.CreateAmenity(d))       // Add an amenity
+			amenityGroup.PUT("/:id", amenity.UpdateAmenity(d))    // Rename an amenity
+			amenityGroup.DELETE("/:id", amenity.DeleteAmenity(d)) // Remove an amenity from the catalog
+		}
+
+		{ // Site Hierarchy Routes
+			siteGroup := protected.Group("/sites")
+
+			siteGroup.GET("", site.GetSites(d))          // List sites with their buildings and floors
+			siteGroup.POST("", site.CreateSite(d))       // Create a site
+			siteGroup.PUT("/:id", site.UpdateSite(d))    // Update a site
+			siteGroup.DELETE("/:id", site.DeleteSite(d)) // Delete an empty site
+
+			buildingGroup := protected.Group("/buildings")
+
+			buildingGroup.GET("", site.GetBuildings(d))                           // List buildings, optionally of one site
+			buildingGroup.POST("", site.CreateBuilding(d))                        // Create a building on a site
+			buildingGroup.GET("/utilization", hall.GetBuildingsUtilization(d))    // Utilization rolled up per building
+			buildingGroup.PUT("/:id", site.UpdateBuilding(d))                     // Update a building
+			buildingGroup.DELETE("/:id", site.DeleteBuilding(d))                  // Delete a building without floors
+			buildingGroup.POST("/:id/floors", site.CreateFloor(d))                // Add a floor to a building
+			buildingGroup.GET("/:id/utilization", hall.GetBuildingUtilization(d)) // Utilization of one building
+
+			floorGroup := protected.Group("/floors")
+
+			floorGroup.DELETE("/:id", site.DeleteFloor(d)) // Delete a floor without halls
+		}
+
+		{ // Review Moderation Routes
+			reviewGroup := protected.Group("/reviews")
+
+			reviewGroup.PUT("/:id/hide", review.HideReview(d))     // Hide an abusive review
+			reviewGroup.PUT("/:id/unhide", review.UnhideReview(d)) // Show a hidden review again
+		}
+
+		{ // Receipt Template Routes
+			receiptGroup := protected.Group("/receipts")
+
+			receiptGroup.POST("/preview", receipt.PreviewReceipt(d)) // Preview a receipt template while editing it
 		}
 
 		{ // Hall Management Routes
 			hallGroup := protected.Group("/halls")
 			hallGroup.Use(AllowedRoles("user"))
 
-			hallGroup.POST("", hall.CreateHall(d))                            // Create a new hall
-			hallGroup.GET("", hall.GetHalls(d))                               // Get all halls
-			hallGroup.GET("/image/:path", hall.ServeImage())                  // Get all halls
-			hallGroup.PUT("/:id", hall.UpdateHall(d))                         // Update a hall by ID
-			hallGroup.DELETE("/:id", hall.DeleteHall(d))                      // Delete a hall by ID
-			hallGroup.GET("/:id/utilization", hall.GetHallUtilizationRate(d)) // Statistics on Hall usage
+			hallGroup.POST("", hall.CreateHall(d))                                                         // Create a new hall
+			hallGroup.GET("", hall.GetHalls(d))                                                            // Get all halls
+			hallGroup.GET("/nearby", hall.NearbyHalls(d))                                                  // Halls within a radius, nearest first
+			hallGroup.GET("/image/:path", hall.ServeImage(d))                                              // Get a hall image
+			hallGroup.GET("/:id", hall.GetHall(d))                                                         // Get a hall by ID, with its version as ETag
+			hallGroup.PATCH("/:id", hall.PatchHall(d))                                                     // Partially update a hall (JSON Merge Patch, If-Match required)
+			hallGroup.PUT("/:id", hall.UpdateHall(d))                                                      // Update a hall by ID
+			hallGroup.DELETE("/:id", hall.DeleteHall(d))                                                   // Archive a hall by ID
+			hallGroup.POST("/:id/restore", hall.RestoreHall(d))                                            // Bring an archived hall back
+			hallGroup.GET("/:id/utilization", hall.GetHallUtilizationRate(d))                              // Statistics on Hall usage
+			hallGroup.PUT("/:id/amenities", amenity.SetHallAmenities(d))                                   // Replace the amenities of a hall
+			hallGroup.PUT("/:id/availability", reservation.SetHallAvailability(d))                         // Activate, deactivate or change the availability window
+			hallGroup.GET("/:id/affected-reservations", reservation.GetAffectedReservations(d))            // Reservations outside the hall's availability
+			hallGroup.POST("/:id/affected-reservations", reservation.ResolveAffectedReservations(d))       // Bulk notify, cancel or move them
+			hallGroup.GET("/:id/reviews", review.GetHallReviews(d))                                        // Reviews of a hall
+			hallGroup.GET("/:id/schedule", schedule.GetHallSchedule(d))                                    // Opening hours and exceptions
+			hallGroup.PUT("/:id/opening-hours", schedule.SetOpeningHours(d))                               // Replace the weekly opening hours
+			hallGroup.POST("/:id/schedule-exceptions", schedule.AddScheduleException(d))                   // Close or change hours on a date
+			hallGroup.DELETE("/:id/schedule-exceptions/:exceptionId", schedule.DeleteScheduleException(d)) // Remove a date exception
+			hallGroup.GET("/:id/images", hall.GetHallImages(d))                                            // List the images of a hall
+			hallGroup.POST("/:id/images", hall.AddHallImages(d))                                           // Upload more images
+			hallGroup.PUT("/:id/images/order", hall.ReorderHallImages(d))                                  // Change the display order
+			hallGroup.PATCH("/:id/images/:imageId", hall.UpdateHallImage(d))                               // Edit caption and alt text
+			hallGroup.DELETE("/:id/images/:imageId", hall.DeleteHallImage(d))                              // Remove an image
+			hallGroup.PUT("/:id/images/:imageId/cover", hall.SetHallCoverImage(d))                         // Make an image the cover
 		}
 
 		{ // Reservation Management Routes
@@ -67,6 +189,35 @@ func Routes(d *configuration.Dependencies) *gin.Engine {
 			reservationGroup.PUT("/:id", reservation.UpdateReservation(d))                  //Manage/Modify reservations
 			reservationGroup.GET("/categorized", reservation.GetCategorizedReservations(d)) // New endpoint for categorized reservations.
 			reservationGroup.GET("/summary", reservation.GetReservationSummary(d))          //Dashboard for reservations
+			reservationGroup.GET("/:id/payments", payment.GetLedger(d))                     // Payment ledger of a reservation
+			reservationGroup.POST("/:id/payments", payment.CreatePayment(d))                // Pay a deposit or part of the balance
+			reservationGroup.POST("/:id/invoice", invoice.CreateInvoice(d))                 // Issue the invoice of a reservation
+			reservationGroup.GET("/:id/receipt", receipt.GetReceipt(d))                     // Download the receipt as PDF or text
+			reservationGroup.POST("/:id/review", review.CreateReview(d))                    // Rate the hall after the reservation has ended
+		}
+
+		{ // Payment Management Routes
+			paymentGroup := protected.Group("/payments")
+			paymentGroup.Use(AllowedRoles("user"))
+
+			paymentGroup.POST("/:id/refund", payment.RefundPayment(d))  // Refund a confirmed payment
+			paymentGroup.GET("/overdue", payment.GetOverdueDeposits(d)) // Reservations with an overdue deposit
+		}
+
+		{ // Notification Routes
+			notificationGroup := protected.Group("/notifications")
+			notificationGroup.Use(AllowedRoles("user"))
+
+			notificationGroup.GET("", notification.GetNotifications(d))               // Notifications of the current user
+			notificationGroup.POST("/:id/read", notification.MarkNotificationRead(d)) // Mark a notification as read
+		}
+
+		{ // Invoice Routes
+			invoiceGroup := protected.Group("/invoices")
+			invoiceGroup.Use(AllowedRoles("user"))
+
+			invoiceGroup.GET("/:id", invoice.GetInvoice(d))                    // Get an issued invoice
+			invoiceGroup.POST("/:id/credit-note", invoice.CreateCreditNote(d)) // Correct an invoice with a credit note
 		}
 	}
 
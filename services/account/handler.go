@@ -0,0 +1,162 @@
+package account
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"storage/configuration"
+	"storage/services/audit"
+	"storage/services/auth"
+	"storage/services/lockout"
+	"storage/services/session"
+	"storage/services/user"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// GetProfile returns the account of the authenticated user.
+func GetProfile(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := auth.CurrentPrincipal(c)
+
+		var u user.User
+		if err := conf.Db.Preload("Roles").Where("id = ?", principal.UserID).First(&u).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusOK, u)
+	}
+}
+
+// ChangePassword sets a new password after checking the current one. Every other
+// session of the user is revoked, so a leaked password stops working everywhere.
+// A wrong current password counts as a failed login, so a stolen session cannot be
+// used to guess the password around the login lockout.
+func ChangePassword(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := auth.CurrentPrincipal(c)
+
+		var req user.ChangePassword
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var u user.User
+		if err := conf.Db.Where("id = ?", principal.UserID).First(&u).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		attempt, ok := beginAttempt(c, conf, u.Username)
+		if !ok {
+			return
+		}
+		defer attempt.Release(c.Request.Context())
+		if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.CurrPassword)); err != nil {
+			locked, err := attempt.Fail(c.Request.Context())
+			if err != nil {
+				log.Printf("Failed to record failed password check of %q: %v", u.Username, err)
+			}
+			if locked {
+				audit.Record(conf.Db, audit.AuthEvent{Type: audit.EventAccountLocked, Username: u.Username, UserID: &u.UserID, IP: c.ClientIP(), Detail: "too many failed password checks"})
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+			return
+		}
+		if req.NewPassword != req.RepeatPassword {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "New passwords do not match"})
+			return
+		}
+		if req.NewPassword == req.CurrPassword {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "New password must differ from the current one"})
+			return
+		}
+		if err := user.ValidatePassword(req.NewPassword, u.Username); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
+		if err := conf.Db.Model(&u).Update("password", string(hashedPassword)).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+			return
+		}
+
+		if err := session.RevokeOthers(conf.Db, u.UserID, principal.SessionID, session.ReasonPasswordChange); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Password changed, but failed to end other sessions"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+	}
+}
+
+// beginAttempt admits a password check of the user, or answers the request and returns
+// false while their logins are locked or throttled.
+func beginAttempt(c *gin.Context, conf *configuration.Dependencies, username string) (*lockout.Attempt, bool) {
+	attempt, verdict, err := conf.Logins.Begin(c.Request.Context(), username, c.ClientIP(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify attempt"})
+		return nil, false
+	}
+	if verdict.Allowed() {
+		return attempt, true
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(verdict.RetryAfter.Seconds()))))
+	if verdict.Locked {
+		c.JSON(http.StatusLocked, gin.H{"error": "Account is temporarily locked after too many failed attempts"})
+	} else {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+	}
+	return nil, false
+}
+
+// GetSessions lists the live sessions of the authenticated user, marking the current one.
+func GetSessions(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := auth.CurrentPrincipal(c)
+
+		sessions, err := session.ListActive(conf.Db, principal.UserID, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sessions"})
+			return
+		}
+
+		type sessionView struct {
+			session.Session
+			Current bool `json:"current"`
+		}
+		views := make([]sessionView, 0, len(sessions))
+		for _, s := range sessions {
+			views = append(views, sessionView{Session: s, Current: s.ID == principal.SessionID})
+		}
+		c.JSON(http.StatusOK, views)
+	}
+}
+
+// RevokeSession ends one of the authenticated user's sessions, e.g. a lost device.
+func RevokeSession(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := auth.CurrentPrincipal(c)
+
+		s, err := session.Find(conf.Db, c.Param("id"))
+		if err != nil || s.UserID != principal.UserID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+
+		if err := session.Revoke(conf.Db, s.ID, session.ReasonRevokedByUser); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+	}
+}
@@ -0,0 +1,203 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"storage/configuration"
+	"storage/services/mail"
+	"storage/services/session"
+	"storage/services/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PasswordResetToken lets the owner of an email address set a new password once.
+// Only the SHA-256 of the token is stored.
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    int64     `gorm:"not null;index"`
+	TokenHash string    `gorm:"not null;size:64;uniqueIndex"`
+	CreatedAt time.Time `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+func (PasswordResetToken) TableName() string {
+	return "hall_res_project.password_reset_tokens"
+}
+
+var errInvalidResetToken = errors.New("invalid or expired reset token")
+
+// resetRequested is the answer to every reset request, so it cannot be used to find out
+// which addresses have an account.
+const resetRequested = "If an account with that email exists, a password reset link has been sent"
+
+// RequestPasswordReset emails a reset link to the account with the given address, if there is one.
+// Requests are throttled per address and per client IP, whether or not the account exists,
+// so the endpoint can neither flood a mailbox nor be used to probe many addresses quickly.
+func RequestPasswordReset(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Email string `json:"email" binding:"required,email"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		email := strings.TrimSpace(strings.ToLower(req.Email))
+
+		attempt, verdict, err := conf.Resets.Begin(c.Request.Context(), email, c.ClientIP(), time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify request"})
+			return
+		}
+		if !verdict.Allowed() {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(verdict.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many password reset requests, try again later"})
+			return
+		}
+		// Every request counts against the allowance, not only unsuccessful ones.
+		if _, err := attempt.Fail(c.Request.Context()); err != nil {
+			log.Printf("Failed to record password reset request: %v", err)
+		}
+
+		var u user.User
+		err = conf.Db.Where("email = ? AND is_active = ?", email, true).First(&u).Error
+		if err == nil {
+			if err := sendResetLink(conf, &u); err != nil {
+				// Logged only; the response must look the same as for unknown addresses.
+				log.Printf("Failed to issue password reset for user %d: %v", u.UserID, err)
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Failed to look up user for password reset: %v", err)
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": resetRequested})
+	}
+}
+
+// sendResetLink replaces any outstanding reset token of the user and queues the email.
+func sendResetLink(conf *configuration.Dependencies, u *user.User) error {
+	token, err := session.RandomToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	ttl := conf.Cfg.Auth.PasswordResetTTL()
+	link := tokenLink(conf.Cfg.Mail.PasswordResetURL, token)
+
+	return conf.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", u.UserID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&PasswordResetToken{
+			UserID:    u.UserID,
+			TokenHash: session.Hash(token),
+			CreatedAt: now,
+			ExpiresAt: now.Add(ttl),
+		}).Error; err != nil {
+			return err
+		}
+		return mail.Enqueue(tx, mail.Message{
+			To:      *u.Email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf("Hello %s,\n\nUse the link below to choose a new password. It is valid for %d minutes and can be used once.\n\n%s\n\n"+
+				"If you did not ask for a password reset, you can ignore this email.\n",
+				u.Username, int(ttl.Minutes()), link),
+		})
+	})
+}
+
+// tokenLink puts a token into a link template, or returns the bare token if there is no template.
+func tokenLink(template, token string) string {
+	if template == "" {
+		return token
+	}
+	return strings.Replace(template, "%s", token, 1)
+}
+
+// ConfirmPasswordReset sets a new password using a reset token. The token is spent and
+// every session of the user is revoked.
+func ConfirmPasswordReset(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Token          string `json:"token" binding:"required"`
+			NewPassword    string `json:"new_password" binding:"required"`
+			RepeatPassword string `json:"repeat_password" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if req.NewPassword != req.RepeatPassword {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "New passwords do not match"})
+			return
+		}
+
+		var userID int64
+		var policyErr error
+		err := conf.Db.Transaction(func(tx *gorm.DB) error {
+			var t PasswordResetToken
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("token_hash = ?", session.Hash(req.Token)).First(&t).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidResetToken
+			}
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			if t.UsedAt != nil || !now.Before(t.ExpiresAt) {
+				return errInvalidResetToken
+			}
+
+			var u user.User
+			if err := tx.Where("id = ? AND is_active = ?", t.UserID, true).First(&u).Error; err != nil {
+				return errInvalidResetToken
+			}
+			if policyErr = user.ValidatePassword(req.NewPassword, u.Username); policyErr != nil {
+				return policyErr
+			}
+
+			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&u).Update("password", string(hashedPassword)).Error; err != nil {
+				return err
+			}
+			userID = u.UserID
+			return tx.Model(&t).Update("used_at", now).Error
+		})
+
+		switch {
+		case errors.Is(err, errInvalidResetToken):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+			return
+		case policyErr != nil:
+			c.JSON(http.StatusBadRequest, gin.H{"error": policyErr.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+			return
+		}
+
+		if err := session.RevokeAll(conf.Db, userID, session.ReasonPasswordReset); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Password changed, but failed to end existing sessions"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+	}
+}
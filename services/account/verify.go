@@ -0,0 +1,168 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"storage/configuration"
+	"storage/services/auth"
+	"storage/services/mail"
+	"storage/services/session"
+	"storage/services/user"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EmailVerificationToken proves that its holder receives mail at Email. Only the SHA-256 of the
+// token is stored. The address is kept so a token cannot verify an address it was not sent to.
+type EmailVerificationToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    int64     `gorm:"not null;index"`
+	Email     string    `gorm:"not null;size:255"`
+	TokenHash string    `gorm:"not null;size:64;uniqueIndex"`
+	CreatedAt time.Time `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+func (EmailVerificationToken) TableName() string {
+	return "hall_res_project.email_verification_tokens"
+}
+
+var (
+	errAlreadyVerified = errors.New("email address is already verified")
+	errNoEmail         = errors.New("user has no email address")
+)
+
+// SendVerification replaces any outstanding verification token of the user and queues
+// an email with a new one. Pass a transaction to send only if the surrounding change commits.
+func SendVerification(db *gorm.DB, conf *configuration.Dependencies, u *user.User) error {
+	if u.Email == nil {
+		return errNoEmail
+	}
+	if !u.NeedsVerification() {
+		return errAlreadyVerified
+	}
+
+	token, err := session.RandomToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	ttl := conf.Cfg.Auth.EmailVerificationTTL()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&EmailVerificationToken{}).
+			Where("user_id = ? AND used_at IS NULL", u.UserID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&EmailVerificationToken{
+			UserID:    u.UserID,
+			Email:     *u.Email,
+			TokenHash: session.Hash(token),
+			CreatedAt: now,
+			ExpiresAt: now.Add(ttl),
+		}).Error; err != nil {
+			return err
+		}
+		return mail.Enqueue(tx, mail.Message{
+			To:      *u.Email,
+			Subject: "Confirm your email address",
+			Body: fmt.Sprintf("Hello %s,\n\nPlease confirm your email address with the link below. It is valid for %d hours.\n\n%s\n\n"+
+				"Until then you can look around, but not make reservations or other changes.\n",
+				u.Username, int(ttl.Hours()), tokenLink(conf.Cfg.Mail.EmailVerificationURL, token)),
+		})
+	})
+}
+
+// VerifyEmail confirms the address a verification token was sent to.
+func VerifyEmail(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Token string `json:"token" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		err := conf.Db.Transaction(func(tx *gorm.DB) error {
+			var t EmailVerificationToken
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("token_hash = ?", session.Hash(req.Token)).First(&t).Error
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			if t.UsedAt != nil || !now.Before(t.ExpiresAt) {
+				return gorm.ErrRecordNotFound
+			}
+
+			res := tx.Model(&user.User{}).
+				Where("id = ? AND email = ?", t.UserID, t.Email).
+				Update("email_verified_at", now)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				// The address changed since the token was sent.
+				return gorm.ErrRecordNotFound
+			}
+			return tx.Model(&t).Update("used_at", now).Error
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email address"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Email address verified"})
+	}
+}
+
+// ResendMyVerification sends a new verification email to the authenticated user.
+func ResendMyVerification(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := auth.CurrentPrincipal(c)
+		resendVerification(c, conf, principal.UserID)
+	}
+}
+
+// ResendVerification sends a new verification email to a user, e.g. when the first one got lost.
+func ResendVerification(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		resendVerification(c, conf, userID)
+	}
+}
+
+func resendVerification(c *gin.Context, conf *configuration.Dependencies, userID int64) {
+	var u user.User
+	if err := conf.Db.Where("id = ?", userID).First(&u).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err := SendVerification(conf.Db, conf, &u)
+	switch {
+	case errors.Is(err, errNoEmail):
+		c.JSON(http.StatusConflict, gin.H{"error": "User has no email address"})
+	case errors.Is(err, errAlreadyVerified):
+		c.JSON(http.StatusConflict, gin.H{"error": "Email address is already verified"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+	}
+}
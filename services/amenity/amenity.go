@@ -0,0 +1,79 @@
+package amenity
+
+import (
+	"fmt"
+	"storage/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SeedDefaults adds the default amenities that are missing from the catalog.
+func SeedDefaults(db *gorm.DB) error {
+	for _, a := range models.DefaultAmenities {
+		amenity := a
+		if err := db.Where("code = ?", amenity.Code).FirstOrCreate(&amenity).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseCodes splits a comma-separated list of amenity codes, dropping blanks and duplicates.
+func ParseCodes(list string) []string {
+	seen := map[string]bool{}
+	var codes []string
+	for _, code := range strings.Split(list, ",") {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if code != "" && !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// Resolve looks up amenities by code and fails if any code is unknown.
+func Resolve(db *gorm.DB, codes []string) ([]models.Amenity, error) {
+	if len(codes) == 0 {
+		return nil, nil
+	}
+
+	var amenities []models.Amenity
+	if err := db.Where("code IN ?", codes).Find(&amenities).Error; err != nil {
+		return nil, err
+	}
+	if len(amenities) != len(codes) {
+		found := map[string]bool{}
+		for _, a := range amenities {
+			found[a.Code] = true
+		}
+		var unknown []string
+		for _, code := range codes {
+			if !found[code] {
+				unknown = append(unknown, code)
+			}
+		}
+		return nil, fmt.Errorf("unknown amenities: %s", strings.Join(unknown, ", "))
+	}
+	return amenities, nil
+}
+
+// FilterHalls restricts a hall query to halls offering the given amenities: all of them
+// when matchAll is set, otherwise at least one.
+func FilterHalls(query *gorm.DB, codes []string, matchAll bool) *gorm.DB {
+	if len(codes) == 0 {
+		return query
+	}
+
+	matching := query.Session(&gorm.Session{NewDB: true}).
+		Table(models.HallAmenity{}.TableName()+" AS ha").
+		Select("ha.hall_id").
+		Joins("JOIN "+models.Amenity{}.TableName()+" AS a ON a.id = ha.amenity_id").
+		Where("a.code IN ?", codes)
+	if matchAll {
+		matching = matching.Group("ha.hall_id").Having("COUNT(DISTINCT a.id) = ?", len(codes))
+	}
+
+	return query.Where(models.Hall{}.TableName()+".id IN (?)", matching)
+}
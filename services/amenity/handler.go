@@ -0,0 +1,147 @@
+package amenity
+
+import (
+	"net/http"
+	"regexp"
+	"storage/configuration"
+	"storage/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var codePattern = regexp.MustCompile(`^[a-z0-9_]{1,50}$`)
+
+// GetAmenities lists the amenity catalog.
+func GetAmenities(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var amenities []models.Amenity
+		if err := conf.Db.Order("name asc").Find(&amenities).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve amenities"})
+			return
+		}
+		c.JSON(http.StatusOK, amenities)
+	}
+}
+
+// CreateAmenity adds an amenity to the catalog.
+func CreateAmenity(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var amenity models.Amenity
+		if err := c.ShouldBindJSON(&amenity); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		amenity.ID = 0
+		amenity.Code = strings.ToLower(strings.TrimSpace(amenity.Code))
+		amenity.Name = strings.TrimSpace(amenity.Name)
+		if !codePattern.MatchString(amenity.Code) || amenity.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Code (lowercase letters, digits and underscores) and name are required"})
+			return
+		}
+
+		var count int64
+		conf.Db.Model(&models.Amenity{}).Where("code = ?", amenity.Code).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Amenity code already exists"})
+			return
+		}
+
+		if err := conf.Db.Create(&amenity).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create amenity"})
+			return
+		}
+		c.JSON(http.StatusOK, amenity)
+	}
+}
+
+// UpdateAmenity renames an amenity. Codes are kept stable because clients filter by them.
+func UpdateAmenity(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name string `json:"name" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var amenity models.Amenity
+		if err := conf.Db.First(&amenity, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Amenity not found"})
+			return
+		}
+
+		amenity.Name = strings.TrimSpace(req.Name)
+		if err := conf.Db.Model(&amenity).Update("name", amenity.Name).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update amenity"})
+			return
+		}
+		c.JSON(http.StatusOK, amenity)
+	}
+}
+
+// DeleteAmenity removes an amenity from the catalog and from every hall offering it.
+func DeleteAmenity(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var amenity models.Amenity
+		if err := conf.Db.First(&amenity, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Amenity not found"})
+			return
+		}
+
+		err := conf.Db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("amenity_id = ?", amenity.ID).Delete(&models.HallAmenity{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&amenity).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete amenity"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Amenity deleted successfully"})
+	}
+}
+
+// SetHallAmenities replaces the amenities of a hall.
+func SetHallAmenities(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Codes []string `json:"codes"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var hall models.Hall
+		if err := conf.Db.First(&hall, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+
+		amenities, err := Resolve(conf.Db, ParseCodes(strings.Join(req.Codes, ",")))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = conf.Db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&hall).Association("Amenities").Replace(amenities); err != nil {
+				return err
+			}
+			return tx.Model(&models.Hall{}).Where("id = ?", hall.ID).Update("version", gorm.Expr("version + 1")).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update hall amenities"})
+			return
+		}
+
+		if amenities == nil {
+			amenities = []models.Amenity{}
+		}
+		c.JSON(http.StatusOK, amenities)
+	}
+}
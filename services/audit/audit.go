@@ -0,0 +1,43 @@
+package audit
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Types of authentication events.
+const (
+	EventAccountLocked   = "account_locked"
+	EventAccountUnlocked = "account_unlocked"
+	EventTwoFactorOn     = "two_factor_enabled"
+	EventTwoFactorOff    = "two_factor_disabled"
+	EventTwoFactorReset  = "two_factor_reset" // Removed by an admin, e.g. after a lost phone
+	EventDeactivated     = "account_deactivated"
+	EventReactivated     = "account_reactivated"
+)
+
+// AuthEvent is an entry of the authentication audit log.
+type AuthEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Type      string    `gorm:"not null;size:50;index" json:"type"`
+	Username  string    `gorm:"size:50;index" json:"username"`
+	UserID    *int64    `gorm:"index" json:"user_id,omitempty"` // Unset for usernames without an account
+	ActorID   *int64    `json:"actor_id,omitempty"`             // Admin who caused the event, if any
+	IP        string    `gorm:"size:64" json:"ip,omitempty"`
+	Detail    string    `gorm:"size:255" json:"detail,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func (AuthEvent) TableName() string {
+	return "hall_res_project.auth_audit_log"
+}
+
+// Record writes an event to the audit log. Failures are logged rather than returned,
+// so that auditing never blocks a login.
+func Record(db *gorm.DB, event AuthEvent) {
+	if err := db.Create(&event).Error; err != nil {
+		log.Printf("Failed to write auth audit event %s for %q: %v", event.Type, event.Username, err)
+	}
+}
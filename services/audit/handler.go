@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"net/http"
+	"storage/configuration"
+	"storage/services/auth"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAuthEvents lists the newest audit events, optionally filtered by username and type.
+func GetAuthEvents(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := 100
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 1000 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+				return
+			}
+			limit = n
+		}
+
+		query := conf.Db.Order("created_at desc, id desc").Limit(limit)
+		if username := c.Query("username"); username != "" {
+			query = query.Where("username = ?", strings.ToLower(username))
+		}
+		if eventType := c.Query("type"); eventType != "" {
+			query = query.Where("type = ?", eventType)
+		}
+
+		var events []AuthEvent
+		if err := query.Find(&events).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+			return
+		}
+		c.JSON(http.StatusOK, events)
+	}
+}
+
+// GetMyLogins lists the recent logins of the authenticated user.
+func GetMyLogins(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := auth.CurrentPrincipal(c)
+		respondLogins(c, conf, principal.UserID)
+	}
+}
+
+// GetUserLogins lists the recent logins of a user.
+func GetUserLogins(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		respondLogins(c, conf, userID)
+	}
+}
+
+func respondLogins(c *gin.Context, conf *configuration.Dependencies, userID int64) {
+	var records []LoginRecord
+	if err := conf.Db.Where("user_id = ?", userID).Order("created_at desc, id desc").Limit(100).Find(&records).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve login history"})
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
@@ -0,0 +1,33 @@
+package audit
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoginRecord is an entry of a user's login history, written for every successful login.
+type LoginRecord struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    int64     `gorm:"not null;index" json:"user_id"`
+	SessionID string    `gorm:"size:32" json:"session_id"`
+	IP        string    `gorm:"size:64" json:"ip"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func (LoginRecord) TableName() string {
+	return "hall_res_project.login_history"
+}
+
+// RecordLogin adds a login to the history. Failures are logged rather than returned,
+// so that bookkeeping never blocks a login.
+func RecordLogin(db *gorm.DB, record LoginRecord) {
+	if len(record.UserAgent) > 255 {
+		record.UserAgent = record.UserAgent[:255]
+	}
+	if err := db.Create(&record).Error; err != nil {
+		log.Printf("Failed to record login of user %d: %v", record.UserID, err)
+	}
+}
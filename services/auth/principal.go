@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey is the gin context key under which AuthMiddleware stores the principal.
+const principalKey = "auth.principal"
+
+// Principal is the authenticated user a request is made on behalf of.
+type Principal struct {
+	UserID    int64
+	Username  string
+	Roles     []string
+	SessionID string
+}
+
+// HasRole reports whether the principal holds a role, compared case-insensitively.
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin reports whether the principal holds the admin role.
+func (p *Principal) IsAdmin() bool {
+	return p.HasRole("admin")
+}
+
+// SetPrincipal stores the authenticated principal on the request context.
+func SetPrincipal(c *gin.Context, p *Principal) {
+	c.Set(principalKey, p)
+}
+
+// CurrentPrincipal returns the authenticated principal of the request.
+// It is the only way handlers should learn who is calling.
+func CurrentPrincipal(c *gin.Context) (*Principal, bool) {
+	value, exists := c.Get(principalKey)
+	if !exists {
+		return nil, false
+	}
+	p, ok := value.(*Principal)
+	return p, ok && p != nil
+}
@@ -0,0 +1,49 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Info describes a stored blob.
+type Info struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Store is a flat key/value store for files such as receipts and hall images.
+// Keys use forward slashes, e.g. "receipts/receipt_12.txt".
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get opens a blob for reading. The caller must close it. Missing blobs return ErrNotFound.
+	Get(ctx context.Context, key string) (io.ReadCloser, Info, error)
+	// Delete removes a blob. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns all blobs whose key starts with prefix.
+	List(ctx context.Context, prefix string) ([]Info, error)
+}
+
+// CleanKey validates a key and returns it in canonical form. Keys must be relative and
+// must not escape their prefix, so untrusted input can never reach outside the store.
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, "\\\x00") || strings.HasPrefix(key, "/") {
+		return "", ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." || part == "." || part == "" {
+			return "", ErrInvalidKey
+		}
+	}
+	return path.Clean(key), nil
+}
@@ -0,0 +1,115 @@
+package hall
+
+import (
+	"errors"
+	"math"
+	"storage/configuration"
+	"storage/models"
+	"storage/services/amenity"
+	"storage/services/site"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// hallQuery builds the query shared by the hall search endpoints, with the
+// associations preloaded and these optional filters applied:
+//
+//	amenities=wifi,stage   amenities_match=all (default) or any
+//	site_id, building_id
+//	min_capacity, max_capacity
+//	min_price, max_price   (cost per day)
+//	min_rating             (average of the visible reviews, 1 to 5)
+//	include_archived=true  (archived halls are left out by default)
+//
+// and sorted by sort_by (rating, reviews, price or capacity) in order asc or desc.
+func hallQuery(c *gin.Context, conf *configuration.Dependencies) (*gorm.DB, error) {
+	query := conf.Db.Preload("Reservations", models.NotCancelled).Preload("HallImages", orderImages).Preload("Amenities").
+		Preload("Floor.Building.Site")
+	column := func(name string) string { return models.Hall{}.TableName() + "." + name }
+
+	if c.Query("include_archived") != "true" {
+		query = query.Where(column("archived_at") + " IS NULL")
+	}
+
+	siteID, err := optionalID(c, "site_id")
+	if err != nil {
+		return nil, errors.New("invalid site_id")
+	}
+	buildingID, err := optionalID(c, "building_id")
+	if err != nil {
+		return nil, errors.New("invalid building_id")
+	}
+	if siteID != 0 || buildingID != 0 {
+		query = query.Where(column("id")+" IN (?)", site.HallIDs(conf.Db, siteID, buildingID))
+	}
+
+	if codes := amenity.ParseCodes(c.Query("amenities")); len(codes) > 0 {
+		match := c.DefaultQuery("amenities_match", "all")
+		if match != "all" && match != "any" {
+			return nil, errors.New("amenities_match must be all or any")
+		}
+		query = amenity.FilterHalls(query, codes, match == "all")
+	}
+
+	bounds := []struct {
+		param, condition string
+		integer          bool
+	}{
+		{"min_capacity", column("capacity") + " >= ?", true},
+		{"max_capacity", column("capacity") + " <= ?", true},
+		{"min_price", column("cost_per_day") + " >= ?", false},
+		{"max_price", column("cost_per_day") + " <= ?", false},
+		{"min_rating", column("average_rating") + " >= ?", false},
+	}
+	for _, b := range bounds {
+		value := c.Query(b.param)
+		if value == "" {
+			continue
+		}
+		if b.integer {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, errors.New("invalid " + b.param)
+			}
+			query = query.Where(b.condition, n)
+		} else {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil || f < 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+				return nil, errors.New("invalid " + b.param)
+			}
+			query = query.Where(b.condition, f)
+		}
+	}
+
+	if sortBy := c.Query("sort_by"); sortBy != "" {
+		sortColumns := map[string]string{
+			"rating":   "average_rating",
+			"reviews":  "review_count",
+			"price":    "cost_per_day",
+			"capacity": "capacity",
+		}
+		sortColumn, ok := sortColumns[sortBy]
+		if !ok {
+			return nil, errors.New("sort_by must be rating, reviews, price or capacity")
+		}
+		order := c.DefaultQuery("order", "desc")
+		if order != "asc" && order != "desc" {
+			return nil, errors.New("order must be asc or desc")
+		}
+		query = query.Order(column(sortColumn) + " " + order).Order(column("id"))
+	}
+
+	return query, nil
+}
+
+// optionalID parses an optional numeric query parameter, returning 0 when it is absent.
+func optionalID(c *gin.Context, name string) (uint, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	return uint(id), err
+}
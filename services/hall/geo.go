@@ -0,0 +1,136 @@
+package hall
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"storage/configuration"
+	"storage/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	earthRadiusKm   = 6371.0
+	defaultRadiusKm = 10.0
+	maxRadiusKm     = 500.0
+)
+
+// Haversine returns the great-circle distance in kilometres between two coordinates given in degrees.
+func Haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// ValidateCoordinates checks that a hall has either both coordinates or neither, within range.
+// NaN, which strconv.ParseFloat accepts and every range comparison lets through, is rejected.
+func ValidateCoordinates(lat, lng *float64) error {
+	if (lat == nil) != (lng == nil) {
+		return errors.New("latitude and longitude must be set together")
+	}
+	if lat == nil {
+		return nil
+	}
+	if math.IsNaN(*lat) || *lat < -90 || *lat > 90 {
+		return errors.New("latitude must be between -90 and 90")
+	}
+	if math.IsNaN(*lng) || *lng < -180 || *lng > 180 {
+		return errors.New("longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+// NearbyHalls returns the halls within radius_km (default 10) of lat/lng, nearest first.
+// It accepts the same filters as GetHalls.
+func NearbyHalls(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+		lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+		if errLat != nil || errLng != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lng are required"})
+			return
+		}
+		if err := ValidateCoordinates(&lat, &lng); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		radius := defaultRadiusKm
+		if r := c.Query("radius_km"); r != "" {
+			var err error
+			radius, err = strconv.ParseFloat(r, 64)
+			if err != nil || math.IsNaN(radius) || radius <= 0 || radius > maxRadiusKm {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "radius_km must be between 0 and 500"})
+				return
+			}
+		}
+
+		query, err := hallQuery(c, conf)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Narrow the candidates down with a bounding box the index can serve;
+		// the exact distance is checked afterwards.
+		minLat, maxLat, minLng, maxLng := boundingBox(lat, lng, radius)
+		table := models.Hall{}.TableName()
+		query = query.Where(table+".latitude BETWEEN ? AND ?", minLat, maxLat)
+		if minLng <= maxLng {
+			query = query.Where(table+".longitude BETWEEN ? AND ?", minLng, maxLng)
+		} else {
+			// The box crosses the antimeridian.
+			query = query.Where("("+table+".longitude >= ? OR "+table+".longitude <= ?)", minLng, maxLng)
+		}
+
+		var candidates []models.Hall
+		if err := query.Find(&candidates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve halls"})
+			return
+		}
+
+		halls := make([]models.Hall, 0, len(candidates))
+		for _, h := range candidates {
+			if h.Latitude == nil || h.Longitude == nil {
+				continue
+			}
+			distance := Haversine(lat, lng, *h.Latitude, *h.Longitude)
+			if distance > radius {
+				continue
+			}
+			h.DistanceKm = &distance
+			setImageURLs(&h)
+			halls = append(halls, h)
+		}
+		sort.SliceStable(halls, func(i, j int) bool { return *halls[i].DistanceKm < *halls[j].DistanceKm })
+
+		c.JSON(http.StatusOK, halls)
+	}
+}
+
+// boundingBox returns the latitude and longitude range that contains every point
+// within radius kilometres of lat/lng. Near the poles it covers all longitudes.
+func boundingBox(lat, lng, radius float64) (minLat, maxLat, minLng, maxLng float64) {
+	delta := radius / earthRadiusKm * 180 / math.Pi
+	minLat, maxLat = lat-delta, lat+delta
+	if minLat <= -90 || maxLat >= 90 {
+		return math.Max(minLat, -90), math.Min(maxLat, 90), -180, 180
+	}
+
+	lngDelta := math.Asin(math.Sin(radius/earthRadiusKm)/math.Cos(lat*math.Pi/180)) * 180 / math.Pi
+	minLng, maxLng = lng-lngDelta, lng+lngDelta
+	if minLng < -180 {
+		minLng += 360
+	}
+	if maxLng > 180 {
+		maxLng -= 360
+	}
+	return minLat, maxLat, minLng, maxLng
+}
@@ -0,0 +1,102 @@
+package hall
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHaversine(t *testing.T) {
+	const degree = earthRadiusKm * math.Pi / 180 // one degree of a great circle, about 111.19 km
+
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"same point", 42.6977, 23.3219, 42.6977, 23.3219, 0},
+		{"one degree along the equator", 0, 10, 0, 11, degree},
+		{"one degree along a meridian", 10, 30, 11, 30, degree},
+		{"across the antimeridian", 0, 179.5, 0, -179.5, degree},
+		{"pole to pole", 90, 0, -90, 0, 180 * degree},
+		{"at the pole longitude is irrelevant", 90, 0, 90, 120, 0},
+		{"antipodes", 0, 0, 0, 180, 180 * degree},
+		{"Sofia to Plovdiv", 42.6977, 23.3219, 42.1354, 24.7453, 132.5},
+	}
+
+	for _, tt := range tests {
+		if got := Haversine(tt.lat1, tt.lng1, tt.lat2, tt.lng2); math.Abs(got-tt.want) > 0.1 {
+			t.Errorf("%s: Haversine() = %.3f km, want %.3f km", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBoundingBox(t *testing.T) {
+	tests := []struct {
+		name          string
+		lat, lng      float64
+		radius        float64
+		fullLongitude bool
+		wraps         bool
+	}{
+		{"mid latitude", 42.7, 23.3, 10, false, false},
+		{"equator", 0, 0, 500, false, false},
+		{"east of the antimeridian", -17.7, 179.9, 50, false, true},
+		{"west of the antimeridian", 65.0, -179.8, 50, false, true},
+		{"near the north pole", 89.95, 10, 10, true, false},
+		{"at the south pole", -90, 0, 1, true, false},
+	}
+
+	for _, tt := range tests {
+		minLat, maxLat, minLng, maxLng := boundingBox(tt.lat, tt.lng, tt.radius)
+		if minLat < -90 || maxLat > 90 || minLat > tt.lat || maxLat < tt.lat {
+			t.Errorf("%s: latitude range [%v, %v]", tt.name, minLat, maxLat)
+		}
+		if full := minLng == -180 && maxLng == 180; full != tt.fullLongitude {
+			t.Errorf("%s: longitude range [%v, %v], full = %v, want %v", tt.name, minLng, maxLng, full, tt.fullLongitude)
+		}
+		if wraps := minLng > maxLng; wraps != tt.wraps {
+			t.Errorf("%s: longitude range [%v, %v], wraps = %v, want %v", tt.name, minLng, maxLng, wraps, tt.wraps)
+		}
+
+		// Every point on the circle must fall inside the box.
+		for bearing := 0.0; bearing < 360; bearing += 5 {
+			lat, lng := destination(tt.lat, tt.lng, tt.radius*0.999, bearing)
+			inLng := lng >= minLng && lng <= maxLng
+			if minLng > maxLng {
+				inLng = lng >= minLng || lng <= maxLng
+			}
+			if lat < minLat || lat > maxLat || !inLng {
+				t.Errorf("%s: point %.4f,%.4f at bearing %v is outside [%v, %v] x [%v, %v]",
+					tt.name, lat, lng, bearing, minLat, maxLat, minLng, maxLng)
+				break
+			}
+		}
+	}
+}
+
+func TestValidateCoordinatesRejectsNonNumbers(t *testing.T) {
+	valid := 42.0
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		v := v
+		if ValidateCoordinates(&v, &valid) == nil {
+			t.Errorf("latitude %v accepted", v)
+		}
+		if ValidateCoordinates(&valid, &v) == nil {
+			t.Errorf("longitude %v accepted", v)
+		}
+	}
+	if err := ValidateCoordinates(&valid, &valid); err != nil {
+		t.Errorf("ValidateCoordinates(42, 42) = %v", err)
+	}
+}
+
+// destination returns the point distance kilometres from lat/lng along the given bearing in degrees.
+func destination(lat, lng, distance, bearing float64) (float64, float64) {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	phi, lambda, theta, delta := toRad(lat), toRad(lng), toRad(bearing), distance/earthRadiusKm
+
+	phi2 := math.Asin(math.Sin(phi)*math.Cos(delta) + math.Cos(phi)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi), math.Cos(delta)-math.Sin(phi)*math.Sin(phi2))
+	lng2 := math.Mod(lambda2*180/math.Pi+540, 360) - 180
+	return phi2 * 180 / math.Pi, lng2
+}
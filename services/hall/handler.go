@@ -2,13 +2,19 @@ package hall
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"net/http"
-	"os"
 	"path/filepath"
 	"storage/configuration"
 	"storage/models"
+	"storage/services/amenity"
+	"storage/services/blob"
+	"storage/services/imaging"
+	"storage/services/reservation"
+	"strings"
 	"time"
 )
 
@@ -34,20 +40,29 @@ func CreateHall(conf *configuration.Dependencies) gin.HandlerFunc {
 			return
 		}
 
-		if hall.Capacity <= 0 || hall.CostPerDay <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Capacity and cost must be positive numbers"})
+		// Fields maintained by the server are never taken from the request.
+		hall.ArchivedAt = nil
+		hall.Version = 0
+		hall.AverageRating, hall.ReviewCount = 0, 0
+
+		if err := validateHall(conf.Db, &hall, nil); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		if !hall.AvailableFrom.IsZero() && !hall.AvailableTo.IsZero() {
-			if !hall.AvailableFrom.Before(hall.AvailableTo) {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "AvailableFrom must be before AvailableTo"})
-				return
-			}
-			if hall.AvailableFrom.Before(time.Now()) {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "AvailableFrom cannot be in the past"})
-				return
-			}
+		amenities, err := amenity.Resolve(conf.Db, amenity.ParseCodes(strings.Join(hall.AmenityCodes, ",")))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		hall.Amenities = amenities
+		hall.AmenityCodes = nil
+
+		// Validate every image before anything is stored, so a bad upload leaves no partial hall behind.
+		uploads, err := processUploads(form, conf.Cfg.Images)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
 		if err := conf.Db.Create(&hall).Error; err != nil {
@@ -55,57 +70,67 @@ func CreateHall(conf *configuration.Dependencies) gin.HandlerFunc {
 			return
 		}
 
-		files := form.File["images"]
-		for _, file := range files {
-			filename := fmt.Sprintf("%d_%s", hall.ID, file.Filename)
-
-			if err := c.SaveUploadedFile(file, "uploads/"+filename); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
-				return
-			}
-
-			image := models.HallImage{
-				HallID:    hall.ID,
-				ImageName: filename,
-			}
-
-			if err := conf.Db.Create(&image).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image data"})
-				return
-			}
+		if _, err := storeImages(c.Request.Context(), conf, hall.ID, uploads); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save images"})
+			return
 		}
 
 		c.JSON(http.StatusOK, hall)
 	}
 }
 
-func ServeImage() gin.HandlerFunc {
+// ServeImage streams a hall image from storage. The "variant" query parameter selects
+// thumbnail, medium or original (the default). Images are immutable once stored, so
+// responses carry an ETag and can be cached by the client.
+func ServeImage(conf *configuration.Dependencies) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		path := c.Param("path")
+		if !imaging.ValidName(path) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image name"})
+			return
+		}
 
-		imagePath := filepath.Join("uploads/", path)
+		variant, ok := imaging.VariantByName(c.Query("variant"))
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Variant must be thumbnail, medium or original"})
+			return
+		}
 
-		if _, err := os.Stat(imagePath); os.IsNotExist(err) {
+		reader, info, err := conf.Storage.Get(c.Request.Context(), ImageKey(variant.FileName(path)))
+		if errors.Is(err, blob.ErrNotFound) && variant != imaging.VariantOriginal {
+			// Images uploaded before variants existed only have the original.
+			reader, info, err = conf.Storage.Get(c.Request.Context(), ImageKey(path))
+		}
+		if errors.Is(err, blob.ErrNotFound) || errors.Is(err, blob.ErrInvalidKey) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
 			return
 		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read image"})
+			return
+		}
+		defer reader.Close()
 
-		ext := filepath.Ext(path)
-		var contentType string
-		switch ext {
-		case ".jpg", ".jpeg":
-			contentType = "image/jpeg"
-		case ".png":
-			contentType = "image/png"
-		case ".gif":
-			contentType = "image/gif"
-		default:
-			contentType = "application/octet-stream"
+		etag := imageETag(info)
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", "private, max-age=86400")
+		if !info.ModTime.IsZero() {
+			c.Header("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+		}
+		if match := c.GetHeader("If-None-Match"); match != "" && etagMatches(match, etag) {
+			c.Status(http.StatusNotModified)
+			return
 		}
 
-		c.Header("Content-Type", contentType)
+		contentType := "application/octet-stream"
+		if format, ok := imaging.FormatByExtension(filepath.Ext(path)); ok {
+			contentType = format.MIME
+		}
 
-		c.File(imagePath)
+		// Never let browsers sniff stored files into something executable.
+		c.DataFromReader(http.StatusOK, info.Size, contentType, reader, map[string]string{
+			"X-Content-Type-Options": "nosniff",
+		})
 	}
 }
 
@@ -147,21 +172,24 @@ func CreateHall_old(conf *configuration.Dependencies) gin.HandlerFunc {
 }
 
 // GetHalls retrieves all available halls.
+// Optional filters: amenities=wifi,stage with amenities_match=all (default) or any,
+// site_id, building_id, min_capacity, max_capacity, min_price and max_price.
 func GetHalls(conf *configuration.Dependencies) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var halls []models.Hall
+		query, err := hallQuery(c, conf)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		if err := conf.Db.Preload("Reservations").Preload("HallImages").Find(&halls).Error; err != nil {
+		var halls []models.Hall
+		if err := query.Find(&halls).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve halls"})
 			return
 		}
 
 		for i := range halls {
-			var imagePaths []string
-			for _, image := range halls[i].HallImages {
-				imagePaths = append(imagePaths, image.ImageName)
-			}
-			halls[i].ImageURLs = imagePaths
+			setImageURLs(&halls[i])
 		}
 
 		c.JSON(http.StatusOK, halls)
@@ -179,37 +207,146 @@ func GetHalls_old(conf *configuration.Dependencies) gin.HandlerFunc {
 	}
 }
 
-// UpdateHall modifies an existing hall.
-func UpdateHall(conf *configuration.Dependencies) gin.HandlerFunc {
+// DeleteHall archives a hall. The hall, its images and its reservations are kept for
+// historical reports, but it no longer shows up in search and cannot be booked. The images
+// of archived halls are removed from storage by `cleanup --archived-before`.
+// If the hall still has upcoming reservations, the request must carry a plan for them:
+//
+//	{"plan": "cancel"} or {"plan": "reassign", "target_hall_id": 7}
+//
+// and the hall is only archived once every one of them has been handled.
+func DeleteHall(conf *configuration.Dependencies) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-
 		var hall models.Hall
-		if err := conf.Db.First(&hall, id).Error; err != nil {
+		if err := conf.Db.First(&hall, c.Param("id")).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
 			return
 		}
+		if hall.ArchivedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Hall is already archived"})
+			return
+		}
 
-		if err := c.ShouldBindJSON(&hall); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		var req struct {
+			Plan         string `json:"plan"`
+			TargetHallID uint   `json:"target_hall_id"`
+			Message      string `json:"message"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+				return
+			}
+		}
+		if req.Plan != "" && req.Plan != "cancel" && req.Plan != "reassign" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Plan must be cancel or reassign"})
+			return
+		}
+		if req.Plan == "reassign" && (req.TargetHallID == 0 || req.TargetHallID == hall.ID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target_hall_id must name another hall"})
 			return
 		}
 
-		conf.Db.Save(&hall)
-		c.JSON(http.StatusOK, hall)
+		var upcoming []models.Reservation
+		if err := conf.Db.Scopes(models.NotCancelled).Where("hall_id = ? AND end_date > ?", hall.ID, time.Now()).
+			Order("start_date asc").Find(&upcoming).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reservations"})
+			return
+		}
+
+		if len(upcoming) > 0 {
+			if req.Plan == "" {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":                 "Hall has upcoming reservations; supply a cancel or reassign plan",
+					"upcoming_reservations": upcoming,
+				})
+				return
+			}
+
+			var failed []gin.H
+			for i := range upcoming {
+				r := &upcoming[i]
+				var err error
+				if req.Plan == "cancel" {
+					err = reservation.Cancel(c.Request.Context(), conf, r, archiveNotice(req.Message,
+						"Your reservation from %s to %s was cancelled because the hall is no longer in use.", r))
+				} else {
+					err = reservation.Move(conf, r, req.TargetHallID, archiveNotice(req.Message,
+						"Your reservation from %s to %s was moved to another hall because the original hall is no longer in use.", r))
+				}
+				if err != nil {
+					failed = append(failed, gin.H{"reservation_id": r.ID, "error": err.Error()})
+				}
+			}
+			if len(failed) > 0 {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":  "Some reservations could not be handled; the hall was not archived",
+					"failed": failed,
+				})
+				return
+			}
+		}
+
+		now := time.Now()
+		if err := conf.Db.Model(&hall).Updates(map[string]interface{}{
+			"archived_at": now,
+			"version":     gorm.Expr("version + 1"),
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive hall"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Hall archived successfully", "handled_reservations": len(upcoming)})
 	}
 }
 
-// DeleteHall removes a hall.
-func DeleteHall(conf *configuration.Dependencies) gin.HandlerFunc {
+// RestoreHall brings an archived hall back into search and booking.
+func RestoreHall(conf *configuration.Dependencies) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
+		var hall models.Hall
+		if err := conf.Db.First(&hall, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+		if hall.ArchivedAt == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Hall is not archived"})
+			return
+		}
 
-		if err := conf.Db.Delete(&models.Hall{}, id).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete hall"})
+		if err := conf.Db.Model(&hall).Updates(map[string]interface{}{
+			"archived_at": nil,
+			"version":     gorm.Expr("version + 1"),
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore hall"})
 			return
 		}
+		hall.ArchivedAt = nil
+		hall.Version++
+		c.JSON(http.StatusOK, hall)
+	}
+}
+
+func archiveNotice(custom, format string, r *models.Reservation) string {
+	if custom != "" {
+		return custom
+	}
+	return fmt.Sprintf(format, r.StartDate.Format("2006-01-02"), r.EndDate.Format("2006-01-02"))
+}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Hall deleted successfully"})
+// checkFloor makes sure the floor a hall is placed on exists. The floor itself is never
+// written through the hall, only the reference to it.
+func checkFloor(db *gorm.DB, hall *models.Hall) error {
+	hall.Floor = nil
+	if hall.FloorID == nil {
+		return nil
+	}
+
+	var count int64
+	if err := db.Model(&models.Floor{}).Where("id = ?", *hall.FloorID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("floor not found")
 	}
+	return nil
 }
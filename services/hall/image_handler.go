@@ -0,0 +1,212 @@
+package hall
+
+import (
+	"errors"
+	"net/http"
+	"storage/configuration"
+	"storage/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetHallImages lists the images of a hall in display order.
+func GetHallImages(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var hall models.Hall
+		if err := conf.Db.Preload("HallImages", orderImages).First(&hall, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+
+		setImageURLs(&hall)
+		c.JSON(http.StatusOK, hall.HallImages)
+	}
+}
+
+// AddHallImages uploads more images to an existing hall.
+func AddHallImages(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var hall models.Hall
+		if err := conf.Db.First(&hall, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+
+		form, err := c.MultipartForm()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse form"})
+			return
+		}
+		if len(form.File["images"]) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No images uploaded"})
+			return
+		}
+
+		uploads, err := processUploads(form, conf.Cfg.Images)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		images, err := storeImages(c.Request.Context(), conf, hall.ID, uploads)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save images"})
+			return
+		}
+
+		c.JSON(http.StatusOK, images)
+	}
+}
+
+// UpdateHallImage changes the caption and alt text of a hall image.
+func UpdateHallImage(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Caption *string `json:"caption" binding:"omitempty,max=255"`
+			AltText *string `json:"alt_text" binding:"omitempty,max=255"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var image models.HallImage
+		if err := conf.Db.Where("hall_id = ?", c.Param("id")).First(&image, c.Param("imageId")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+
+		if req.Caption != nil {
+			image.Caption = *req.Caption
+		}
+		if req.AltText != nil {
+			image.AltText = *req.AltText
+		}
+		if err := conf.Db.Model(&image).Select("caption", "alt_text").Updates(&image).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update image"})
+			return
+		}
+
+		image.URLs = ImageURLs(image.ImageName)
+		c.JSON(http.StatusOK, image)
+	}
+}
+
+// DeleteHallImage removes an image from a hall and from storage. If it was the cover,
+// the next image in display order becomes the cover.
+func DeleteHallImage(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var image models.HallImage
+		if err := conf.Db.Where("hall_id = ?", c.Param("id")).First(&image, c.Param("imageId")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+
+		err := conf.Db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&image).Error; err != nil {
+				return err
+			}
+			if !image.IsCover {
+				return nil
+			}
+
+			var next models.HallImage
+			err := orderImages(tx.Where("hall_id = ?", image.HallID)).First(&next).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			return tx.Model(&next).Update("is_cover", true).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+			return
+		}
+
+		if err := deleteImage(c.Request.Context(), conf.Storage, image.ImageName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Image removed from hall, but failed to delete the file"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
+	}
+}
+
+// ReorderHallImages sets the display order of a hall's images. The request must list
+// every image of the hall exactly once.
+func ReorderHallImages(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			ImageIDs []uint `json:"image_ids" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var images []models.HallImage
+		if err := conf.Db.Where("hall_id = ?", c.Param("id")).Find(&images).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve images"})
+			return
+		}
+
+		remaining := make(map[uint]bool, len(images))
+		for _, img := range images {
+			remaining[img.ID] = true
+		}
+		for _, id := range req.ImageIDs {
+			if !remaining[id] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Image IDs must list every image of the hall exactly once"})
+				return
+			}
+			delete(remaining, id)
+		}
+		if len(remaining) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Image IDs must list every image of the hall exactly once"})
+			return
+		}
+
+		err := conf.Db.Transaction(func(tx *gorm.DB) error {
+			for position, id := range req.ImageIDs {
+				if err := tx.Model(&models.HallImage{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder images"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Images reordered successfully"})
+	}
+}
+
+// SetHallCoverImage makes an image the cover of its hall.
+func SetHallCoverImage(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var image models.HallImage
+		if err := conf.Db.Where("hall_id = ?", c.Param("id")).First(&image, c.Param("imageId")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+
+		err := conf.Db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.HallImage{}).Where("hall_id = ? AND id <> ?", image.HallID, image.ID).Update("is_cover", false).Error; err != nil {
+				return err
+			}
+			return tx.Model(&image).Update("is_cover", true).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set cover image"})
+			return
+		}
+
+		image.URLs = ImageURLs(image.ImageName)
+		c.JSON(http.StatusOK, image)
+	}
+}
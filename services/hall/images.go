@@ -0,0 +1,202 @@
+package hall
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"storage/configuration"
+	"storage/models"
+	"storage/services/blob"
+	"storage/services/imaging"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ImagePrefix is the storage prefix under which hall images are kept.
+const ImagePrefix = "uploads/"
+
+// ImageKey returns the storage key of a hall image.
+func ImageKey(name string) string {
+	return ImagePrefix + name
+}
+
+// processUpload runs an uploaded file through the image pipeline.
+func processUpload(file *multipart.FileHeader, limits imaging.Limits) (*imaging.Image, error) {
+	if limits.MaxBytes > 0 && file.Size > limits.MaxBytes {
+		return nil, imaging.ErrTooLarge
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	return imaging.Process(src, file.Filename, limits)
+}
+
+// ImageURL is the path under which ServeImage serves a variant of a hall image.
+func ImageURL(name string, variant imaging.Variant) string {
+	u := "/api/halls/image/" + url.PathEscape(name)
+	if variant.MaxSize > 0 {
+		u += "?variant=" + variant.Name
+	}
+	return u
+}
+
+// ImageURLs lists the URLs of all variants of a hall image.
+func ImageURLs(name string) models.ImageVariants {
+	return models.ImageVariants{
+		Thumbnail: ImageURL(name, imaging.VariantThumbnail),
+		Medium:    ImageURL(name, imaging.VariantMedium),
+		Original:  ImageURL(name, imaging.VariantOriginal),
+	}
+}
+
+// saveImage stores a processed image and its resized variants under a server-generated name
+// and returns the name.
+func saveImage(ctx context.Context, store blob.Store, img *imaging.Image) (string, error) {
+	name, err := imaging.NewName(img.Format)
+	if err != nil {
+		return "", err
+	}
+
+	for _, variant := range imaging.Variants {
+		data, err := img.Render(variant)
+		if err != nil {
+			return "", err
+		}
+		if err := store.Put(ctx, ImageKey(variant.FileName(name)), bytes.NewReader(data), img.Format.MIME); err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// imageETag derives a strong ETag from the stored blob. Image names are random and never reused,
+// so the key, size and modification time identify the content.
+func imageETag(info blob.Info) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d", info.Key, info.Size, info.ModTime.UnixNano())))
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// etagMatches checks an If-None-Match header, which may list several tags or "*".
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// upload is a validated image together with the caption and alt text sent with it.
+type upload struct {
+	image   *imaging.Image
+	caption string
+	altText string
+}
+
+// processUploads validates the "images" files of a multipart form. Optional "captions" and
+// "alt_texts" values are matched to the files by position.
+func processUploads(form *multipart.Form, limits imaging.Limits) ([]upload, error) {
+	captions := form.Value["captions"]
+	altTexts := form.Value["alt_texts"]
+
+	var uploads []upload
+	for i, file := range form.File["images"] {
+		img, err := processUpload(file, limits)
+		if err != nil {
+			return nil, fmt.Errorf("image %q rejected: %v", file.Filename, err)
+		}
+
+		u := upload{image: img}
+		if i < len(captions) {
+			u.caption = captions[i]
+		}
+		if i < len(altTexts) {
+			u.altText = altTexts[i]
+		}
+		uploads = append(uploads, u)
+	}
+	return uploads, nil
+}
+
+// storeImages saves uploads after the hall's existing images. The first image of a hall
+// without a cover becomes the cover.
+func storeImages(ctx context.Context, conf *configuration.Dependencies, hallID uint, uploads []upload) ([]models.HallImage, error) {
+	var existing []models.HallImage
+	if err := conf.Db.Where("hall_id = ?", hallID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	position, hasCover := 0, false
+	for _, img := range existing {
+		if img.Position >= position {
+			position = img.Position + 1
+		}
+		hasCover = hasCover || img.IsCover
+	}
+
+	var saved []models.HallImage
+	for _, u := range uploads {
+		name, err := saveImage(ctx, conf.Storage, u.image)
+		if err != nil {
+			return saved, err
+		}
+
+		image := models.HallImage{
+			HallID:    hallID,
+			ImageName: name,
+			Caption:   u.caption,
+			AltText:   u.altText,
+			Position:  position,
+			IsCover:   !hasCover,
+		}
+		if err := conf.Db.Create(&image).Error; err != nil {
+			deleteImage(ctx, conf.Storage, name)
+			return saved, err
+		}
+
+		position++
+		hasCover = true
+		image.URLs = ImageURLs(name)
+		saved = append(saved, image)
+	}
+	return saved, nil
+}
+
+// deleteImage removes an image and all of its variants from storage.
+func deleteImage(ctx context.Context, store blob.Store, name string) error {
+	for _, variant := range imaging.Variants {
+		if err := store.Delete(ctx, ImageKey(variant.FileName(name))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderImages sorts preloaded hall images into display order.
+func orderImages(db *gorm.DB) *gorm.DB {
+	return db.Order("position asc, id asc")
+}
+
+// setImageURLs fills the image URL fields of a hall from its preloaded images.
+func setImageURLs(hall *models.Hall) {
+	hall.ImageURLs = nil
+	hall.CoverImage = nil
+	for i := range hall.HallImages {
+		image := &hall.HallImages[i]
+		image.URLs = ImageURLs(image.ImageName)
+		hall.ImageURLs = append(hall.ImageURLs, image.URLs)
+		if image.IsCover {
+			cover := image.URLs
+			hall.CoverImage = &cover
+		}
+	}
+}
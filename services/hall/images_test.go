@@ -0,0 +1,128 @@
+package hall
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"storage/configuration"
+	"storage/services/blob"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const secret = "db_password=hunter2"
+
+// imageStore lays out a local store holding one hall image and, outside the image
+// prefix, a file that must never be served.
+func imageStore(t *testing.T) *configuration.Dependencies {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "uploads"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "uploads", "hall.png"), []byte("\x89PNG\r\n\x1a\nimage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "config.json"), []byte(secret), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &configuration.Dependencies{Storage: blob.NewLocal(root)}
+}
+
+func TestServeImageThroughRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/image/:path", ServeImage(imageStore(t)))
+
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"/image/hall.png", http.StatusOK},
+		{"/image/missing.png", http.StatusNotFound},
+		{"/image/../config.json", http.StatusNotFound},
+		{"/image/..%2fconfig.json", http.StatusNotFound},
+		{"/image/%2e%2e%2fconfig.json", http.StatusNotFound},
+		{"/image/%2e%2e%5cconfig.json", http.StatusBadRequest},
+		{"/image/..%5cconfig.json", http.StatusBadRequest},
+		{"/image/%252e%252e%252fconfig.json", http.StatusBadRequest},
+		{"/image/%2fetc%2fpasswd", http.StatusNotFound},
+		{"/image/hall.png%00.txt", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if w.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.target, w.Code, tt.want)
+		}
+		if strings.Contains(w.Body.String(), secret) {
+			t.Errorf("GET %s leaked a file outside the image store", tt.target)
+		}
+	}
+}
+
+func TestServeImageRejectsUnsafeNames(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := ServeImage(imageStore(t))
+
+	// The names are passed straight to the handler, as a router that does not split on
+	// decoded slashes would.
+	names := []string{
+		"../config.json",
+		"..",
+		"..\\config.json",
+		"uploads/../../config.json",
+		"/config.json",
+		"/etc/passwd",
+		"C:\\config.json",
+		"..%2fconfig.json",
+		"%2e%2e%2fconfig.json",
+		"hall.png\x00",
+	}
+
+	for _, name := range names {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/image/x", nil)
+		c.Params = gin.Params{{Key: "path", Value: name}}
+
+		handler(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("ServeImage(%q) = %d, want %d", name, w.Code, http.StatusBadRequest)
+		}
+		if strings.Contains(w.Body.String(), secret) {
+			t.Errorf("ServeImage(%q) leaked a file outside the image store", name)
+		}
+	}
+}
+
+func TestServeImageCaching(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/image/:path", ServeImage(imageStore(t)))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/image/hall.png", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET = %d, want 200", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", got)
+	}
+	if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/image/hall.png", nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("conditional GET = %d, want 304", w.Code)
+	}
+}
@@ -0,0 +1,267 @@
+package hall
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"storage/configuration"
+	"storage/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// updatableColumns maps the JSON fields of a hall that may be changed by an update to their columns.
+// Everything else (id, version, archival, availability, associations) is managed by dedicated
+// endpoints; availability goes through PUT /halls/:id/availability, which checks the affected reservations.
+var updatableColumns = map[string]string{
+	"capacity":     "capacity",
+	"location":     "location",
+	"floor_id":     "floor_id",
+	"latitude":     "latitude",
+	"longitude":    "longitude",
+	"cost_per_day": "cost_per_day",
+}
+
+var (
+	// errVersionMismatch means the hall was changed by someone else since the client read it.
+	errVersionMismatch = errors.New("hall has been modified; reload it and retry")
+	// errHallArchived means the hall must be restored before it can be changed.
+	errHallArchived = errors.New("Hall is archived; restore it before changing it")
+)
+
+// GetHall returns one hall. The ETag header carries its version for use with If-Match.
+func GetHall(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var hall models.Hall
+		if err := conf.Db.Preload("HallImages", orderImages).Preload("Amenities").Preload("Floor.Building.Site").
+			First(&hall, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+		setImageURLs(&hall)
+
+		c.Header("ETag", versionETag(hall.Version))
+		c.JSON(http.StatusOK, hall)
+	}
+}
+
+// PatchHall applies a JSON Merge Patch (RFC 7396) to a hall. Only the fields in
+// updatableColumns may appear in the patch, and the result must pass the same
+// validation as a new hall. The request must carry the hall's ETag in If-Match.
+// If the price or capacity changes, the upcoming reservations of the hall are
+// listed so they can be reviewed.
+func PatchHall(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if contentType := c.ContentType(); contentType != "application/merge-patch+json" && contentType != "application/json" {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/merge-patch+json"})
+			return
+		}
+		ifMatch := c.GetHeader("If-Match")
+		if ifMatch == "" {
+			c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header with the hall's ETag is required"})
+			return
+		}
+
+		var previous models.Hall
+		if err := conf.Db.First(&previous, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+		if previous.ArchivedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": errHallArchived.Error()})
+			return
+		}
+		if !strongETagMatches(ifMatch, versionETag(previous.Version)) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": errVersionMismatch.Error()})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		var patch map[string]json.RawMessage
+		if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Patch must be a JSON object"})
+			return
+		}
+		for field := range patch {
+			if _, ok := updatableColumns[field]; !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Field %q cannot be patched", field)})
+				return
+			}
+		}
+
+		hall, err := mergePatch(previous, patch)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		fields := make([]string, 0, len(patch))
+		for field := range patch {
+			fields = append(fields, field)
+		}
+		respondUpdated(c, conf, &previous, &hall, fields)
+	}
+}
+
+// UpdateHall replaces the updatable fields of an existing hall with the request body.
+// If-Match is honoured when present.
+func UpdateHall(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var previous models.Hall
+		if err := conf.Db.First(&previous, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+		if previous.ArchivedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": errHallArchived.Error()})
+			return
+		}
+		if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && !strongETagMatches(ifMatch, versionETag(previous.Version)) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": errVersionMismatch.Error()})
+			return
+		}
+
+		hall := previous
+		if err := c.ShouldBindJSON(&hall); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		fields := make([]string, 0, len(updatableColumns))
+		for field := range updatableColumns {
+			fields = append(fields, field)
+		}
+		respondUpdated(c, conf, &previous, &hall, fields)
+	}
+}
+
+// mergePatch applies an RFC 7396 merge patch to the JSON form of a hall. The patch
+// holds only top-level scalar fields, so merging is a matter of replacing or, for
+// null, removing them.
+func mergePatch(hall models.Hall, patch map[string]json.RawMessage) (models.Hall, error) {
+	original, err := json.Marshal(hall)
+	if err != nil {
+		return hall, err
+	}
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(original, &document); err != nil {
+		return hall, err
+	}
+
+	for field, value := range patch {
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			delete(document, field)
+		} else {
+			document[field] = value
+		}
+	}
+
+	merged, err := json.Marshal(document)
+	if err != nil {
+		return hall, err
+	}
+	var result models.Hall
+	if err := json.Unmarshal(merged, &result); err != nil {
+		return hall, errors.New("Invalid value in patch")
+	}
+	return result, nil
+}
+
+// respondUpdated validates the updated hall, stores the given fields if the version still
+// matches and writes the response.
+func respondUpdated(c *gin.Context, conf *configuration.Dependencies, previous, hall *models.Hall, fields []string) {
+	hall.ID = previous.ID
+	hall.Version = previous.Version
+	hall.ArchivedAt = previous.ArchivedAt
+	hall.Available = previous.Available
+	hall.AvailableFrom = previous.AvailableFrom
+	hall.AvailableTo = previous.AvailableTo
+	if err := validateHall(conf.Db, hall, previous); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := saveHall(conf.Db, hall, fields)
+	if errors.Is(err, errVersionMismatch) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update hall"})
+		return
+	}
+
+	response := gin.H{"hall": hall}
+	if hall.CostPerDay != previous.CostPerDay || hall.Capacity != previous.Capacity {
+		var upcoming []models.Reservation
+		if err := conf.Db.Scopes(models.NotCancelled).Where("hall_id = ? AND end_date > ?", hall.ID, time.Now()).
+			Order("start_date asc").Find(&upcoming).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Hall updated, but failed to retrieve reservations"})
+			return
+		}
+		response["affected_reservations"] = upcoming
+	}
+
+	c.Header("ETag", versionETag(hall.Version))
+	c.JSON(http.StatusOK, response)
+}
+
+// saveHall writes the given JSON fields of the hall and bumps its version, provided
+// nobody else has updated or archived it in the meantime.
+func saveHall(db *gorm.DB, hall *models.Hall, fields []string) error {
+	values := map[string]interface{}{}
+	for _, field := range fields {
+		switch updatableColumns[field] {
+		case "capacity":
+			values["capacity"] = hall.Capacity
+		case "location":
+			values["location"] = hall.Location
+		case "floor_id":
+			values["floor_id"] = hall.FloorID
+		case "latitude":
+			values["latitude"] = hall.Latitude
+		case "longitude":
+			values["longitude"] = hall.Longitude
+		case "cost_per_day":
+			values["cost_per_day"] = hall.CostPerDay
+		}
+	}
+	values["version"] = gorm.Expr("version + 1")
+
+	result := db.Model(&models.Hall{}).Where("id = ? AND version = ? AND archived_at IS NULL", hall.ID, hall.Version).Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errVersionMismatch
+	}
+	hall.Version++
+	return nil
+}
+
+func versionETag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// strongETagMatches checks an If-Match header, which may list several tags or "*". If-Match
+// uses the strong comparison of RFC 7232, so weak tags never match.
+func strongETagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package hall
+
+import "testing"
+
+func TestStrongETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"3"`, true},
+		{`"2", "3"`, true},
+		{`*`, true},
+		{`W/"3"`, false},
+		{`"2", W/"3"`, false},
+		{`"4"`, false},
+	}
+
+	for _, tt := range tests {
+		if got := strongETagMatches(tt.header, versionETag(3)); got != tt.want {
+			t.Errorf("strongETagMatches(%s) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestAvailabilityIsNotUpdatable(t *testing.T) {
+	for _, field := range []string{"available", "available_from", "available_to", "archived_at", "version"} {
+		if _, ok := updatableColumns[field]; ok {
+			t.Errorf("%s can be changed through PATCH and PUT", field)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"storage/configuration"
 	"storage/models"
+	"storage/services/site"
 	"strconv"
 )
 
@@ -34,55 +35,23 @@ func GetHallUtilizationRate(conf *configuration.Dependencies) gin.HandlerFunc {
 			return
 		}
 
-		// Parse optional start_date and end_date query parameters.
-		var startDate, endDate time.Time
-		if s := c.Query("start_date"); s != "" {
-			startDate, err = time.Parse("2006-01-02", s)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format"})
-				return
-			}
-		} else {
-			// Default: 30 days ago.
-			startDate = time.Now().AddDate(0, 0, -30)
-		}
-		if e := c.Query("end_date"); e != "" {
-			endDate, err = time.Parse("2006-01-02", e)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format"})
-				return
-			}
-		} else {
-			// Default: today.
-			endDate = time.Now()
+		startDate, endDate, ok := utilizationPeriod(c)
+		if !ok {
+			return
 		}
 
-		totalDays := int(endDate.Sub(startDate).Hours()/24) + 1
+		totalDays := periodDays(startDate, endDate)
 
 		// Query reservations for this hall overlapping the period.
 		var reservations []models.Reservation
-		if err := conf.Db.
+		if err := conf.Db.Scopes(models.NotCancelled).
 			Where("hall_id = ? AND start_date <= ? AND end_date >= ?", hall.ID, endDate, startDate).
 			Find(&reservations).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reservations"})
 			return
 		}
 
-		// Compute booked days by summing overlaps.
-		bookedDays := 0
-		for _, r := range reservations {
-			overlapStart := r.StartDate
-			if startDate.After(overlapStart) {
-				overlapStart = startDate
-			}
-			overlapEnd := r.EndDate
-			if endDate.Before(overlapEnd) {
-				overlapEnd = endDate
-			}
-			days := int(overlapEnd.Sub(overlapStart).Hours()/24) + 1
-			bookedDays += days
-		}
-
+		bookedDays := bookedDays(reservations, startDate, endDate)
 		utilizationRate := (float64(bookedDays) / float64(totalDays)) * 100
 
 		c.JSON(http.StatusOK, gin.H{
@@ -94,3 +63,170 @@ func GetHallUtilizationRate(conf *configuration.Dependencies) gin.HandlerFunc {
 		})
 	}
 }
+
+// BuildingUtilization is the utilization of all halls in a building over a period.
+// Capacity is measured in hall-days: the number of halls times the days in the period.
+type BuildingUtilization struct {
+	BuildingID      uint    `json:"building_id"`
+	BuildingName    string  `json:"building_name"`
+	SiteID          uint    `json:"site_id"`
+	Halls           int     `json:"halls"`
+	TotalHallDays   int     `json:"total_hall_days"`
+	BookedDays      int     `json:"booked_days"`
+	UtilizationRate float64 `json:"utilization_rate"`
+}
+
+// GetBuildingUtilization rolls the utilization of every hall in a building up into one figure.
+func GetBuildingUtilization(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var building models.Building
+		if err := conf.Db.First(&building, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Building not found"})
+			return
+		}
+
+		startDate, endDate, ok := utilizationPeriod(c)
+		if !ok {
+			return
+		}
+
+		rollup, err := buildingUtilization(conf, []models.Building{building}, startDate, endDate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reservations"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"period":      gin.H{"start": startDate.Format("2006-01-02"), "end": endDate.Format("2006-01-02")},
+			"total_days":  periodDays(startDate, endDate),
+			"utilization": rollup[0],
+		})
+	}
+}
+
+// GetBuildingsUtilization lists the utilization of every building, optionally limited to one site.
+func GetBuildingsUtilization(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := conf.Db.Order("site_id asc, name asc")
+		if siteID := c.Query("site_id"); siteID != "" {
+			query = query.Where("site_id = ?", siteID)
+		}
+
+		var buildings []models.Building
+		if err := query.Find(&buildings).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve buildings"})
+			return
+		}
+
+		startDate, endDate, ok := utilizationPeriod(c)
+		if !ok {
+			return
+		}
+
+		rollup, err := buildingUtilization(conf, buildings, startDate, endDate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reservations"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"period":      gin.H{"start": startDate.Format("2006-01-02"), "end": endDate.Format("2006-01-02")},
+			"total_days":  periodDays(startDate, endDate),
+			"utilization": rollup,
+		})
+	}
+}
+
+func buildingUtilization(conf *configuration.Dependencies, buildings []models.Building, startDate, endDate time.Time) ([]BuildingUtilization, error) {
+	halls, err := site.BuildingHalls(conf.Db, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	rollup := make([]BuildingUtilization, len(buildings))
+	index := make(map[uint]int, len(buildings))
+	for i, b := range buildings {
+		rollup[i] = BuildingUtilization{BuildingID: b.ID, BuildingName: b.Name, SiteID: b.SiteID}
+		index[b.ID] = i
+	}
+
+	var hallIDs []uint
+	for hallID, buildingID := range halls {
+		if i, ok := index[buildingID]; ok {
+			rollup[i].Halls++
+			hallIDs = append(hallIDs, hallID)
+		}
+	}
+
+	var reservations []models.Reservation
+	if len(hallIDs) > 0 {
+		if err := conf.Db.Scopes(models.NotCancelled).
+			Where("hall_id IN ? AND start_date <= ? AND end_date >= ?", hallIDs, endDate, startDate).
+			Find(&reservations).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	for _, r := range reservations {
+		i := index[halls[r.HallID]]
+		rollup[i].BookedDays += bookedDays([]models.Reservation{r}, startDate, endDate)
+	}
+
+	days := periodDays(startDate, endDate)
+	for i := range rollup {
+		rollup[i].TotalHallDays = rollup[i].Halls * days
+		if rollup[i].TotalHallDays > 0 {
+			rollup[i].UtilizationRate = float64(rollup[i].BookedDays) / float64(rollup[i].TotalHallDays) * 100
+		}
+	}
+	return rollup, nil
+}
+
+// utilizationPeriod parses the optional start_date and end_date query parameters,
+// defaulting to the last 30 days. On error it writes the response and returns false.
+func utilizationPeriod(c *gin.Context) (time.Time, time.Time, bool) {
+	var startDate, endDate time.Time
+	var err error
+	if s := c.Query("start_date"); s != "" {
+		startDate, err = time.Parse("2006-01-02", s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format"})
+			return startDate, endDate, false
+		}
+	} else {
+		// Default: 30 days ago.
+		startDate = time.Now().AddDate(0, 0, -30)
+	}
+	if e := c.Query("end_date"); e != "" {
+		endDate, err = time.Parse("2006-01-02", e)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format"})
+			return startDate, endDate, false
+		}
+	} else {
+		// Default: today.
+		endDate = time.Now()
+	}
+	return startDate, endDate, true
+}
+
+func periodDays(startDate, endDate time.Time) int {
+	return int(endDate.Sub(startDate).Hours()/24) + 1
+}
+
+// bookedDays sums the days each reservation overlaps the period.
+func bookedDays(reservations []models.Reservation, startDate, endDate time.Time) int {
+	booked := 0
+	for _, r := range reservations {
+		overlapStart := r.StartDate
+		if startDate.After(overlapStart) {
+			overlapStart = startDate
+		}
+		overlapEnd := r.EndDate
+		if endDate.Before(overlapEnd) {
+			overlapEnd = endDate
+		}
+		booked += int(overlapEnd.Sub(overlapStart).Hours()/24) + 1
+	}
+	return booked
+}
@@ -0,0 +1,33 @@
+package hall
+
+import (
+	"errors"
+	"storage/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// validateHall applies the rules every hall must satisfy, on create as well as on update.
+// previous is the stored hall when updating and nil when creating; the availability
+// window may only start in the past if it did so already.
+func validateHall(db *gorm.DB, hall, previous *models.Hall) error {
+	if hall.Capacity <= 0 || hall.CostPerDay <= 0 {
+		return errors.New("Capacity and cost must be positive numbers")
+	}
+
+	if !hall.AvailableFrom.IsZero() && !hall.AvailableTo.IsZero() {
+		if !hall.AvailableFrom.Before(hall.AvailableTo) {
+			return errors.New("AvailableFrom must be before AvailableTo")
+		}
+		changed := previous == nil || !hall.AvailableFrom.Equal(previous.AvailableFrom)
+		if changed && hall.AvailableFrom.Before(time.Now()) {
+			return errors.New("AvailableFrom cannot be in the past")
+		}
+	}
+
+	if err := checkFloor(db, hall); err != nil {
+		return err
+	}
+	return ValidateCoordinates(hall.Latitude, hall.Longitude)
+}
@@ -0,0 +1,261 @@
+package imaging
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrTooLarge        = errors.New("image file is too large")
+	ErrUnsupportedType = errors.New("unsupported image type, use JPEG, PNG or GIF")
+	ErrTypeMismatch    = errors.New("file extension does not match the image content")
+	ErrDimensions      = errors.New("image dimensions exceed the allowed limits")
+	ErrCorrupt         = errors.New("image data is corrupt")
+)
+
+// Limits bounds what an uploaded image may be.
+type Limits struct {
+	MaxBytes  int64 `json:"max_bytes"`
+	MaxWidth  int   `json:"max_width"`
+	MaxHeight int   `json:"max_height"`
+	// MaxAnimationPixels bounds the pixels of all frames of an animated GIF together. Each
+	// frame is decoded at one byte per pixel.
+	MaxAnimationPixels int64 `json:"max_animation_pixels"`
+}
+
+// DefaultLimits apply to any limit left at zero.
+var DefaultLimits = Limits{MaxBytes: 10 << 20, MaxWidth: 6000, MaxHeight: 6000, MaxAnimationPixels: 50_000_000}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = DefaultLimits.MaxBytes
+	}
+	if l.MaxWidth <= 0 {
+		l.MaxWidth = DefaultLimits.MaxWidth
+	}
+	if l.MaxHeight <= 0 {
+		l.MaxHeight = DefaultLimits.MaxHeight
+	}
+	if l.MaxAnimationPixels <= 0 {
+		l.MaxAnimationPixels = DefaultLimits.MaxAnimationPixels
+	}
+	return l
+}
+
+// Format describes an accepted image format.
+type Format struct {
+	MIME       string
+	Extension  string
+	extensions []string // Extensions a client may legitimately use for the format
+}
+
+var formats = map[string]Format{
+	"image/jpeg": {MIME: "image/jpeg", Extension: ".jpg", extensions: []string{".jpg", ".jpeg", ".jpe"}},
+	"image/png":  {MIME: "image/png", Extension: ".png", extensions: []string{".png"}},
+	"image/gif":  {MIME: "image/gif", Extension: ".gif", extensions: []string{".gif"}},
+}
+
+// FormatByExtension returns the format stored under a file extension.
+func FormatByExtension(ext string) (Format, bool) {
+	ext = strings.ToLower(ext)
+	for _, f := range formats {
+		for _, e := range f.extensions {
+			if e == ext {
+				return f, true
+			}
+		}
+	}
+	return Format{}, false
+}
+
+// Image is an uploaded image that passed validation and was re-encoded without metadata.
+type Image struct {
+	Format Format
+	Width  int
+	Height int
+	Data   []byte
+	Image  image.Image // Decoded pixels, after applying the EXIF orientation
+}
+
+// Process validates an upload and re-encodes it. The type is sniffed from the file bytes,
+// never taken from the client, and the client filename is only used to reject uploads whose
+// extension claims a different type. Re-encoding drops EXIF and all other metadata; the
+// EXIF orientation of JPEGs is applied to the pixels first so photos stay upright.
+func Process(r io.Reader, filename string, limits Limits) (*Image, error) {
+	limits = limits.withDefaults()
+
+	raw, err := io.ReadAll(io.LimitReader(r, limits.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(raw)) > limits.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	format, ok := formats[http.DetectContentType(raw)]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+	if ext := filepath.Ext(filename); ext != "" {
+		if claimed, ok := FormatByExtension(ext); !ok || claimed.MIME != format.MIME {
+			return nil, ErrTypeMismatch
+		}
+	}
+
+	// Check the header before decoding so oversized images are rejected without allocating their pixels.
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > limits.MaxWidth || cfg.Height > limits.MaxHeight {
+		return nil, ErrDimensions
+	}
+
+	var out bytes.Buffer
+	var img image.Image
+	switch format.MIME {
+	case "image/gif":
+		// The header only gives the size of the canvas, and every frame is decoded into its
+		// own buffer, so the frames are counted before anything is decoded.
+		pixels, err := gifPixels(raw)
+		if err != nil {
+			return nil, ErrCorrupt
+		}
+		if pixels > limits.MaxAnimationPixels {
+			return nil, ErrDimensions
+		}
+		anim, err := gif.DecodeAll(bytes.NewReader(raw))
+		if err != nil {
+			return nil, ErrCorrupt
+		}
+		// Keep the animation but drop comments and application extensions.
+		clean := &gif.GIF{Image: anim.Image, Delay: anim.Delay, LoopCount: anim.LoopCount, Disposal: anim.Disposal, Config: anim.Config, BackgroundIndex: anim.BackgroundIndex}
+		if err := gif.EncodeAll(&out, clean); err != nil {
+			return nil, err
+		}
+		img = anim.Image[0]
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(raw))
+		if err != nil {
+			return nil, ErrCorrupt
+		}
+		if err := png.Encode(&out, img); err != nil {
+			return nil, err
+		}
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(raw))
+		if err != nil {
+			return nil, ErrCorrupt
+		}
+		img = applyOrientation(img, jpegOrientation(raw))
+		if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, err
+		}
+	}
+
+	bounds := img.Bounds()
+	return &Image{
+		Format: format,
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+		Data:   out.Bytes(),
+		Image:  img,
+	}, nil
+}
+
+// gifPixels adds up the pixels of all frames of a GIF by walking its blocks, without
+// decoding any image data.
+func gifPixels(raw []byte) (int64, error) {
+	errFormat := errors.New("malformed gif")
+	// Header and logical screen descriptor, followed by the global color table if there is one.
+	if len(raw) < 13 {
+		return 0, errFormat
+	}
+	pos := 13
+	if flags := raw[10]; flags&0x80 != 0 {
+		pos += 3 << (flags&0x07 + 1)
+	}
+
+	// skipSubBlocks moves past a sequence of data sub-blocks ended by an empty one.
+	skipSubBlocks := func() error {
+		for {
+			if pos >= len(raw) {
+				return errFormat
+			}
+			n := int(raw[pos])
+			pos += 1 + n
+			if n == 0 {
+				return nil
+			}
+		}
+	}
+
+	var pixels int64
+	for {
+		if pos >= len(raw) {
+			return 0, errFormat
+		}
+		switch raw[pos] {
+		case 0x21: // Extension: label, then sub-blocks
+			pos += 2
+			if err := skipSubBlocks(); err != nil {
+				return 0, err
+			}
+		case 0x2c: // Image descriptor
+			if pos+10 > len(raw) {
+				return 0, errFormat
+			}
+			width := int64(raw[pos+5]) | int64(raw[pos+6])<<8
+			height := int64(raw[pos+7]) | int64(raw[pos+8])<<8
+			flags := raw[pos+9]
+			pixels += width * height
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			pos++ // LZW minimum code size
+			if err := skipSubBlocks(); err != nil {
+				return 0, err
+			}
+		case 0x3b: // Trailer
+			return pixels, nil
+		default:
+			return 0, errFormat
+		}
+	}
+}
+
+// NewName returns a random server-side file name with the format's extension.
+func NewName(format Format) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate image name: %v", err)
+	}
+	return hex.EncodeToString(b) + format.Extension, nil
+}
+
+// ValidName reports whether name is a plain file name that is safe to use as a storage key
+// component: no directories, no traversal, no control characters and no percent-encoding
+// that a later decoding step could turn into any of those.
+func ValidName(name string) bool {
+	if name == "" || name == "." || name == ".." || len(name) > 255 {
+		return false
+	}
+	for _, r := range name {
+		if r == '/' || r == '\\' || r == '%' || r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	return !strings.Contains(name, "..")
+}
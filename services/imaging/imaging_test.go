@@ -0,0 +1,277 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(w, h)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(w, h), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeGIF(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, testImage(w, h), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encodeAnimation encodes a GIF of frames identical w×h frames. Identical frames compress
+// well, so the file stays small however much memory the decoded frames would take.
+func encodeAnimation(t *testing.T, w, h, frames int) []byte {
+	t.Helper()
+	frame := image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{color.Black, color.White})
+	anim := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withExif inserts an APP1 EXIF segment holding the given orientation and a marker
+// string right after the SOI marker of a JPEG.
+func withExif(jpg []byte, orientation uint16, marker string) []byte {
+	tiff := []byte("II*\x00")
+	tiff = binary.LittleEndian.AppendUint32(tiff, 8)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112) // Orientation
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)      // SHORT
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0) // Padding and no next IFD
+	tiff = append(tiff, marker...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+// withTextChunk inserts a tEXt chunk right before the IEND chunk of a PNG.
+func withTextChunk(p []byte, text string) []byte {
+	data := append([]byte("Comment\x00"), text...)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	typed := append([]byte("tEXt"), data...)
+	chunk = append(chunk, typed...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(typed))
+
+	end := len(p) - 12
+	out := append([]byte{}, p[:end]...)
+	out = append(out, chunk...)
+	return append(out, p[end:]...)
+}
+
+func TestProcess(t *testing.T) {
+	small := Limits{MaxBytes: 1 << 20, MaxWidth: 100, MaxHeight: 80}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		filename   string
+		limits     Limits
+		wantErr    error
+		wantMIME   string
+		wantWidth  int
+		wantHeight int
+	}{
+		{name: "png", data: encodePNG(t, 40, 30), filename: "hall.png", limits: small, wantMIME: "image/png", wantWidth: 40, wantHeight: 30},
+		{name: "jpeg with .jpeg extension", data: encodeJPEG(t, 40, 30), filename: "hall.JPEG", limits: small, wantMIME: "image/jpeg", wantWidth: 40, wantHeight: 30},
+		{name: "gif", data: encodeGIF(t, 40, 30), filename: "hall.gif", limits: small, wantMIME: "image/gif", wantWidth: 40, wantHeight: 30},
+		{name: "no extension uses the sniffed type", data: encodePNG(t, 40, 30), filename: "hall", limits: small, wantMIME: "image/png", wantWidth: 40, wantHeight: 30},
+
+		{name: "png named jpg", data: encodePNG(t, 40, 30), filename: "hall.jpg", limits: small, wantErr: ErrTypeMismatch},
+		{name: "jpeg named gif", data: encodeJPEG(t, 40, 30), filename: "hall.gif", limits: small, wantErr: ErrTypeMismatch},
+		{name: "png with unknown extension", data: encodePNG(t, 40, 30), filename: "hall.php", limits: small, wantErr: ErrTypeMismatch},
+		{name: "html named png", data: []byte("<html><script>alert(1)</script></html>"), filename: "hall.png", limits: small, wantErr: ErrUnsupportedType},
+		{name: "truncated png", data: encodePNG(t, 40, 30)[:40], filename: "hall.png", limits: small, wantErr: ErrCorrupt},
+
+		{name: "over the byte limit", data: encodePNG(t, 40, 30), filename: "hall.png", limits: Limits{MaxBytes: 64, MaxWidth: 100, MaxHeight: 80}, wantErr: ErrTooLarge},
+		{name: "too wide", data: encodePNG(t, 101, 10), filename: "hall.png", limits: small, wantErr: ErrDimensions},
+		{name: "too tall", data: encodeJPEG(t, 10, 81), filename: "hall.jpg", limits: small, wantErr: ErrDimensions},
+		{name: "exactly at the limits", data: encodePNG(t, 100, 80), filename: "hall.png", limits: small, wantMIME: "image/png", wantWidth: 100, wantHeight: 80},
+
+		{name: "animation within the frame budget", data: encodeAnimation(t, 50, 40, 10), filename: "hall.gif", limits: Limits{MaxWidth: 100, MaxHeight: 80, MaxAnimationPixels: 20000}, wantMIME: "image/gif", wantWidth: 50, wantHeight: 40},
+		{name: "animation over the frame budget", data: encodeAnimation(t, 50, 40, 11), filename: "hall.gif", limits: Limits{MaxWidth: 100, MaxHeight: 80, MaxAnimationPixels: 20000}, wantErr: ErrDimensions},
+		{name: "truncated gif", data: encodeAnimation(t, 50, 40, 3)[:100], filename: "hall.gif", limits: small, wantErr: ErrCorrupt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Process(bytes.NewReader(tt.data), tt.filename, tt.limits)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Process() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if img.Format.MIME != tt.wantMIME || img.Width != tt.wantWidth || img.Height != tt.wantHeight {
+				t.Errorf("Process() = %s %dx%d, want %s %dx%d", img.Format.MIME, img.Width, img.Height, tt.wantMIME, tt.wantWidth, tt.wantHeight)
+			}
+			if _, _, err := image.Decode(bytes.NewReader(img.Data)); err != nil {
+				t.Errorf("re-encoded image does not decode: %v", err)
+			}
+		})
+	}
+}
+
+func TestProcessRejectsGIFBomb(t *testing.T) {
+	// 13 frames of 2000×2000 fit in about 50 KB but would decode into 52 million pixels.
+	data := encodeAnimation(t, 2000, 2000, 13)
+	if len(data) > int(DefaultLimits.MaxBytes) {
+		t.Fatalf("test GIF is %d bytes, over the upload limit", len(data))
+	}
+	if _, err := Process(bytes.NewReader(data), "hall.gif", Limits{}); !errors.Is(err, ErrDimensions) {
+		t.Errorf("Process() error = %v, want ErrDimensions", err)
+	}
+}
+
+func TestGIFPixels(t *testing.T) {
+	data := encodeAnimation(t, 30, 20, 7)
+	pixels, err := gifPixels(data)
+	if err != nil || pixels != 7*30*20 {
+		t.Errorf("gifPixels() = %d, %v, want %d", pixels, err, 7*30*20)
+	}
+	// A still image is one frame.
+	if pixels, err := gifPixels(encodeGIF(t, 40, 30)); err != nil || pixels != 1200 {
+		t.Errorf("gifPixels() of a still image = %d, %v, want 1200", pixels, err)
+	}
+	for _, bad := range [][]byte{data[:12], data[:len(data)-1], append(data[:len(data)-1:len(data)-1], 0x99)} {
+		if _, err := gifPixels(bad); err == nil {
+			t.Errorf("gifPixels() accepted a malformed GIF of %d bytes", len(bad))
+		}
+	}
+}
+
+func TestProcessStripsMetadata(t *testing.T) {
+	const secret = "GPS 52.5200N 13.4050E"
+
+	tests := []struct {
+		name       string
+		data       []byte
+		filename   string
+		wantWidth  int
+		wantHeight int
+	}{
+		{name: "jpeg exif without rotation", data: withExif(encodeJPEG(t, 40, 30), 1, secret), filename: "photo.jpg", wantWidth: 40, wantHeight: 30},
+		{name: "jpeg exif rotated 90", data: withExif(encodeJPEG(t, 40, 30), 6, secret), filename: "photo.jpg", wantWidth: 30, wantHeight: 40},
+		{name: "jpeg exif rotated 180", data: withExif(encodeJPEG(t, 40, 30), 3, secret), filename: "photo.jpg", wantWidth: 40, wantHeight: 30},
+		{name: "png text chunk", data: withTextChunk(encodePNG(t, 40, 30), secret), filename: "photo.png", wantWidth: 40, wantHeight: 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !bytes.Contains(tt.data, []byte(secret)) {
+				t.Fatal("test input does not carry the metadata")
+			}
+
+			img, err := Process(bytes.NewReader(tt.data), tt.filename, Limits{})
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if bytes.Contains(img.Data, []byte(secret)) || bytes.Contains(img.Data, []byte("Exif\x00\x00")) {
+				t.Error("metadata survived re-encoding")
+			}
+			if got := jpegOrientation(img.Data); got != 1 {
+				t.Errorf("orientation after processing = %d, want 1", got)
+			}
+			if img.Width != tt.wantWidth || img.Height != tt.wantHeight {
+				t.Errorf("size = %dx%d, want %dx%d", img.Width, img.Height, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	for want := 1; want <= 8; want++ {
+		data := withExif(encodeJPEG(t, 4, 4), uint16(want), "")
+		if got := jpegOrientation(data); got != want {
+			t.Errorf("jpegOrientation() = %d, want %d", got, want)
+		}
+	}
+	if got := jpegOrientation(encodeJPEG(t, 4, 4)); got != 1 {
+		t.Errorf("jpegOrientation() without EXIF = %d, want 1", got)
+	}
+}
+
+func TestValidName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"0123456789abcdef0123456789abcdef.jpg", true},
+		{"hall_thumbnail.png", true},
+		{"photo.final.jpeg", true},
+
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../config.json", false},
+		{"..\\config.json", false},
+		{"images/../../config.json", false},
+		{"a..b.png", false},
+		{"/etc/passwd", false},
+		{"\\\\server\\share\\file.png", false},
+		{"C:\\Windows\\win.ini", false},
+		{"%2e%2e%2fconfig.json", false},
+		{"..%2fconfig.json", false},
+		{"%2fetc%2fpasswd", false},
+		{"hall.png%00.txt", false},
+		{"hall\x00.png", false},
+		{"hall\n.png", false},
+		{"hall\x7f.png", false},
+		{strings.Repeat("a", 252) + ".png", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidName(tt.name); got != tt.want {
+			t.Errorf("ValidName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
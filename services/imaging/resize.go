@@ -0,0 +1,162 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"path"
+	"strings"
+)
+
+// Variant is a resized rendition of an uploaded image.
+type Variant struct {
+	Name    string
+	MaxSize int // Longest side in pixels; 0 keeps the original
+}
+
+// Image variants, from smallest to largest. The original is stored under the image's own name.
+var (
+	VariantThumbnail = Variant{Name: "thumbnail", MaxSize: 320}
+	VariantMedium    = Variant{Name: "medium", MaxSize: 1024}
+	VariantOriginal  = Variant{Name: "original"}
+
+	Variants = []Variant{VariantThumbnail, VariantMedium, VariantOriginal}
+)
+
+// VariantByName looks up a variant; an empty name selects the original.
+func VariantByName(name string) (Variant, bool) {
+	if name == "" {
+		return VariantOriginal, true
+	}
+	for _, v := range Variants {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return Variant{}, false
+}
+
+// FileName returns the stored file name of this variant of an image, e.g. "abc_thumbnail.jpg".
+func (v Variant) FileName(name string) string {
+	if v.MaxSize == 0 {
+		return name
+	}
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "_" + v.Name + ext
+}
+
+// Render resizes the image to fit the variant and encodes it in the image's format.
+// Images already smaller than the variant are not upscaled.
+func (i *Image) Render(v Variant) ([]byte, error) {
+	if v.MaxSize == 0 {
+		return i.Data, nil
+	}
+
+	scaled := Fit(i.Image, v.MaxSize)
+	var out bytes.Buffer
+	var err error
+	switch i.Format.MIME {
+	case "image/jpeg":
+		err = jpeg.Encode(&out, scaled, &jpeg.Options{Quality: 85})
+	case "image/png":
+		err = png.Encode(&out, scaled)
+	case "image/gif":
+		err = gif.Encode(&out, scaled, nil)
+	}
+	return out.Bytes(), err
+}
+
+// Fit scales img down so that neither side exceeds maxSize, keeping the aspect ratio.
+func Fit(img image.Image, maxSize int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSize && h <= maxSize {
+		return img
+	}
+
+	scale := float64(maxSize) / float64(w)
+	if h > w {
+		scale = float64(maxSize) / float64(h)
+	}
+	dw := int(math.Max(1, math.Round(float64(w)*scale)))
+	dh := int(math.Max(1, math.Round(float64(h)*scale)))
+	return Resize(img, dw, dh)
+}
+
+// Resize scales img to exactly w x h pixels. Each destination pixel is the area-weighted
+// average of the source pixels it covers (a box filter), which gives clean downscaling.
+func Resize(img image.Image, w, h int) *image.NRGBA {
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	sw, sh := b.Dx(), b.Dy()
+	xs := boxWeights(sw, w)
+	ys := boxWeights(sh, h)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for dy, yw := range ys {
+		for dx, xw := range xs {
+			var r, g, bl, a, total float64
+			for _, yc := range yw {
+				row := yc.index * src.Stride
+				for _, xc := range xw {
+					weight := yc.weight * xc.weight
+					p := src.Pix[row+xc.index*4 : row+xc.index*4+4]
+					r += float64(p[0]) * weight
+					g += float64(p[1]) * weight
+					bl += float64(p[2]) * weight
+					a += float64(p[3]) * weight
+					total += weight
+				}
+			}
+
+			// Averaging is done on premultiplied values; convert back for NRGBA.
+			o := dst.PixOffset(dx, dy)
+			if a == 0 {
+				continue
+			}
+			dst.Pix[o+0] = clamp(r / a * 255)
+			dst.Pix[o+1] = clamp(g / a * 255)
+			dst.Pix[o+2] = clamp(bl / a * 255)
+			dst.Pix[o+3] = clamp(a / total)
+		}
+	}
+	return dst
+}
+
+type coverage struct {
+	index  int
+	weight float64
+}
+
+// boxWeights computes, for every destination index, which source indices it covers and by how much.
+func boxWeights(srcLen, dstLen int) [][]coverage {
+	scale := float64(srcLen) / float64(dstLen)
+	weights := make([][]coverage, dstLen)
+	for d := range weights {
+		start := float64(d) * scale
+		end := start + scale
+		for s := int(start); s < srcLen && float64(s) < end; s++ {
+			overlap := math.Min(end, float64(s+1)) - math.Max(start, float64(s))
+			if overlap > 0 {
+				weights[d] = append(weights[d], coverage{index: s, weight: overlap})
+			}
+		}
+	}
+	return weights
+}
+
+func clamp(v float64) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
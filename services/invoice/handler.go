@@ -0,0 +1,90 @@
+package invoice
+
+import (
+	"errors"
+	"net/http"
+	"storage/configuration"
+	"storage/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateInvoice issues the invoice for a reservation.
+func CreateInvoice(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var buyer Buyer
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&buyer); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+				return
+			}
+		}
+
+		var reservation models.Reservation
+		if err := conf.Db.Preload("Hall").First(&reservation, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+			return
+		}
+
+		if reservation.IsCancelled() {
+			c.JSON(http.StatusConflict, gin.H{"error": "Reservation has been cancelled"})
+			return
+		}
+
+		inv, err := Issue(conf.Db, conf.Cfg, &reservation, buyer)
+		if errors.Is(err, ErrAlreadyInvoiced) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue invoice"})
+			return
+		}
+
+		c.JSON(http.StatusOK, inv)
+	}
+}
+
+// GetInvoice returns an issued invoice or credit note with its line items.
+func GetInvoice(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var inv models.Invoice
+		if err := conf.Db.Preload("Lines").First(&inv, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, inv)
+	}
+}
+
+// CreateCreditNote corrects an invoice by issuing a credit note against it.
+func CreateCreditNote(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Reason string `json:"reason" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A reason for the credit note is required"})
+			return
+		}
+
+		var original models.Invoice
+		if err := conf.Db.Preload("Lines").First(&original, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
+
+		note, err := IssueCreditNote(conf.Db, conf.Cfg, &original, req.Reason)
+		if errors.Is(err, ErrAlreadyCredited) || errors.Is(err, ErrNotCreditable) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue credit note"})
+			return
+		}
+
+		c.JSON(http.StatusOK, note)
+	}
+}
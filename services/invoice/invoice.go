@@ -0,0 +1,231 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"storage/configuration"
+	"storage/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Buyer holds the customer details printed on an invoice.
+type Buyer struct {
+	Company string `json:"company"`
+	VATID   string `json:"vat_id"`
+	Address string `json:"address"`
+}
+
+// FiscalYear returns the fiscal year a date falls into. Fiscal years are named after
+// the calendar year in which they start.
+func FiscalYear(t time.Time, startMonth int) int {
+	if startMonth <= 1 {
+		return t.Year()
+	}
+	if int(t.Month()) < startMonth {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+// Issue creates an invoice for a reservation, numbered from the gap-free sequence of its fiscal year.
+func Issue(db *gorm.DB, cfg *configuration.EnvironmentConfig, reservation *models.Reservation, buyer Buyer) (*models.Invoice, error) {
+	if buyer.Company == "" {
+		buyer.Company = reservation.Company
+	}
+
+	inv := &models.Invoice{
+		Kind:          models.InvoiceKindInvoice,
+		ReservationID: reservation.ID,
+		BuyerCompany:  buyer.Company,
+		BuyerVATID:    buyer.VATID,
+		BuyerAddress:  buyer.Address,
+		Lines:         reservationLines(reservation, cfg.Invoicing.VATRate),
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the reservation first, so concurrent requests for it are checked one at a time.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			First(&models.Reservation{}, reservation.ID).Error; err != nil {
+			return err
+		}
+
+		// A reservation may be invoiced again only after its previous invoice was credited.
+		credited := tx.Model(&models.Invoice{}).Select("credited_invoice_id").
+			Where("kind = ? AND reservation_id = ?", models.InvoiceKindCreditNote, reservation.ID)
+		var count int64
+		if err := tx.Model(&models.Invoice{}).
+			Where("reservation_id = ? AND kind = ?", reservation.ID, models.InvoiceKindInvoice).
+			Where("id NOT IN (?)", credited).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyInvoiced
+		}
+		return create(tx, cfg, inv)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// IssueCreditNote cancels an invoice in full by issuing a credit note with negated line items.
+func IssueCreditNote(db *gorm.DB, cfg *configuration.EnvironmentConfig, original *models.Invoice, reason string) (*models.Invoice, error) {
+	if original.Kind != models.InvoiceKindInvoice {
+		return nil, ErrNotCreditable
+	}
+
+	note := &models.Invoice{
+		Kind:              models.InvoiceKindCreditNote,
+		ReservationID:     original.ReservationID,
+		CreditedInvoiceID: &original.ID,
+		Reason:            reason,
+		BuyerCompany:      original.BuyerCompany,
+		BuyerVATID:        original.BuyerVATID,
+		BuyerAddress:      original.BuyerAddress,
+	}
+	for _, line := range original.Lines {
+		note.Lines = append(note.Lines, models.InvoiceLine{
+			Position:    line.Position,
+			Description: fmt.Sprintf("%s (credit for invoice %s)", line.Description, original.Number),
+			Quantity:    -line.Quantity,
+			UnitPrice:   line.UnitPrice,
+			NetAmount:   -line.NetAmount,
+			VATRate:     line.VATRate,
+			VATAmount:   -line.VATAmount,
+			GrossAmount: -line.GrossAmount,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the original invoice, so it cannot be credited twice by concurrent requests.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			First(&models.Invoice{}, original.ID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Invoice{}).Where("credited_invoice_id = ?", original.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyCredited
+		}
+		return create(tx, cfg, note)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// CreditReservation issues a credit note for the reservation's invoice, if it has one that
+// has not been credited yet. It is used when a reservation is cancelled.
+func CreditReservation(db *gorm.DB, cfg *configuration.EnvironmentConfig, reservationID uint, reason string) error {
+	credited := db.Model(&models.Invoice{}).Select("credited_invoice_id").
+		Where("kind = ? AND reservation_id = ? AND credited_invoice_id IS NOT NULL", models.InvoiceKindCreditNote, reservationID)
+
+	var open []models.Invoice
+	if err := db.Preload("Lines").
+		Where("reservation_id = ? AND kind = ?", reservationID, models.InvoiceKindInvoice).
+		Where("id NOT IN (?)", credited).
+		Find(&open).Error; err != nil {
+		return err
+	}
+	for i := range open {
+		if _, err := IssueCreditNote(db, cfg, &open[i], reason); err != nil && !errors.Is(err, ErrAlreadyCredited) {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	ErrAlreadyInvoiced = errors.New("reservation has already been invoiced")
+	ErrAlreadyCredited = errors.New("invoice has already been credited")
+	ErrNotCreditable   = errors.New("only invoices can be credited")
+)
+
+// create assigns the next number of the fiscal year and stores the invoice. It must run
+// inside a transaction: the sequence row stays locked until commit, and a failed insert
+// rolls the counter back, so numbers are never skipped or reused.
+func create(tx *gorm.DB, cfg *configuration.EnvironmentConfig, inv *models.Invoice) error {
+	now := time.Now()
+	year := FiscalYear(now, cfg.Invoicing.FiscalYearStartMonth)
+
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.InvoiceSequence{FiscalYear: year}).Error; err != nil {
+		return err
+	}
+
+	var seq models.InvoiceSequence
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&seq, "fiscal_year = ?", year).Error; err != nil {
+		return err
+	}
+	seq.LastNumber++
+	if err := tx.Model(&seq).Where("fiscal_year = ?", year).Update("last_number", seq.LastNumber).Error; err != nil {
+		return err
+	}
+
+	inv.FiscalYear = year
+	inv.Sequence = seq.LastNumber
+	inv.Number = models.FormatInvoiceNumber(year, seq.LastNumber)
+	inv.IssuedAt = now
+	inv.SellerCompany = cfg.Invoicing.Company
+	inv.SellerVATID = cfg.Invoicing.VATID
+	inv.SellerAddress = cfg.Invoicing.Address
+	inv.Currency = cfg.Payments.Currency
+
+	inv.NetTotal, inv.VATTotal, inv.Total = 0, 0, 0
+	for _, line := range inv.Lines {
+		inv.NetTotal += line.NetAmount
+		inv.VATTotal += line.VATAmount
+		inv.Total += line.GrossAmount
+	}
+	inv.NetTotal = round(inv.NetTotal)
+	inv.VATTotal = round(inv.VATTotal)
+	inv.Total = round(inv.Total)
+
+	return tx.Create(inv).Error
+}
+
+// reservationLines itemizes a reservation: the hall rental per day plus the long-stay discount, if any.
+// Reservation prices include VAT, which is extracted per line.
+func reservationLines(r *models.Reservation, vatRate float64) []models.InvoiceLine {
+	description := fmt.Sprintf("Hall %d rental, %s - %s", r.HallID, r.StartDate.Format("2006-01-02"), r.EndDate.Format("2006-01-02"))
+	if r.Hall.CostPerDay <= 0 {
+		return []models.InvoiceLine{newLine(1, description, 1, r.TotalCost, vatRate)}
+	}
+
+	b := r.CostBreakdown(r.Hall.CostPerDay)
+	lines := []models.InvoiceLine{newLine(1, description, b.Days, b.CostPerDay, vatRate)}
+	if discount := round(b.Subtotal - r.TotalCost); discount > 0 {
+		lines = append(lines, newLine(2, "Long-stay discount", 1, -discount, vatRate))
+	}
+	return lines
+}
+
+// newLine builds a line item from a VAT-inclusive unit price.
+func newLine(position int, description string, quantity, grossUnitPrice, vatRate float64) models.InvoiceLine {
+	gross := round(quantity * grossUnitPrice)
+	net := round(gross / (1 + vatRate))
+	return models.InvoiceLine{
+		Position:    position,
+		Description: description,
+		Quantity:    quantity,
+		UnitPrice:   round(grossUnitPrice / (1 + vatRate)),
+		NetAmount:   net,
+		VATRate:     vatRate,
+		VATAmount:   round(gross - net),
+		GrossAmount: gross,
+	}
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
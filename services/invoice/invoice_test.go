@@ -0,0 +1,220 @@
+package invoice
+
+import (
+	"errors"
+	"os"
+	"storage/configuration"
+	"storage/models"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestFiscalYear(t *testing.T) {
+	tests := []struct {
+		date       string
+		startMonth int
+		want       int
+	}{
+		{"2025-01-01", 0, 2025},
+		{"2025-12-31", 1, 2025},
+		{"2025-03-31", 4, 2024},
+		{"2025-04-01", 4, 2025},
+		{"2026-03-31", 4, 2025},
+		{"2025-06-30", 7, 2024},
+		{"2025-07-01", 7, 2025},
+		{"2025-11-30", 12, 2024},
+		{"2025-12-01", 12, 2025},
+	}
+
+	for _, tt := range tests {
+		date, _ := time.Parse("2006-01-02", tt.date)
+		if got := FiscalYear(date, tt.startMonth); got != tt.want {
+			t.Errorf("FiscalYear(%s, %d) = %d, want %d", tt.date, tt.startMonth, got, tt.want)
+		}
+	}
+}
+
+func TestReservationLines(t *testing.T) {
+	start := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+	r := models.Reservation{HallID: 3, StartDate: start, EndDate: start.AddDate(0, 0, 10), Hall: models.Hall{CostPerDay: 120}}
+	r.CalculateTotalCost(r.Hall.CostPerDay)
+
+	lines := reservationLines(&r, 0.20)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want rental and long-stay discount", len(lines))
+	}
+	if lines[0].Quantity != 10 || lines[0].GrossAmount != 1200 || lines[0].NetAmount != 1000 || lines[0].VATAmount != 200 {
+		t.Errorf("rental line = %+v", lines[0])
+	}
+	if lines[1].GrossAmount != -120 {
+		t.Errorf("discount line = %+v", lines[1])
+	}
+	var total float64
+	for _, l := range lines {
+		total += l.GrossAmount
+	}
+	if round(total) != r.TotalCost {
+		t.Errorf("lines add up to %v, want the reservation total %v", total, r.TotalCost)
+	}
+}
+
+// testDB connects to the MySQL server named by TEST_MYSQL_DSN, e.g.
+// "root:secret@tcp(localhost:3306)/?parseTime=True&loc=Local". It must be a disposable
+// server: the tests create the hall_res_project tables they need and leave their rows behind.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN is not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("CREATE DATABASE IF NOT EXISTS hall_res_project").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Reservation{}, &models.Invoice{}, &models.InvoiceLine{}, &models.InvoiceSequence{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func testConfig() *configuration.EnvironmentConfig {
+	return &configuration.EnvironmentConfig{
+		Invoicing: configuration.Invoicing{Company: "Hall Reservations Ltd.", VATRate: 0.20},
+		Payments:  configuration.Payments{Currency: "BGN"},
+	}
+}
+
+func testReservation(t *testing.T, db *gorm.DB) *models.Reservation {
+	t.Helper()
+	start := time.Now().AddDate(0, 1, 0)
+	r := &models.Reservation{UserID: 1, HallID: 1, Name: "Test", Company: "ACME", StartDate: start, EndDate: start.AddDate(0, 0, 2), TotalCost: 240}
+	if err := db.Create(r).Error; err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func lastNumber(t *testing.T, db *gorm.DB, year int) int {
+	t.Helper()
+	var seq models.InvoiceSequence
+	err := db.First(&seq, "fiscal_year = ?", year).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seq.LastNumber
+}
+
+func TestNumberingIsGapFreeAcrossRollback(t *testing.T) {
+	db := testDB(t)
+	cfg := testConfig()
+	r := testReservation(t, db)
+	year := FiscalYear(time.Now(), cfg.Invoicing.FiscalYearStartMonth)
+	before := lastNumber(t, db, year)
+
+	// An invoice whose transaction rolls back gives its number back.
+	errAbort := errors.New("abort")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		inv := &models.Invoice{Kind: models.InvoiceKindInvoice, ReservationID: r.ID, BuyerCompany: "ACME"}
+		if err := create(tx, cfg, inv); err != nil {
+			return err
+		}
+		if inv.Sequence != before+1 {
+			t.Errorf("sequence inside the transaction = %d, want %d", inv.Sequence, before+1)
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("transaction error = %v", err)
+	}
+	if got := lastNumber(t, db, year); got != before {
+		t.Fatalf("last number after rollback = %d, want %d", got, before)
+	}
+
+	inv, err := Issue(db, cfg, r, Buyer{})
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if inv.Sequence != before+1 || inv.Number != models.FormatInvoiceNumber(year, before+1) {
+		t.Errorf("invoice %s has sequence %d, want %d", inv.Number, inv.Sequence, before+1)
+	}
+}
+
+func TestReinvoiceOnlyAfterCreditNote(t *testing.T) {
+	db := testDB(t)
+	cfg := testConfig()
+	r := testReservation(t, db)
+
+	first, err := Issue(db, cfg, r, Buyer{})
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if _, err := Issue(db, cfg, r, Buyer{}); !errors.Is(err, ErrAlreadyInvoiced) {
+		t.Fatalf("second Issue() error = %v, want ErrAlreadyInvoiced", err)
+	}
+
+	note, err := IssueCreditNote(db, cfg, first, "Wrong buyer")
+	if err != nil {
+		t.Fatalf("IssueCreditNote() error = %v", err)
+	}
+	if note.Total != -first.Total || note.Sequence != first.Sequence+1 {
+		t.Errorf("credit note total %v, sequence %d; invoice total %v, sequence %d", note.Total, note.Sequence, first.Total, first.Sequence)
+	}
+	if _, err := IssueCreditNote(db, cfg, first, "Again"); !errors.Is(err, ErrAlreadyCredited) {
+		t.Errorf("second IssueCreditNote() error = %v, want ErrAlreadyCredited", err)
+	}
+	if _, err := IssueCreditNote(db, cfg, note, "Credit of a credit"); !errors.Is(err, ErrNotCreditable) {
+		t.Errorf("crediting a credit note error = %v, want ErrNotCreditable", err)
+	}
+
+	second, err := Issue(db, cfg, r, Buyer{Company: "ACME Holding"})
+	if err != nil {
+		t.Fatalf("Issue() after the credit note error = %v", err)
+	}
+	if second.BuyerCompany != "ACME Holding" {
+		t.Errorf("buyer = %q", second.BuyerCompany)
+	}
+}
+
+func TestConcurrentIssueInvoicesOnce(t *testing.T) {
+	db := testDB(t)
+	cfg := testConfig()
+	r := testReservation(t, db)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = Issue(db, cfg, r, Buyer{})
+		}(i)
+	}
+	wg.Wait()
+
+	issued := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			issued++
+		case !errors.Is(err, ErrAlreadyInvoiced):
+			t.Errorf("Issue() error = %v", err)
+		}
+	}
+	if issued != 1 {
+		t.Errorf("%d invoices issued for one reservation, want 1", issued)
+	}
+}
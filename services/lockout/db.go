@@ -0,0 +1,78 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Counter is the database row behind the DB store.
+type Counter struct {
+	Key         string    `gorm:"primaryKey;size:191"` // "user:<name>" or "ip:<address>"
+	Failures    int       `gorm:"not null;default:0"`
+	LastFailure time.Time `gorm:"index"`
+	LockedUntil time.Time
+}
+
+func (Counter) TableName() string {
+	return "hall_res_project.login_attempts"
+}
+
+// DB is a Store shared by every instance using the same database.
+type DB struct {
+	db *gorm.DB
+}
+
+func NewDB(db *gorm.DB) *DB {
+	return &DB{db: db}
+}
+
+func (s *DB) Get(ctx context.Context, key string) (Entry, error) {
+	var a Counter
+	err := s.db.WithContext(ctx).First(&a, "`key` = ?", key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Entry{}, nil
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	return a.entry(), nil
+}
+
+func (s *DB) Update(ctx context.Context, key string, fn func(*Entry)) (Entry, error) {
+	var e Entry
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Make sure the row exists, then lock it, so concurrent failures are all counted.
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Counter{Key: key}).Error; err != nil {
+			return err
+		}
+		var a Counter
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&a, "`key` = ?", key).Error; err != nil {
+			return err
+		}
+
+		e = a.entry()
+		fn(&e)
+		return tx.Model(&Counter{}).Where("`key` = ?", key).Updates(map[string]interface{}{
+			"failures":     e.Failures,
+			"last_failure": e.LastFailure,
+			"locked_until": e.LockedUntil,
+		}).Error
+	})
+	return e, err
+}
+
+func (s *DB) Delete(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Delete(&Counter{}, "`key` = ?", key).Error
+}
+
+func (s *DB) Prune(ctx context.Context, before time.Time) error {
+	return s.db.WithContext(ctx).Where("last_failure < ? AND locked_until < ?", before, time.Now()).Delete(&Counter{}).Error
+}
+
+func (a *Counter) entry() Entry {
+	return Entry{Failures: a.Failures, LastFailure: a.LastFailure, LockedUntil: a.LockedUntil}
+}
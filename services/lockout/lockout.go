@@ -0,0 +1,261 @@
+package lockout
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+// Policy decides how failed logins are slowed down. Both the username and the client IP
+// are tracked: each failure beyond the free attempts doubles the wait before the next try.
+// After MaxFailures the account is locked outright. IPs are never locked, since many users
+// may share one address, but their free allowance is larger.
+type Policy struct {
+	MaxFailures       int `json:"max_failures"`        // Failures per username before the account is locked
+	LockMinutes       int `json:"lock_minutes"`        // How long a locked account stays locked
+	FreeAttempts      int `json:"free_attempts"`       // Failures per username allowed without delay
+	IPFreeAttempts    int `json:"ip_free_attempts"`    // Failures per IP allowed without delay
+	MaxBackoffSeconds int `json:"max_backoff_seconds"` // Upper bound of the wait between attempts
+	ResetMinutes      int `json:"reset_minutes"`       // Failures older than this are forgotten
+}
+
+// DefaultPolicy applies to any setting left at zero.
+var DefaultPolicy = Policy{
+	MaxFailures:       10,
+	LockMinutes:       15,
+	FreeAttempts:      3,
+	IPFreeAttempts:    20,
+	MaxBackoffSeconds: 300,
+	ResetMinutes:      60,
+}
+
+func (p Policy) withDefaults() Policy {
+	if p.MaxFailures <= 0 {
+		p.MaxFailures = DefaultPolicy.MaxFailures
+	}
+	if p.LockMinutes <= 0 {
+		p.LockMinutes = DefaultPolicy.LockMinutes
+	}
+	if p.FreeAttempts <= 0 {
+		p.FreeAttempts = DefaultPolicy.FreeAttempts
+	}
+	if p.IPFreeAttempts <= 0 {
+		p.IPFreeAttempts = DefaultPolicy.IPFreeAttempts
+	}
+	if p.MaxBackoffSeconds <= 0 {
+		p.MaxBackoffSeconds = DefaultPolicy.MaxBackoffSeconds
+	}
+	if p.ResetMinutes <= 0 {
+		p.ResetMinutes = DefaultPolicy.ResetMinutes
+	}
+	return p
+}
+
+// Verdict says whether a login may be attempted now.
+type Verdict struct {
+	Locked     bool          // The account is locked
+	RetryAfter time.Duration // Zero if the attempt may go ahead
+}
+
+// Allowed reports whether the attempt may go ahead.
+func (v Verdict) Allowed() bool {
+	return v.RetryAfter <= 0
+}
+
+// UserKey and IPKey name the counters of a username and of a client address.
+// Usernames are tracked whether or not an account exists, so lockouts reveal nothing.
+func UserKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Guard applies a Policy using a Store.
+type Guard struct {
+	store  Store
+	policy Policy
+}
+
+func NewGuard(store Store, policy Policy) *Guard {
+	return &Guard{store: store, policy: policy.withDefaults()}
+}
+
+// Attempt is a login attempt that was admitted by Begin. It already counts as a failure,
+// so concurrent attempts see it and are slowed down by it; it must be settled with Fail,
+// Succeed or Release.
+type Attempt struct {
+	guard    *Guard
+	username string
+	ip       string
+	at       time.Time
+	user     Entry // Counters before the attempt was reserved, restored on release
+	addr     Entry
+	settled  bool
+}
+
+// Begin admits a login for username from ip at now, unless the account is locked or the
+// username or ip has to wait after earlier failures. Checking and reserving happen in one
+// Store update per key, so parallel guesses cannot all pass the same check.
+// The Attempt is nil when the verdict does not allow the login.
+func (g *Guard) Begin(ctx context.Context, username, ip string, now time.Time) (*Attempt, Verdict, error) {
+	// Stored times keep milliseconds, and release has to recognise its own reservation.
+	now = now.Truncate(time.Millisecond)
+	a := &Attempt{guard: g, username: username, ip: ip, at: now}
+
+	var verdict Verdict
+	_, err := g.store.Update(ctx, UserKey(username), func(e *Entry) {
+		if e.LockedUntil.After(now) {
+			verdict = Verdict{Locked: true, RetryAfter: e.LockedUntil.Sub(now)}
+			return
+		}
+		if wait := g.wait(*e, g.policy.FreeAttempts, now); wait > 0 {
+			verdict = Verdict{RetryAfter: wait}
+			return
+		}
+		a.user = *e
+		g.count(e, now)
+	})
+	if err != nil || !verdict.Allowed() {
+		return nil, verdict, err
+	}
+
+	_, err = g.store.Update(ctx, IPKey(ip), func(e *Entry) {
+		if wait := g.wait(*e, g.policy.IPFreeAttempts, now); wait > 0 {
+			verdict = Verdict{RetryAfter: wait}
+			return
+		}
+		a.addr = *e
+		g.count(e, now)
+	})
+	if err != nil || !verdict.Allowed() {
+		// Give back the reservation on the username.
+		if rerr := a.release(ctx, UserKey(username), a.user); rerr != nil && err == nil {
+			err = rerr
+		}
+		return nil, verdict, err
+	}
+	return a, verdict, nil
+}
+
+// Fail settles the attempt as a failed login. The failure was counted by Begin already;
+// Fail locks the account once it reaches MaxFailures and reports whether it did.
+func (a *Attempt) Fail(ctx context.Context) (bool, error) {
+	if a.settled {
+		return false, nil
+	}
+	a.settled = true
+
+	g := a.guard
+	locked := false
+	_, err := g.store.Update(ctx, UserKey(a.username), func(e *Entry) {
+		if e.Failures >= g.policy.MaxFailures && !e.LockedUntil.After(a.at) {
+			// Start counting afresh once the lock expires.
+			e.Failures = 0
+			e.LockedUntil = a.at.Add(time.Duration(g.policy.LockMinutes) * time.Minute)
+			locked = true
+		}
+	})
+	return locked, err
+}
+
+// Succeed settles the attempt as a successful login and clears the failures of the
+// username. The reservation on the IP is given back but earlier failures are kept, so one
+// valid account cannot be used to reset the allowance for guessing others.
+func (a *Attempt) Succeed(ctx context.Context) error {
+	if a.settled {
+		return nil
+	}
+	a.settled = true
+
+	err := a.guard.store.Delete(ctx, UserKey(a.username))
+	if rerr := a.release(ctx, IPKey(a.ip), a.addr); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// Release gives back the reservation of an attempt that was neither a failed nor a
+// successful login, such as one that ended in a server error or a correct password
+// followed by a second factor. It does nothing once the attempt is settled, so it can be
+// deferred.
+func (a *Attempt) Release(ctx context.Context) error {
+	if a.settled {
+		return nil
+	}
+	a.settled = true
+
+	err := a.release(ctx, UserKey(a.username), a.user)
+	if rerr := a.release(ctx, IPKey(a.ip), a.addr); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// release takes the failure reserved at a.at back out of the entry of key. The time of the
+// last failure is restored unless a later attempt has replaced it.
+func (a *Attempt) release(ctx context.Context, key string, before Entry) error {
+	_, err := a.guard.store.Update(ctx, key, func(e *Entry) {
+		if e.Failures > 0 {
+			e.Failures--
+		}
+		if e.LastFailure.Equal(a.at) {
+			e.LastFailure = before.LastFailure
+		}
+	})
+	return err
+}
+
+// Unlock lifts the lock of an account and clears its failures.
+func (g *Guard) Unlock(ctx context.Context, username string) error {
+	return g.store.Delete(ctx, UserKey(username))
+}
+
+// Prune forgets the counters that no longer slow anyone down: failures older than
+// ResetMinutes on keys that are not locked. Counters are kept for unknown usernames and
+// for every client address, so they have to be pruned to stay bounded.
+func (g *Guard) Prune(ctx context.Context, now time.Time) error {
+	return g.store.Prune(ctx, now.Add(-time.Duration(g.policy.ResetMinutes)*time.Minute))
+}
+
+// RunPruner prunes the counters every interval until ctx is cancelled.
+func (g *Guard) RunPruner(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := g.Prune(ctx, time.Now()); err != nil {
+			log.Printf("Login lockout: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (g *Guard) count(e *Entry, now time.Time) {
+	if now.Sub(e.LastFailure) > time.Duration(g.policy.ResetMinutes)*time.Minute {
+		e.Failures = 0
+	}
+	e.Failures++
+	e.LastFailure = now
+}
+
+// wait is how long to wait after the last failure: nothing for the first free failures,
+// then 1s, 2s, 4s and so on up to MaxBackoffSeconds.
+func (g *Guard) wait(e Entry, free int, now time.Time) time.Duration {
+	if e.Failures <= free || now.Sub(e.LastFailure) > time.Duration(g.policy.ResetMinutes)*time.Minute {
+		return 0
+	}
+	max := time.Duration(g.policy.MaxBackoffSeconds) * time.Second
+	delay := max
+	if exp := e.Failures - free - 1; exp < 30 {
+		if d := time.Second << exp; d < max {
+			delay = d
+		}
+	}
+	return e.LastFailure.Add(delay).Sub(now)
+}
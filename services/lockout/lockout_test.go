@@ -0,0 +1,238 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	MaxFailures:       5,
+	LockMinutes:       15,
+	FreeAttempts:      3,
+	IPFreeAttempts:    10,
+	MaxBackoffSeconds: 60,
+	ResetMinutes:      60,
+}
+
+func TestBeginReservesConcurrentAttempts(t *testing.T) {
+	g := NewGuard(NewMemory(), testPolicy)
+	ctx := context.Background()
+	now := time.Now()
+
+	// Guesses sent in parallel are all in flight before any of them fails, so they must
+	// be slowed down by each other's reservations, not only by settled failures.
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		admitted []*Attempt
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a, verdict, err := g.Begin(ctx, "alice", "10.0.0.1", now)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if verdict.Allowed() != (a != nil) {
+				t.Errorf("Begin() returned attempt %v with verdict %+v", a, verdict)
+			}
+			if a != nil {
+				mu.Lock()
+				admitted = append(admitted, a)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if want := testPolicy.FreeAttempts + 1; len(admitted) != want {
+		t.Fatalf("%d parallel attempts admitted, want %d", len(admitted), want)
+	}
+	for _, a := range admitted {
+		if _, err := a.Fail(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, verdict, _ := g.Begin(ctx, "alice", "10.0.0.2", now); verdict.Allowed() {
+		t.Error("attempt admitted right after the free failures were used up")
+	}
+}
+
+func TestReleaseGivesBackReservation(t *testing.T) {
+	store := NewMemory()
+	g := NewGuard(store, testPolicy)
+	ctx := context.Background()
+	start := time.Now().Truncate(time.Millisecond)
+
+	a, _, _ := g.Begin(ctx, "alice", "10.0.0.1", start)
+	a.Fail(ctx)
+	before, _ := store.Get(ctx, UserKey("alice"))
+
+	a, _, _ = g.Begin(ctx, "alice", "10.0.0.1", start.Add(time.Second))
+	if e, _ := store.Get(ctx, UserKey("alice")); e.Failures != 2 {
+		t.Fatalf("Failures while in flight = %d, want 2", e.Failures)
+	}
+	if err := a.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := store.Get(ctx, UserKey("alice")); after != before {
+		t.Errorf("entry after Release() = %+v, want %+v", after, before)
+	}
+	if e, _ := store.Get(ctx, IPKey("10.0.0.1")); e.Failures != 1 || !e.LastFailure.Equal(start) {
+		t.Errorf("IP entry after Release() = %+v, want the first failure only", e)
+	}
+
+	// Settling twice has no further effect, so Release can be deferred.
+	if locked, err := a.Fail(ctx); locked || err != nil {
+		t.Errorf("Fail() after Release() = %v, %v", locked, err)
+	}
+	if e, _ := store.Get(ctx, UserKey("alice")); e.Failures != 1 {
+		t.Errorf("Failures after a second settle = %d, want 1", e.Failures)
+	}
+}
+
+func TestFailLocksAccount(t *testing.T) {
+	g := NewGuard(NewMemory(), testPolicy)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 1; i <= testPolicy.MaxFailures; i++ {
+		// Step past any backoff so only the failure count matters.
+		now = now.Add(time.Duration(testPolicy.MaxBackoffSeconds) * time.Second)
+		a, verdict, err := g.Begin(ctx, "Alice", "10.0.0.1", now)
+		if err != nil || a == nil {
+			t.Fatalf("attempt %d refused: %+v, %v", i, verdict, err)
+		}
+		locked, err := a.Fail(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked != (i == testPolicy.MaxFailures) {
+			t.Errorf("Fail() %d locked = %v", i, locked)
+		}
+	}
+
+	_, verdict, _ := g.Begin(ctx, "alice", "10.0.0.2", now.Add(time.Minute))
+	if !verdict.Locked || verdict.RetryAfter != 14*time.Minute {
+		t.Errorf("verdict while locked = %+v, want locked for 14m", verdict)
+	}
+	if _, verdict, _ := g.Begin(ctx, "alice", "10.0.0.2", now.Add(16*time.Minute)); !verdict.Allowed() {
+		t.Errorf("verdict after the lock expired = %+v", verdict)
+	}
+
+	if err := g.Unlock(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, verdict, _ := g.Begin(ctx, "alice", "10.0.0.3", now.Add(time.Minute)); !verdict.Allowed() {
+		t.Errorf("verdict after Unlock() = %+v", verdict)
+	}
+}
+
+func TestSucceedKeepsIPFailures(t *testing.T) {
+	store := NewMemory()
+	g := NewGuard(store, testPolicy)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		a, _, _ := g.Begin(ctx, "alice", "10.0.0.1", now)
+		a.Fail(ctx)
+	}
+	a, _, _ := g.Begin(ctx, "alice", "10.0.0.1", now)
+	if err := a.Succeed(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, _ := store.Get(ctx, UserKey("alice")); e.Failures != 0 {
+		t.Errorf("user failures after Succeed() = %d, want 0", e.Failures)
+	}
+	if e, _ := store.Get(ctx, IPKey("10.0.0.1")); e.Failures != 2 {
+		t.Errorf("IP failures after Succeed() = %d, want the 2 earlier failures", e.Failures)
+	}
+}
+
+func TestIPThrottleReleasesUserReservation(t *testing.T) {
+	store := NewMemory()
+	g := NewGuard(store, testPolicy)
+	ctx := context.Background()
+	now := time.Now()
+
+	// Spread guesses over many usernames from one address.
+	for i := 0; i <= testPolicy.IPFreeAttempts; i++ {
+		a, _, _ := g.Begin(ctx, "user"+string(rune('a'+i)), "10.0.0.1", now)
+		if a == nil {
+			t.Fatalf("attempt %d refused", i)
+		}
+		a.Fail(ctx)
+	}
+
+	a, verdict, err := g.Begin(ctx, "bob", "10.0.0.1", now)
+	if err != nil || a != nil || verdict.Allowed() || verdict.Locked {
+		t.Fatalf("Begin() from a throttled IP = %v, %+v, %v", a, verdict, err)
+	}
+	if e, _ := store.Get(ctx, UserKey("bob")); e.Failures != 0 {
+		t.Errorf("refused attempt left %d failures on the username", e.Failures)
+	}
+}
+
+func TestPruneForgetsStaleCounters(t *testing.T) {
+	store := NewMemory()
+	g := NewGuard(store, testPolicy)
+	ctx := context.Background()
+	now := time.Now()
+	stale := now.Add(-2 * time.Hour)
+
+	a, _, _ := g.Begin(ctx, "ghost", "10.0.0.1", stale)
+	a.Fail(ctx)
+	a, _, _ = g.Begin(ctx, "alice", "10.0.0.2", now.Add(-time.Minute))
+	a.Fail(ctx)
+	store.Update(ctx, UserKey("locked"), func(e *Entry) {
+		e.LastFailure = stale
+		e.LockedUntil = now.Add(time.Minute)
+	})
+
+	if err := g.Prune(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	for key, kept := range map[string]bool{
+		UserKey("ghost"):  false,
+		IPKey("10.0.0.1"): false,
+		UserKey("alice"):  true,
+		IPKey("10.0.0.2"): true,
+		UserKey("locked"): true,
+	} {
+		e, _ := store.Get(ctx, key)
+		if (e != Entry{}) != kept {
+			t.Errorf("%s kept = %v, want %v", key, e != Entry{}, kept)
+		}
+	}
+}
+
+func TestPrefixedStoreKeepsCountersApart(t *testing.T) {
+	store := NewMemory()
+	logins := NewGuard(store, testPolicy)
+	resets := NewGuard(Prefixed(store, "reset:"), testPolicy)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i <= testPolicy.FreeAttempts; i++ {
+		a, _, _ := resets.Begin(ctx, "alice", "10.0.0.1", now)
+		if a == nil {
+			t.Fatalf("reset %d refused", i)
+		}
+		a.Fail(ctx)
+	}
+	if _, verdict, _ := resets.Begin(ctx, "alice", "10.0.0.1", now); verdict.Allowed() {
+		t.Error("reset admitted after the free attempts were used up")
+	}
+	if _, verdict, _ := logins.Begin(ctx, "alice", "10.0.0.1", now); !verdict.Allowed() {
+		t.Errorf("login refused by the reset counters: %+v", verdict)
+	}
+	if e, _ := store.Get(ctx, "reset:"+UserKey("alice")); e.Failures != testPolicy.FreeAttempts+1 {
+		t.Errorf("reset failures = %d, want %d", e.Failures, testPolicy.FreeAttempts+1)
+	}
+}
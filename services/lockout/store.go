@@ -0,0 +1,107 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Entry is the failed-login record of one key, a username or a client IP.
+type Entry struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store keeps failed-login counters. Use the database store when several instances
+// serve logins, so an attacker cannot spread guesses across them.
+type Store interface {
+	// Get returns the entry of a key; unknown keys have a zero entry.
+	Get(ctx context.Context, key string) (Entry, error)
+	// Update applies fn to the entry of a key atomically and returns the result.
+	Update(ctx context.Context, key string, fn func(*Entry)) (Entry, error)
+	// Delete forgets a key.
+	Delete(ctx context.Context, key string) error
+	// Prune forgets the entries that are not locked and have not failed since before.
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// Memory is a Store local to one process.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]Entry)}
+}
+
+// memoryPruneAt is the size from which stale entries are dropped on update.
+const memoryPruneAt = 10000
+
+func (m *Memory) Get(ctx context.Context, key string) (Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.entries[key], nil
+}
+
+func (m *Memory) Update(ctx context.Context, key string, fn func(*Entry)) (Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.entries) >= memoryPruneAt {
+		m.prune(time.Now().Add(-24 * time.Hour))
+	}
+
+	e := m.entries[key]
+	fn(&e)
+	m.entries[key] = e
+	return e, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *Memory) Prune(ctx context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(before)
+	return nil
+}
+
+// prune drops entries that are not locked and have not failed since before.
+func (m *Memory) prune(before time.Time) {
+	now := time.Now()
+	for key, e := range m.entries {
+		if e.LastFailure.Before(before) && !e.LockedUntil.After(now) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+// Prefixed namespaces the keys of a store, so a second Guard with its own policy can share
+// it without touching the login counters. Prune is passed through and covers the whole store.
+func Prefixed(store Store, prefix string) Store {
+	return &prefixed{Store: store, prefix: prefix}
+}
+
+type prefixed struct {
+	Store
+	prefix string
+}
+
+func (p *prefixed) Get(ctx context.Context, key string) (Entry, error) {
+	return p.Store.Get(ctx, p.prefix+key)
+}
+
+func (p *prefixed) Update(ctx context.Context, key string, fn func(*Entry)) (Entry, error) {
+	return p.Store.Update(ctx, p.prefix+key, fn)
+}
+
+func (p *prefixed) Delete(ctx context.Context, key string) error {
+	return p.Store.Delete(ctx, p.prefix+key)
+}
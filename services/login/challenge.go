@@ -0,0 +1,119 @@
+package login
+
+import (
+	"errors"
+	"net/http"
+	"storage/configuration"
+	"storage/services/session"
+	"storage/services/twofactor"
+	"storage/services/user"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Limits of the second login step.
+const (
+	challengeTTL         = 5 * time.Minute
+	maxChallengeAttempts = 5
+)
+
+// LoginChallenge proves that the password step of a two-factor login succeeded.
+// Only the SHA-256 of the challenge token is stored.
+type LoginChallenge struct {
+	ID        uint      `gorm:"primaryKey"`
+	TokenHash string    `gorm:"not null;size:64;uniqueIndex"`
+	UserID    int64     `gorm:"not null;index"`
+	Attempts  int       `gorm:"not null;default:0"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+func (LoginChallenge) TableName() string {
+	return "hall_res_project.login_challenges"
+}
+
+var errInvalidChallenge = errors.New("invalid or expired login challenge")
+
+func newChallenge(db *gorm.DB, userID int64) (string, error) {
+	token, err := session.RandomToken()
+	if err != nil {
+		return "", err
+	}
+	err = db.Create(&LoginChallenge{
+		TokenHash: session.Hash(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(challengeTTL),
+	}).Error
+	return token, err
+}
+
+// TwoFactorHandler completes a two-factor login with a TOTP or recovery code.
+// Wrong codes count as failed logins, so guessing codes is throttled like guessing passwords.
+func TwoFactorHandler(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Challenge string `json:"challenge" binding:"required"`
+			Code      string `json:"code" binding:"required"` // TOTP code or recovery code
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		now := time.Now()
+		var challenge LoginChallenge
+		err := conf.Db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("token_hash = ?", session.Hash(req.Challenge)).First(&challenge).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidChallenge
+			}
+			if err != nil {
+				return err
+			}
+			if !now.Before(challenge.ExpiresAt) || challenge.Attempts >= maxChallengeAttempts {
+				return errInvalidChallenge
+			}
+			return tx.Model(&challenge).Update("attempts", challenge.Attempts+1).Error
+		})
+		if errors.Is(err, errInvalidChallenge) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Login challenge is invalid or expired, log in again"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify login challenge"})
+			return
+		}
+
+		var dbUser user.User
+		if err := conf.Db.Preload("Roles").Where("id = ? AND is_active = ?", challenge.UserID, true).First(&dbUser).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+
+		username := strings.ToLower(dbUser.Username)
+		attempt, ok := beginAttempt(c, conf, username)
+		if !ok {
+			return
+		}
+		defer attempt.Release(c.Request.Context())
+
+		err = twofactor.Verify(conf.Db, dbUser.UserID, req.Code, now)
+		if errors.Is(err, twofactor.ErrInvalidCode) {
+			loginFailed(c, conf, attempt, username, &dbUser)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify code"})
+			return
+		}
+
+		// The challenge is spent.
+		conf.Db.Delete(&challenge)
+		completeLogin(c, conf, attempt, &dbUser, false)
+	}
+}
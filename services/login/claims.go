@@ -0,0 +1,73 @@
+package login
+
+import (
+	"errors"
+	"storage/configuration"
+	"storage/services/signing"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims is the payload of an access token. Subject holds the user ID as a string,
+// as the JWT spec requires, and UserID the same value as a number; ID (jti) is the
+// session the token belongs to.
+type Claims struct {
+	Username string `json:"username"`
+	UserID   int64  `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+// Validate checks the claims that the registered-claims validation does not cover.
+// It is called by the jwt parser after the signature and time checks.
+func (c Claims) Validate() error {
+	if c.UserID <= 0 || c.Subject != strconv.FormatInt(c.UserID, 10) {
+		return errors.New("subject does not match user ID")
+	}
+	if c.Username == "" {
+		return errors.New("missing username")
+	}
+	if c.ID == "" {
+		return errors.New("missing session ID")
+	}
+	return nil
+}
+
+// NewClaims builds the claims of an access token for a user's session.
+func NewClaims(cfg *configuration.Auth, userID int64, username, sessionID string, now time.Time) Claims {
+	return Claims{
+		Username: username,
+		UserID:   userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatInt(userID, 10),
+			Issuer:    cfg.TokenIssuer(),
+			Audience:  jwt.ClaimStrings{cfg.TokenAudience()},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(cfg.AccessTokenTTL())),
+			ID:        sessionID,
+		},
+	}
+}
+
+// ParseToken verifies an access token and returns its claims. Besides the signature, by
+// the key named in its kid header, it requires the expected algorithm, issuer and
+// audience, and exp and iat claims.
+func ParseToken(cfg *configuration.Auth, keys *signing.KeySet, tokenString string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, keys.Keyfunc,
+		jwt.WithValidMethods(keys.Algorithms()),
+		jwt.WithIssuer(cfg.TokenIssuer()),
+		jwt.WithAudience(cfg.TokenAudience()),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.IssuedAt == nil {
+		return nil, errors.New("missing issued-at claim")
+	}
+	return &claims, nil
+}
@@ -1,36 +1,33 @@
 package login
 
 import (
+	"log"
+	"math"
 	"net/http"
-	"os"
 	"storage/configuration"
+	"storage/services/audit"
+	"storage/services/lockout"
+	"storage/services/session"
+	"storage/services/twofactor"
 	"storage/services/user"
+	"strconv"
 	"strings"
-
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // User struct to represent the expected request body
 type User struct {
-	Username string `json:"username"`
+	Username string `json:"username" binding:"max=50"` // No account has a longer name
 	Password string `json:"password"`
 }
 
-// Claims struct to include within the JWT token
-type Claims struct {
-	Username string `json:"username"`
-	jwt.RegisteredClaims
-}
-
 // LoginHandler handles the login requests
 func LoginHandler(conf *configuration.Dependencies) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var inputUser User
-		jwtKey := os.Getenv("JWT_SECRET_KEY")
 		if err := c.ShouldBindJSON(&inputUser); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
 			return
@@ -38,33 +35,136 @@ func LoginHandler(conf *configuration.Dependencies) gin.HandlerFunc {
 
 		inputUser.Username = strings.TrimSpace(strings.ToLower(inputUser.Username))
 
-		var dbUser user.User
-		if err := conf.Db.Preload("Roles").Where("lower(username) = ?", inputUser.Username).First(&dbUser).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		attempt, ok := beginAttempt(c, conf, inputUser.Username)
+		if !ok {
 			return
 		}
+		// Gives the reservation back unless the attempt is settled below.
+		defer attempt.Release(c.Request.Context())
 
-		if err := bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte(inputUser.Password)); err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		var dbUser user.User
+		err := conf.Db.Preload("Roles").Where("lower(username) = ?", inputUser.Username).First(&dbUser).Error
+		if err == nil {
+			err = bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte(inputUser.Password))
+		}
+		if err != nil {
+			loginFailed(c, conf, attempt, inputUser.Username, &dbUser)
 			return
 		}
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"username": inputUser.Username,
-			"exp":      time.Now().Add(time.Hour * 1).Unix(),
-		})
-
-		tokenString, err := token.SignedString([]byte(jwtKey))
+		// Checked after the password, so the answer reveals nothing to someone guessing.
+		if !dbUser.IsActive {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
+			return
+		}
 
+		enrolled, err := twofactor.Enrolled(conf.Db, dbUser.UserID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create token"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not check two-factor authentication"})
+			return
+		}
+		if enrolled {
+			// Second step: the client answers the challenge at /api/login/2fa.
+			challenge, err := newChallenge(conf.Db, dbUser.UserID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start two-factor login"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"two_factor_required": true,
+				"challenge":           challenge,
+				"expires_in":          int(challengeTTL.Seconds()),
+			})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"username": inputUser.Username,
-			"token":    tokenString,
-			"roles":    dbUser.Roles,
-		})
+		completeLogin(c, conf, attempt, &dbUser, twofactor.Required(dbUser.Roles))
+	}
+}
+
+// completeLogin clears the failed logins of a user who proved their identity, starts a
+// session and answers with the token pair. Users who still have to set up a required 2FA
+// can only reach the enrollment endpoints with it.
+func completeLogin(c *gin.Context, conf *configuration.Dependencies, attempt *lockout.Attempt, dbUser *user.User, setupRequired bool) {
+	username := strings.ToLower(dbUser.Username)
+	if err := attempt.Succeed(c.Request.Context()); err != nil {
+		log.Printf("Failed to clear failed logins of %q: %v", username, err)
+	}
+
+	sess, refreshToken, err := session.Create(conf.Db, dbUser.UserID, c.Request.UserAgent(), c.ClientIP(), conf.Cfg.Auth.RefreshTokenTTL())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create session"})
+		return
+	}
+
+	tokenString, err := accessToken(conf, dbUser, sess.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create token"})
+		return
+	}
+
+	now := time.Now()
+	if err := conf.Db.Model(dbUser).UpdateColumn("last_login", now).Error; err != nil {
+		log.Printf("Failed to update last login of user %d: %v", dbUser.UserID, err)
+	}
+	audit.RecordLogin(conf.Db, audit.LoginRecord{
+		UserID:    dbUser.UserID,
+		SessionID: sess.ID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		CreatedAt: now,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"username":                  username,
+		"token":                     tokenString,
+		"refresh_token":             refreshToken,
+		"expires_in":                int(conf.Cfg.Auth.AccessTokenTTL().Seconds()),
+		"roles":                     dbUser.Roles,
+		"two_factor_setup_required": setupRequired,
+	})
+}
+
+// beginAttempt admits a login attempt for username, or answers the request and returns
+// false while the account is locked or the client has to wait after failed logins.
+func beginAttempt(c *gin.Context, conf *configuration.Dependencies, username string) (*lockout.Attempt, bool) {
+	attempt, verdict, err := conf.Logins.Begin(c.Request.Context(), username, c.ClientIP(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify login attempt"})
+		return nil, false
+	}
+	if !verdict.Allowed() {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(verdict.RetryAfter.Seconds()))))
+		if verdict.Locked {
+			c.JSON(http.StatusLocked, gin.H{"error": "Account is temporarily locked after too many failed logins"})
+		} else {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed logins, try again later"})
+		}
+		return nil, false
+	}
+	return attempt, true
+}
+
+// loginFailed settles an attempt as a failed login and answers it. u is empty if the
+// username is unknown.
+func loginFailed(c *gin.Context, conf *configuration.Dependencies, attempt *lockout.Attempt, username string, u *user.User) {
+	locked, err := attempt.Fail(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to record failed login of %q: %v", username, err)
 	}
+	if locked {
+		event := audit.AuthEvent{Type: audit.EventAccountLocked, Username: username, IP: c.ClientIP(), Detail: "too many failed logins"}
+		if u.UserID != 0 {
+			event.UserID = &u.UserID
+		}
+		audit.Record(conf.Db, event)
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+}
+
+// accessToken signs a short-lived access token for a session. Its jti is the session ID,
+// which AuthMiddleware checks so that revoked sessions lose access immediately.
+func accessToken(conf *configuration.Dependencies, u *user.User, sessionID string) (string, error) {
+	claims := NewClaims(&conf.Cfg.Auth, u.UserID, strings.ToLower(u.Username), sessionID, time.Now())
+	return conf.Keys.Sign(claims)
 }
@@ -0,0 +1,18 @@
+package login
+
+import (
+	"net/http"
+	"storage/configuration"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler publishes the public keys that verify access tokens, including keys
+// scheduled to start signing later, so other services can verify tokens on their own.
+func JWKSHandler(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, conf.Keys.JWKS(time.Now()))
+	}
+}
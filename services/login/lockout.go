@@ -0,0 +1,44 @@
+package login
+
+import (
+	"net/http"
+	"storage/configuration"
+	"storage/services/audit"
+	"storage/services/auth"
+	"storage/services/user"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnlockAccount lifts a lockout caused by failed logins before it expires.
+func UnlockAccount(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var u user.User
+		if err := conf.Db.Where("id = ?", userID).First(&u).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		username := strings.ToLower(u.Username)
+		if err := conf.Logins.Unlock(c.Request.Context(), username); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+			return
+		}
+
+		event := audit.AuthEvent{Type: audit.EventAccountUnlocked, Username: username, UserID: &u.UserID, IP: c.ClientIP()}
+		if principal, ok := auth.CurrentPrincipal(c); ok {
+			event.ActorID = &principal.UserID
+		}
+		audit.Record(conf.Db, event)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Account unlocked successfully"})
+	}
+}
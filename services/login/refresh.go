@@ -0,0 +1,74 @@
+package login
+
+import (
+	"errors"
+	"net/http"
+	"storage/configuration"
+	"storage/services/auth"
+	"storage/services/session"
+	"storage/services/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RefreshHandler exchanges a refresh token for a new access token and a new refresh token.
+// Every refresh token works once; presenting a spent one revokes the session.
+func RefreshHandler(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			RefreshToken string `json:"refresh_token" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		sess, refreshToken, err := session.Rotate(conf.Db, req.RefreshToken, conf.Cfg.Auth.RefreshTokenTTL())
+		if errors.Is(err, session.ErrInvalidToken) || errors.Is(err, session.ErrTokenReuse) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not refresh session"})
+			return
+		}
+
+		var dbUser user.User
+		if err := conf.Db.Preload("Roles").Where("id = ? AND is_active = ?", sess.UserID, true).First(&dbUser).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+
+		tokenString, err := accessToken(conf, &dbUser, sess.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"username":      dbUser.Username,
+			"token":         tokenString,
+			"refresh_token": refreshToken,
+			"expires_in":    int(conf.Cfg.Auth.AccessTokenTTL().Seconds()),
+			"roles":         dbUser.Roles,
+		})
+	}
+}
+
+// LogoutHandler revokes the session of the access token used for the request,
+// together with its refresh tokens.
+func LogoutHandler(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.CurrentPrincipal(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if err := session.Revoke(conf.Db, principal.SessionID, session.ReasonLogout); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	}
+}
@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+)
+
+// ErrInvalidAddress is returned for messages without a usable recipient.
+var ErrInvalidAddress = errors.New("invalid email address")
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email. Handlers do not call it directly; they queue messages with
+// Enqueue and the outbox worker hands them to the sender.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Log is a Sender that only writes messages to the application log, for development.
+type Log struct{}
+
+func NewLog() *Log {
+	return &Log{}
+}
+
+func (l *Log) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	log.Printf("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// validate rejects recipients and subjects that could inject extra headers.
+func validate(msg Message) error {
+	if msg.To == "" || !strings.Contains(msg.To, "@") || strings.ContainsAny(msg.To, "\r\n<>,;") {
+		return ErrInvalidAddress
+	}
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return errors.New("subject must be a single line")
+	}
+	return nil
+}
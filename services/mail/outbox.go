@@ -0,0 +1,125 @@
+package mail
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxAttempts is how often delivery of a message is tried before it is given up.
+const MaxAttempts = 5
+
+// OutboxMessage is an email waiting to be sent. Writing it in the same transaction as
+// the change that triggers it means no email is lost or sent for a rolled-back change.
+type OutboxMessage struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	To            string     `gorm:"column:recipient;not null;size:255" json:"to"`
+	Subject       string     `gorm:"not null;size:255" json:"subject"`
+	Body          string     `gorm:"type:text" json:"-"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	LastError     string     `gorm:"size:500" json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `gorm:"not null;index" json:"next_attempt_at"`
+	SentAt        *time.Time `gorm:"index" json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "hall_res_project.mail_outbox"
+}
+
+// Enqueue stores a message for delivery by the outbox worker.
+func Enqueue(db *gorm.DB, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	return db.Create(&OutboxMessage{
+		To:            msg.To,
+		Subject:       msg.Subject,
+		Body:          msg.Body,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// claimTimeout is how long a claimed message is left to its worker. A message whose
+// worker died mid-send becomes due again once it passes.
+const claimTimeout = 10 * time.Minute
+
+// Deliver sends up to limit due messages. Failed messages are retried with exponential
+// backoff until MaxAttempts is reached. It returns the number of messages sent.
+//
+// Messages are claimed before they are sent: the due rows are locked with SKIP LOCKED,
+// so concurrent workers pick disjoint rows, and their attempt is counted and their next
+// attempt pushed past claimTimeout before the lock is released. Another worker therefore
+// never sends a message that is already being sent.
+func Deliver(ctx context.Context, db *gorm.DB, sender Sender, limit int) (int, error) {
+	var due []OutboxMessage
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("sent_at IS NULL AND attempts < ? AND next_attempt_at <= ?", MaxAttempts, now).
+			Order("id").Limit(limit).Find(&due).Error
+		if err != nil || len(due) == 0 {
+			return err
+		}
+		ids := make([]uint, len(due))
+		for i := range due {
+			ids[i] = due[i].ID
+			due[i].Attempts++
+		}
+		return tx.Model(&OutboxMessage{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": now.Add(claimTimeout),
+		}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, m := range due {
+		err := sender.Send(ctx, Message{To: m.To, Subject: m.Subject, Body: m.Body})
+		now := time.Now()
+		updates := map[string]interface{}{}
+		if err != nil {
+			updates["last_error"] = truncate(err.Error(), 500)
+			updates["next_attempt_at"] = now.Add(time.Minute << (m.Attempts - 1))
+			log.Printf("Failed to send mail %d: %v", m.ID, err)
+		} else {
+			updates["sent_at"] = now
+			updates["last_error"] = ""
+			sent++
+		}
+		// The attempt count only matches while the claim is ours; if it expired and
+		// another worker took the message over, its outcome is the one recorded.
+		if err := db.Model(&OutboxMessage{}).Where("id = ? AND attempts = ?", m.ID, m.Attempts).Updates(updates).Error; err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// RunOutbox delivers queued mail every interval until ctx is cancelled.
+func RunOutbox(ctx context.Context, db *gorm.DB, sender Sender, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := Deliver(ctx, db, sender, 50); err != nil {
+			log.Printf("Mail outbox: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,138 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPConfig points at the relay used for outgoing mail. A local stand-in such as
+// MailHog (host localhost, port 1025, no credentials) works for development.
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"` // e.g. "Hall Reservations <no-reply@example.com>"
+}
+
+// Time limits of a delivery, so an unresponsive relay cannot hold up the outbox.
+const (
+	smtpDialTimeout = 10 * time.Second
+	smtpSendTimeout = time.Minute // For the whole conversation, including the dial
+)
+
+// SMTP sends mail through an SMTP relay, upgrading to TLS when the server offers STARTTLS.
+type SMTP struct {
+	cfg         SMTPConfig
+	envelope    string // Bare address of From, used for MAIL FROM
+	dialTimeout time.Duration
+	sendTimeout time.Duration
+}
+
+func NewSMTP(cfg SMTPConfig) (*SMTP, error) {
+	if cfg.Host == "" || cfg.From == "" {
+		return nil, errors.New("smtp: host and from are required")
+	}
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+	from, err := netmail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("smtp: invalid from address: %w", err)
+	}
+	return &SMTP{cfg: cfg, envelope: from.Address, dialTimeout: smtpDialTimeout, sendTimeout: smtpSendTimeout}, nil
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.sendTimeout)
+	defer cancel()
+
+	dialer := net.Dialer{Timeout: s.dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.cfg.Host, s.cfg.Port))
+	if err != nil {
+		return err
+	}
+	// The deadline bounds every read and write; closing the connection on cancellation
+	// also interrupts a conversation that is stuck before it.
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	err = s.deliver(conn, msg)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// deliver holds the SMTP conversation for one message over conn, as smtp.SendMail does.
+func (s *SMTP) deliver(conn net.Conn, msg Message) error {
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return err
+		}
+	}
+	if s.cfg.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server does not support AUTH")
+		}
+		// PlainAuth refuses to send credentials without TLS, except to localhost.
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(s.envelope); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.compose(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (s *SMTP) compose(msg Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	// Normalise line endings; SMTP requires CRLF.
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
@@ -0,0 +1,259 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpServer is a minimal in-process SMTP server that records what it receives. It
+// offers AUTH PLAIN but not STARTTLS, like a local development relay.
+type smtpServer struct {
+	listener net.Listener
+	reject   string // RCPT address answered with a permanent failure
+
+	mu   sync.Mutex
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{listener: l}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		s.mu.Lock()
+		switch verb {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			fields := strings.Fields(line)
+			cred, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			s.auth = string(cred)
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			s.from = strings.TrimPrefix(line, "MAIL FROM:")
+			reply("250 OK")
+		case "RCPT":
+			to := strings.TrimPrefix(line, "RCPT TO:")
+			if s.reject != "" && to == "<"+s.reject+">" {
+				reply("550 5.1.1 No such user")
+				break
+			}
+			s.to = append(s.to, to)
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					s.mu.Unlock()
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.data = data.String()
+			reply("250 OK queued")
+		case "RSET", "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			s.mu.Unlock()
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *smtpServer) sender(t *testing.T, username, password string) *SMTP {
+	t.Helper()
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	sender, err := NewSMTP(SMTPConfig{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		From:     "Hall Reservations <no-reply@example.com>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sender
+}
+
+func TestSMTPSend(t *testing.T) {
+	server := newSMTPServer(t)
+	sender := server.sender(t, "relay-user", "relay-pass")
+
+	err := sender.Send(context.Background(), Message{
+		To:      "guest@example.com",
+		Subject: "Reservation confirmed",
+		Body:    "Hello,\nyour reservation is confirmed.\n.\nSee you soon.",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.auth != "\x00relay-user\x00relay-pass" {
+		t.Errorf("AUTH PLAIN credentials = %q", server.auth)
+	}
+	if server.from != "<no-reply@example.com>" {
+		t.Errorf("MAIL FROM = %q, want the bare From address", server.from)
+	}
+	if len(server.to) != 1 || server.to[0] != "<guest@example.com>" {
+		t.Errorf("RCPT TO = %v", server.to)
+	}
+
+	headers, body, ok := strings.Cut(server.data, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("message has no header/body separator:\n%q", server.data)
+	}
+	for _, want := range []string{
+		"From: Hall Reservations <no-reply@example.com>\r\n",
+		"To: guest@example.com\r\n",
+		"Subject: Reservation confirmed\r\n",
+		"Content-Type: text/plain; charset=UTF-8\r\n",
+		"Date: ",
+	} {
+		if !strings.Contains(headers+"\r\n", want) {
+			t.Errorf("headers do not contain %q:\n%s", want, headers)
+		}
+	}
+	// Bare newlines become CRLF, and the lone dot is escaped so it does not end DATA.
+	if want := "Hello,\r\nyour reservation is confirmed.\r\n..\r\nSee you soon.\r\n"; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestSMTPSendWithoutCredentials(t *testing.T) {
+	server := newSMTPServer(t)
+	sender := server.sender(t, "", "")
+
+	if err := sender.Send(context.Background(), Message{To: "guest@example.com", Subject: "Hi", Body: "x"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.auth != "" {
+		t.Errorf("AUTH was used without configured credentials")
+	}
+}
+
+func TestSMTPSendErrors(t *testing.T) {
+	server := newSMTPServer(t)
+	server.reject = "nobody@example.com"
+	sender := server.sender(t, "", "")
+	ctx := context.Background()
+
+	err := sender.Send(ctx, Message{To: "nobody@example.com", Subject: "Hi", Body: "x"})
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Errorf("Send() to a rejected recipient error = %v, want the 550 reply", err)
+	}
+
+	for _, msg := range []Message{
+		{To: "guest@example.com\r\nBcc: victim@example.com", Subject: "Hi"},
+		{To: "a@example.com, b@example.com", Subject: "Hi"},
+		{To: "not-an-address", Subject: "Hi"},
+	} {
+		if err := sender.Send(ctx, msg); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("Send(To: %q) error = %v, want ErrInvalidAddress", msg.To, err)
+		}
+	}
+	if err := sender.Send(ctx, Message{To: "guest@example.com", Subject: "Hi\r\nBcc: victim@example.com"}); err == nil {
+		t.Error("Send() accepted a subject with a line break")
+	}
+}
+
+// silentServer accepts connections but never greets, like a hung relay.
+func silentServer(t *testing.T) *SMTP {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	sender, err := NewSMTP(SMTPConfig{Host: host, Port: port, From: "no-reply@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sender
+}
+
+func TestSMTPSendHonoursContext(t *testing.T) {
+	sender := silentServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err := sender.Send(ctx, Message{To: "guest@example.com", Subject: "Hi", Body: "x"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestSMTPSendTimesOut(t *testing.T) {
+	sender := silentServer(t)
+	sender.sendTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	err := sender.Send(context.Background(), Message{To: "guest@example.com", Subject: "Hi", Body: "x"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Send() returned after %v, want the 100ms send timeout", elapsed)
+	}
+}
@@ -0,0 +1,66 @@
+package notification
+
+import (
+	"net/http"
+	"storage/configuration"
+	"storage/models"
+	"storage/services/auth"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Notify records a message for the owner of a reservation.
+func Notify(db *gorm.DB, r *models.Reservation, message string) error {
+	id := r.ID
+	return db.Create(&models.Notification{UserID: r.UserID, ReservationID: &id, Message: message}).Error
+}
+
+// GetNotifications lists the notifications of the authenticated user, newest first.
+// unread=true limits the list to notifications not yet marked as read.
+func GetNotifications(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.CurrentPrincipal(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		query := conf.Db.Where("user_id = ?", principal.UserID)
+		if c.Query("unread") == "true" {
+			query = query.Where("read_at IS NULL")
+		}
+
+		var notifications []models.Notification
+		if err := query.Order("created_at desc").Find(&notifications).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications"})
+			return
+		}
+		c.JSON(http.StatusOK, notifications)
+	}
+}
+
+// MarkNotificationRead marks one of the authenticated user's notifications as read.
+func MarkNotificationRead(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.CurrentPrincipal(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		result := conf.Db.Model(&models.Notification{}).
+			Where("id = ? AND user_id = ?", c.Param("id"), principal.UserID).
+			Update("read_at", time.Now())
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+	}
+}
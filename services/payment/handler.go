@@ -0,0 +1,343 @@
+package payment
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"storage/configuration"
+	"storage/models"
+	"storage/services/payment/provider"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errReservationCancelled = errors.New("reservation has been cancelled")
+	errNothingToPay         = errors.New("nothing left to pay")
+	errExceedsBalance       = errors.New("amount exceeds the balance due")
+)
+
+// SignatureHeader carries the provider signature of a webhook payload.
+const SignatureHeader = "X-Payment-Signature"
+
+// GetLedger returns the payment ledger of a reservation.
+func GetLedger(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var reservation models.Reservation
+		if err := conf.Db.First(&reservation, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+			return
+		}
+
+		ledger, err := loadLedger(conf.Db, &reservation)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payments"})
+			return
+		}
+
+		c.JSON(http.StatusOK, ledger)
+	}
+}
+
+// CreatePayment collects a deposit or a (partial) payment for a reservation through the configured provider.
+func CreatePayment(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Kind   string  `json:"kind"`
+			Amount float64 `json:"amount"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if req.Kind == "" {
+			req.Kind = models.PaymentKindPayment
+		}
+		if req.Kind != models.PaymentKindDeposit && req.Kind != models.PaymentKindPayment {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Kind must be deposit or payment"})
+			return
+		}
+
+		// A payment is made in three steps, so money is never collected without a record of it.
+		// First a pending payment is reserved while the reservation is locked, so concurrent
+		// requests cannot both charge the same outstanding balance. The provider is then
+		// charged outside any transaction, and the outcome is recorded against the payment's
+		// idempotency key. A payment whose outcome could not be recorded stays pending; charging
+		// it again with the same key returns the original result.
+		var payment models.Payment
+		var ledger models.PaymentLedger
+		err := conf.Db.Transaction(func(tx *gorm.DB) error {
+			var reservation models.Reservation
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, c.Param("id")).Error; err != nil {
+				return err
+			}
+			if reservation.IsCancelled() {
+				return errReservationCancelled
+			}
+
+			var err error
+			ledger, err = loadLedger(tx, &reservation)
+			if err != nil {
+				return err
+			}
+
+			// Default to the outstanding deposit or balance when no amount is given.
+			if req.Amount == 0 {
+				req.Amount = ledger.BalanceDue
+				if req.Kind == models.PaymentKindDeposit {
+					req.Amount = reservation.DepositAmount - (ledger.Paid - ledger.Refunded)
+				}
+			}
+			if req.Amount <= 0 {
+				return errNothingToPay
+			}
+			if req.Amount > ledger.BalanceDue-ledger.Pending {
+				return errExceedsBalance
+			}
+
+			key, err := newIdempotencyKey()
+			if err != nil {
+				return err
+			}
+			payment = models.Payment{
+				ReservationID:  reservation.ID,
+				Kind:           req.Kind,
+				Amount:         req.Amount,
+				Status:         models.PaymentStatusPending,
+				Provider:       conf.Payments.Name(),
+				IdempotencyKey: key,
+			}
+			return tx.Create(&payment).Error
+		})
+
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+			return
+		case errors.Is(err, errReservationCancelled):
+			c.JSON(http.StatusConflict, gin.H{"error": "Reservation has been cancelled"})
+			return
+		case errors.Is(err, errNothingToPay):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing left to pay"})
+			return
+		case errors.Is(err, errExceedsBalance):
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Amount exceeds the balance due of %.2f", ledger.BalanceDue-ledger.Pending)})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+			return
+		}
+
+		result, chargeErr := conf.Payments.Charge(c.Request.Context(), provider.Charge{
+			ReservationID:  payment.ReservationID,
+			Amount:         payment.Amount,
+			Currency:       conf.Cfg.Payments.Currency,
+			Description:    fmt.Sprintf("Reservation %d %s", payment.ReservationID, payment.Kind),
+			IdempotencyKey: payment.IdempotencyKey,
+		})
+
+		updates := map[string]interface{}{"status": models.PaymentStatusFailed}
+		if chargeErr == nil {
+			updates["status"] = result.Status
+			updates["provider_ref"] = result.Reference
+			if result.Status == models.PaymentStatusConfirmed {
+				updates["confirmed_at"] = time.Now()
+			}
+		}
+		err = conf.Db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(&models.Payment{}).
+				Where("idempotency_key = ? AND status = ?", payment.IdempotencyKey, models.PaymentStatusPending).
+				Updates(updates).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.First(&payment, payment.ID).Error; err != nil {
+				return err
+			}
+			ledger, err = SyncReservation(tx, payment.ReservationID)
+			return err
+		})
+
+		switch {
+		case chargeErr != nil:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider rejected the charge: " + chargeErr.Error()})
+			return
+		case err != nil:
+			log.Printf("Failed to record the outcome of payment %d (key %s): %v", payment.ID, payment.IdempotencyKey, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"payment": payment, "ledger": ledger})
+	}
+}
+
+// newIdempotencyKey returns a random key identifying a charge at the provider.
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// RefundPayment refunds all or part of a confirmed payment.
+func RefundPayment(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Amount float64 `json:"amount"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var original models.Payment
+		if err := conf.Db.First(&original, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			return
+		}
+		if original.Kind == models.PaymentKindRefund || original.Status != models.PaymentStatusConfirmed {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only confirmed payments can be refunded"})
+			return
+		}
+
+		left, err := refundable(conf.Db, &original)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payments"})
+			return
+		}
+		if req.Amount == 0 {
+			req.Amount = left
+		}
+		if req.Amount <= 0 || req.Amount > left {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Refund amount must be between 0 and %.2f", left)})
+			return
+		}
+
+		refundPayment, err := refund(c.Request.Context(), conf, &original, req.Amount)
+		if errors.Is(err, errRefundExceeds) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The payment has been refunded in the meantime"})
+			return
+		}
+		if errors.Is(err, errProviderRefund) {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record refund"})
+			return
+		}
+
+		ledger, err := SyncReservation(conf.Db, original.ReservationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Refund recorded, but failed to update reservation"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"payment": refundPayment, "ledger": ledger})
+	}
+}
+
+// PaymentWebhook receives payment confirmations from the provider named in the URL.
+// It is public; callbacks are authenticated by the provider signature instead.
+func PaymentWebhook(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Param("provider") != conf.Payments.Name() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown payment provider"})
+			return
+		}
+
+		payload, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read payload"})
+			return
+		}
+
+		event, err := conf.Payments.ParseWebhook(payload, c.GetHeader(SignatureHeader))
+		if errors.Is(err, provider.ErrInvalidSignature) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if event.Status != models.PaymentStatusConfirmed && event.Status != models.PaymentStatusFailed {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported payment status"})
+			return
+		}
+
+		var payment models.Payment
+		if err := conf.Db.Where("provider = ? AND provider_ref = ?", conf.Payments.Name(), event.Reference).First(&payment).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			return
+		}
+
+		// Providers may deliver the same callback more than once, even concurrently. Only the
+		// delivery that moves the payment out of pending acts on it.
+		updates := map[string]interface{}{"status": event.Status}
+		if event.Status == models.PaymentStatusConfirmed {
+			now := time.Now()
+			payment.ConfirmedAt = &now
+			updates["confirmed_at"] = now
+		}
+		res := conf.Db.Model(&models.Payment{}).
+			Where("id = ? AND status = ?", payment.ID, models.PaymentStatusPending).
+			Updates(updates)
+		if res.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment"})
+			return
+		}
+		if res.RowsAffected == 0 {
+			c.JSON(http.StatusOK, gin.H{"message": "Payment already processed"})
+			return
+		}
+		payment.Status = event.Status
+
+		// A charge that was still pending when its reservation was cancelled is returned straight away.
+		if payment.Status == models.PaymentStatusConfirmed && payment.Kind != models.PaymentKindRefund {
+			var reservation models.Reservation
+			if err := conf.Db.First(&reservation, payment.ReservationID).Error; err == nil && reservation.IsCancelled() {
+				left, err := refundable(conf.Db, &payment)
+				if err == nil && left > 0 {
+					_, err = refund(c.Request.Context(), conf, &payment, left)
+				}
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refund payment of cancelled reservation"})
+					return
+				}
+			}
+		}
+
+		if _, err := SyncReservation(conf.Db, payment.ReservationID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reservation"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Payment updated"})
+	}
+}
+
+// GetOverdueDeposits lists reservations whose deposit is still unpaid past its due date.
+func GetOverdueDeposits(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var reservations []models.Reservation
+		if err := conf.Db.Preload("Hall").Scopes(models.NotCancelled).
+			Where("payment_status = ? AND deposit_amount > 0 AND deposit_due_date < ?", models.ReservationUnpaid, time.Now()).
+			Order("deposit_due_date asc").
+			Find(&reservations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reservations"})
+			return
+		}
+
+		c.JSON(http.StatusOK, reservations)
+	}
+}
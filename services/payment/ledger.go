@@ -0,0 +1,37 @@
+package payment
+
+import (
+	"storage/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// loadLedger builds the payment ledger for a reservation.
+func loadLedger(db *gorm.DB, reservation *models.Reservation) (models.PaymentLedger, error) {
+	var payments []models.Payment
+	if err := db.Where("reservation_id = ?", reservation.ID).Order("created_at asc").Find(&payments).Error; err != nil {
+		return models.PaymentLedger{}, err
+	}
+	return models.BuildLedger(*reservation, payments, time.Now()), nil
+}
+
+// SyncReservation recomputes the ledger and stores the paid amount and payment status on the
+// reservation. It must run whenever the payments or the cost of a reservation change.
+func SyncReservation(db *gorm.DB, reservationID uint) (models.PaymentLedger, error) {
+	var reservation models.Reservation
+	if err := db.First(&reservation, reservationID).Error; err != nil {
+		return models.PaymentLedger{}, err
+	}
+
+	ledger, err := loadLedger(db, &reservation)
+	if err != nil {
+		return models.PaymentLedger{}, err
+	}
+
+	err = db.Model(&models.Reservation{}).Where("id = ?", reservationID).Updates(map[string]interface{}{
+		"amount_paid":    ledger.Paid - ledger.Refunded,
+		"payment_status": ledger.Status,
+	}).Error
+	return ledger, err
+}
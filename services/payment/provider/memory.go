@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Memory is an in-process payment provider for development and tests.
+// Charges stay pending until confirmed through a signed webhook callback,
+// unless AutoConfirm is set.
+type Memory struct {
+	AutoConfirm bool
+
+	secret  []byte
+	mu      sync.Mutex
+	seq     int
+	charges map[string]float64
+	keys    map[string]Result // Results by idempotency key
+}
+
+// NewMemory creates an in-memory provider that signs webhooks with the given secret.
+func NewMemory(secret string, autoConfirm bool) *Memory {
+	return &Memory{
+		AutoConfirm: autoConfirm,
+		secret:      []byte(secret),
+		charges:     make(map[string]float64),
+		keys:        make(map[string]Result),
+	}
+}
+
+func (m *Memory) Name() string {
+	return "memory"
+}
+
+func (m *Memory) Charge(ctx context.Context, charge Charge) (Result, error) {
+	if charge.Amount <= 0 {
+		return Result{}, errors.New("charge amount must be positive")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if result, ok := m.keys[charge.IdempotencyKey]; ok && charge.IdempotencyKey != "" {
+		return result, nil
+	}
+
+	m.seq++
+	ref := fmt.Sprintf("mem_ch_%d", m.seq)
+	m.charges[ref] = charge.Amount
+
+	result := Result{Reference: ref, Status: m.initialStatus()}
+	if charge.IdempotencyKey != "" {
+		m.keys[charge.IdempotencyKey] = result
+	}
+	return result, nil
+}
+
+func (m *Memory) Refund(ctx context.Context, reference string, amount float64) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	charged, ok := m.charges[reference]
+	if !ok {
+		return Result{}, fmt.Errorf("unknown charge %q", reference)
+	}
+	if amount <= 0 || amount > charged {
+		return Result{}, errors.New("refund amount exceeds the charged amount")
+	}
+	m.charges[reference] = charged - amount
+
+	m.seq++
+	return Result{Reference: fmt.Sprintf("mem_re_%d", m.seq), Status: m.initialStatus()}, nil
+}
+
+func (m *Memory) ParseWebhook(payload []byte, signature string) (Event, error) {
+	if len(m.secret) == 0 || !hmac.Equal([]byte(m.Sign(payload)), []byte(signature)) {
+		return Event{}, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return Event{}, fmt.Errorf("invalid webhook payload: %v", err)
+	}
+	return event, nil
+}
+
+// Sign returns the hex HMAC-SHA256 signature expected in the webhook signature header.
+func (m *Memory) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (m *Memory) initialStatus() string {
+	if m.AutoConfirm {
+		return "confirmed"
+	}
+	return "pending"
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemoryChargeIsIdempotent(t *testing.T) {
+	m := NewMemory("secret", false)
+	ctx := context.Background()
+
+	first, err := m.Charge(ctx, Charge{ReservationID: 1, Amount: 300, IdempotencyKey: "k1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != "pending" {
+		t.Errorf("Status = %q, want pending", first.Status)
+	}
+	again, err := m.Charge(ctx, Charge{ReservationID: 1, Amount: 300, IdempotencyKey: "k1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != first {
+		t.Errorf("repeated charge = %+v, want the first result %+v", again, first)
+	}
+	other, _ := m.Charge(ctx, Charge{ReservationID: 1, Amount: 300, IdempotencyKey: "k2"})
+	if other.Reference == first.Reference {
+		t.Error("a new key reused the reference of another charge")
+	}
+
+	// The repeated charge did not collect the amount twice.
+	if _, err := m.Refund(ctx, first.Reference, 301); err == nil {
+		t.Error("refunded more than was charged")
+	}
+	if _, err := m.Refund(ctx, first.Reference, 300); err != nil {
+		t.Errorf("Refund() error = %v", err)
+	}
+}
+
+func TestMemoryAutoConfirm(t *testing.T) {
+	m := NewMemory("secret", true)
+	result, err := m.Charge(context.Background(), Charge{Amount: 10})
+	if err != nil || result.Status != "confirmed" {
+		t.Errorf("Charge() = %+v, %v, want confirmed", result, err)
+	}
+	if _, err := m.Charge(context.Background(), Charge{Amount: 0}); err == nil {
+		t.Error("Charge() accepted a zero amount")
+	}
+}
+
+func TestMemoryParseWebhook(t *testing.T) {
+	m := NewMemory("secret", false)
+	payload := []byte(`{"reference":"mem_ch_1","status":"confirmed"}`)
+
+	event, err := m.ParseWebhook(payload, m.Sign(payload))
+	if err != nil {
+		t.Fatalf("ParseWebhook() error = %v", err)
+	}
+	if event.Reference != "mem_ch_1" || event.Status != "confirmed" {
+		t.Errorf("event = %+v", event)
+	}
+
+	if _, err := m.ParseWebhook(payload, NewMemory("other", false).Sign(payload)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("ParseWebhook() with a foreign signature error = %v, want ErrInvalidSignature", err)
+	}
+	if _, err := NewMemory("", false).ParseWebhook(payload, ""); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("ParseWebhook() without a secret error = %v, want ErrInvalidSignature", err)
+	}
+}
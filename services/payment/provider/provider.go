@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrInvalidSignature is returned when a webhook callback cannot be authenticated.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Charge describes an amount to collect for a reservation.
+type Charge struct {
+	ReservationID uint
+	Amount        float64
+	Currency      string
+	Description   string
+	// IdempotencyKey identifies the charge. Charging again with the same key returns the
+	// result of the first charge instead of collecting the amount twice.
+	IdempotencyKey string
+}
+
+// Result is the provider's answer to a charge or refund request.
+type Result struct {
+	Reference string // Provider-side identifier used by webhook callbacks
+	Status    string // pending, confirmed or failed
+}
+
+// Event is a payment status update delivered by a provider callback.
+type Event struct {
+	Reference string `json:"reference"`
+	Status    string `json:"status"`
+}
+
+// Provider is implemented by every payment provider the service can collect payments through.
+type Provider interface {
+	// Name identifies the provider in stored payments and webhook URLs.
+	Name() string
+	// Charge starts collecting an amount. Providers that confirm asynchronously return a pending result.
+	Charge(ctx context.Context, charge Charge) (Result, error)
+	// Refund returns an amount of a previously confirmed charge.
+	Refund(ctx context.Context, reference string, amount float64) (Result, error)
+	// ParseWebhook authenticates a callback payload and extracts the status update it carries.
+	ParseWebhook(payload []byte, signature string) (Event, error)
+}
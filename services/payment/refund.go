@@ -0,0 +1,116 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"storage/configuration"
+	"storage/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errProviderRefund = errors.New("payment provider rejected the refund")
+	errRefundExceeds  = errors.New("refund exceeds the refundable amount")
+)
+
+// refund returns amount of a confirmed payment through its provider and records the refund in the ledger.
+// The refund is reserved as pending while the original payment is locked, so concurrent refunds cannot
+// together return more than was paid; the provider is called after the reservation is committed.
+func refund(ctx context.Context, conf *configuration.Dependencies, original *models.Payment, amount float64) (models.Payment, error) {
+	refund := models.Payment{
+		ReservationID: original.ReservationID,
+		Kind:          models.PaymentKindRefund,
+		Amount:        amount,
+		Status:        models.PaymentStatusPending,
+		Provider:      original.Provider,
+		RefundOf:      &original.ID,
+	}
+	err := conf.Db.Transaction(func(tx *gorm.DB) error {
+		var locked models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, original.ID).Error; err != nil {
+			return err
+		}
+		left, err := refundable(tx, &locked)
+		if err != nil {
+			return err
+		}
+		if amount <= 0 || amount > left {
+			return errRefundExceeds
+		}
+		return tx.Create(&refund).Error
+	})
+	if err != nil {
+		return models.Payment{}, err
+	}
+
+	result, err := conf.Payments.Refund(ctx, original.ProviderRef, amount)
+	if err != nil {
+		// A failed refund no longer counts against the refundable amount.
+		conf.Db.Model(&refund).Update("status", models.PaymentStatusFailed)
+		return models.Payment{}, fmt.Errorf("%w: %v", errProviderRefund, err)
+	}
+
+	refund.Status = result.Status
+	refund.ProviderRef = result.Reference
+	if refund.Status == models.PaymentStatusConfirmed {
+		now := time.Now()
+		refund.ConfirmedAt = &now
+	}
+	if err := conf.Db.Model(&refund).Updates(map[string]interface{}{
+		"status":       refund.Status,
+		"provider_ref": refund.ProviderRef,
+		"confirmed_at": refund.ConfirmedAt,
+	}).Error; err != nil {
+		return models.Payment{}, err
+	}
+	return refund, nil
+}
+
+// refundable returns how much of a payment has not been refunded yet.
+func refundable(db *gorm.DB, original *models.Payment) (float64, error) {
+	var alreadyRefunded float64
+	err := db.Model(&models.Payment{}).
+		Where("refund_of = ? AND status <> ?", original.ID, models.PaymentStatusFailed).
+		Select("COALESCE(SUM(amount), 0)").Scan(&alreadyRefunded).Error
+	return original.Amount - alreadyRefunded, err
+}
+
+// RefundAll refunds whatever is left of every confirmed payment of a reservation, e.g. when
+// it is cancelled, and returns the amount refunded.
+func RefundAll(ctx context.Context, conf *configuration.Dependencies, reservationID uint) (float64, error) {
+	var payments []models.Payment
+	if err := conf.Db.Where("reservation_id = ? AND kind <> ? AND status = ?",
+		reservationID, models.PaymentKindRefund, models.PaymentStatusConfirmed).
+		Find(&payments).Error; err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for i := range payments {
+		amount, err := refundable(conf.Db, &payments[i])
+		if err != nil {
+			return total, err
+		}
+		if amount <= 0 {
+			continue
+		}
+		_, err = refund(ctx, conf, &payments[i], amount)
+		if errors.Is(err, errRefundExceeds) {
+			// Refunded concurrently by someone else.
+			continue
+		}
+		if err != nil {
+			return total, err
+		}
+		total += amount
+	}
+
+	if _, err := SyncReservation(conf.Db, reservationID); err != nil {
+		return total, err
+	}
+	return total, nil
+}
@@ -0,0 +1,382 @@
+package receipt
+
+import (
+	"embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// The receipt fonts are DejaVu Sans (see fonts/LICENSE). They cover Latin, Cyrillic and
+// Greek, so receipts print correctly in every supported locale.
+//
+//go:embed fonts/DejaVuSans.ttf fonts/DejaVuSans-Bold.ttf
+var fontFiles embed.FS
+
+var (
+	regularFont = &ttFont{name: "DejaVuSans", file: "fonts/DejaVuSans.ttf"}
+	boldFont    = &ttFont{name: "DejaVuSans-Bold", file: "fonts/DejaVuSans-Bold.ttf"}
+)
+
+var errBadFont = errors.New("invalid TrueType font")
+
+// ttFont is a TrueType font parsed far enough to measure text and to embed a subset
+// of its glyphs in a PDF. Fonts are loaded on first use.
+type ttFont struct {
+	name string // PostScript name
+	file string
+
+	once sync.Once
+	err  error
+
+	tables     map[string][]byte
+	unitsPerEm int
+	ascent     int
+	descent    int
+	capHeight  int
+	bbox       [4]int
+	numGlyphs  int
+	advances   []uint16
+	glyphs     map[rune]uint16
+	loca       []uint32
+}
+
+func (f *ttFont) load() error {
+	f.once.Do(func() {
+		data, err := fontFiles.ReadFile(f.file)
+		if err != nil {
+			f.err = err
+			return
+		}
+		f.err = f.parse(data)
+	})
+	return f.err
+}
+
+func (f *ttFont) parse(data []byte) error {
+	if err := f.parseOutlines(data); err != nil {
+		return err
+	}
+	return f.parseCmap()
+}
+
+// parseOutlines reads the table directory, the metrics and the glyph locations.
+func (f *ttFont) parseOutlines(data []byte) error {
+	if len(data) < 12 {
+		return errBadFont
+	}
+	f.tables = map[string][]byte{}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(data) {
+			return errBadFont
+		}
+		tag := string(data[rec : rec+4])
+		offset := int(binary.BigEndian.Uint32(data[rec+8:]))
+		length := int(binary.BigEndian.Uint32(data[rec+12:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return errBadFont
+		}
+		f.tables[tag] = data[offset : offset+length]
+	}
+	for _, tag := range []string{"head", "hhea", "maxp", "hmtx", "loca", "glyf"} {
+		if f.tables[tag] == nil {
+			return fmt.Errorf("%w: missing %s table", errBadFont, tag)
+		}
+	}
+
+	head, hhea := f.tables["head"], f.tables["hhea"]
+	if len(head) < 54 || len(hhea) < 36 || len(f.tables["maxp"]) < 6 {
+		return errBadFont
+	}
+	f.unitsPerEm = int(binary.BigEndian.Uint16(head[18:]))
+	for i := range f.bbox {
+		f.bbox[i] = int(int16(binary.BigEndian.Uint16(head[36+2*i:])))
+	}
+	longLoca := binary.BigEndian.Uint16(head[50:]) == 1
+	f.ascent = int(int16(binary.BigEndian.Uint16(hhea[4:])))
+	f.descent = int(int16(binary.BigEndian.Uint16(hhea[6:])))
+	f.capHeight = f.ascent
+	if os2 := f.tables["OS/2"]; len(os2) >= 90 && binary.BigEndian.Uint16(os2) >= 2 {
+		f.capHeight = int(int16(binary.BigEndian.Uint16(os2[88:])))
+	}
+	f.numGlyphs = int(binary.BigEndian.Uint16(f.tables["maxp"][4:]))
+
+	// Horizontal metrics: the last advance repeats for the glyphs after numberOfHMetrics.
+	hmtx := f.tables["hmtx"]
+	metrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	if metrics == 0 || len(hmtx) < 4*metrics {
+		return errBadFont
+	}
+	f.advances = make([]uint16, f.numGlyphs)
+	for g := range f.advances {
+		if g < metrics {
+			f.advances[g] = binary.BigEndian.Uint16(hmtx[4*g:])
+		} else {
+			f.advances[g] = f.advances[metrics-1]
+		}
+	}
+
+	loca := f.tables["loca"]
+	f.loca = make([]uint32, f.numGlyphs+1)
+	for g := range f.loca {
+		if longLoca {
+			if 4*g+4 > len(loca) {
+				return errBadFont
+			}
+			f.loca[g] = binary.BigEndian.Uint32(loca[4*g:])
+		} else {
+			if 2*g+2 > len(loca) {
+				return errBadFont
+			}
+			f.loca[g] = 2 * uint32(binary.BigEndian.Uint16(loca[2*g:]))
+		}
+	}
+	return nil
+}
+
+// parseCmap reads the Unicode character map, preferring the full-repertoire format 12
+// subtable over the BMP-only format 4 one.
+func (f *ttFont) parseCmap() error {
+	cmap := f.tables["cmap"]
+	if len(cmap) < 4 {
+		return fmt.Errorf("%w: missing cmap table", errBadFont)
+	}
+	var bmp, full []byte
+	n := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i := 0; i < n; i++ {
+		rec := 4 + 8*i
+		if rec+8 > len(cmap) {
+			return errBadFont
+		}
+		platform, encoding := binary.BigEndian.Uint16(cmap[rec:]), binary.BigEndian.Uint16(cmap[rec+2:])
+		offset := int(binary.BigEndian.Uint32(cmap[rec+4:]))
+		if offset+4 > len(cmap) {
+			return errBadFont
+		}
+		switch {
+		case platform == 3 && encoding == 10, platform == 0 && encoding == 4:
+			full = cmap[offset:]
+		case platform == 3 && encoding == 1, platform == 0 && encoding == 3:
+			bmp = cmap[offset:]
+		}
+	}
+
+	f.glyphs = map[rune]uint16{}
+	switch {
+	case full != nil && binary.BigEndian.Uint16(full) == 12 && len(full) >= 16:
+		groups := int(binary.BigEndian.Uint32(full[12:]))
+		for i := 0; i < groups; i++ {
+			g := full[16+12*i:]
+			if len(g) < 12 {
+				return errBadFont
+			}
+			start, end, glyph := binary.BigEndian.Uint32(g), binary.BigEndian.Uint32(g[4:]), binary.BigEndian.Uint32(g[8:])
+			for r := start; r <= end && r <= 0x10FFFF; r++ {
+				f.glyphs[rune(r)] = uint16(glyph + r - start)
+			}
+		}
+	case bmp != nil && binary.BigEndian.Uint16(bmp) == 4 && len(bmp) >= 14:
+		segments := int(binary.BigEndian.Uint16(bmp[6:])) / 2
+		ends := 14
+		starts := ends + 2*segments + 2
+		deltas := starts + 2*segments
+		ranges := deltas + 2*segments
+		if ranges+2*segments > len(bmp) {
+			return errBadFont
+		}
+		for s := 0; s < segments; s++ {
+			end := int(binary.BigEndian.Uint16(bmp[ends+2*s:]))
+			start := int(binary.BigEndian.Uint16(bmp[starts+2*s:]))
+			delta := binary.BigEndian.Uint16(bmp[deltas+2*s:])
+			rangeOffset := int(binary.BigEndian.Uint16(bmp[ranges+2*s:]))
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				var glyph uint16
+				if rangeOffset == 0 {
+					glyph = uint16(c) + delta
+				} else {
+					at := ranges + 2*s + rangeOffset + 2*(c-start)
+					if at+2 > len(bmp) {
+						continue
+					}
+					if glyph = binary.BigEndian.Uint16(bmp[at:]); glyph != 0 {
+						glyph += delta
+					}
+				}
+				if glyph != 0 {
+					f.glyphs[rune(c)] = glyph
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("%w: no Unicode cmap", errBadFont)
+	}
+	return nil
+}
+
+// glyph returns the glyph of r, falling back to '?' for characters the font lacks.
+func (f *ttFont) glyph(r rune) uint16 {
+	if g, ok := f.glyphs[r]; ok {
+		return g
+	}
+	return f.glyphs['?']
+}
+
+// width returns the advance width of s at the given size in points.
+func (f *ttFont) width(s string, size float64) float64 {
+	var units int
+	for _, r := range s {
+		units += int(f.advances[f.glyph(r)])
+	}
+	return float64(units) * size / float64(f.unitsPerEm)
+}
+
+// scale converts font units to the 1000-unit glyph space of PDF font dictionaries.
+func (f *ttFont) scale(v int) int {
+	return v * 1000 / f.unitsPerEm
+}
+
+// glyphData returns the outline of a glyph in the glyf table.
+func (f *ttFont) glyphData(g uint16) []byte {
+	glyf := f.tables["glyf"]
+	start, end := f.loca[g], f.loca[g+1]
+	if start >= end || int(end) > len(glyf) {
+		return nil
+	}
+	return glyf[start:end]
+}
+
+// components adds the glyphs a composite glyph is built from to used.
+func (f *ttFont) components(g uint16, used map[uint16]bool) {
+	data := f.glyphData(g)
+	if len(data) < 10 || int16(binary.BigEndian.Uint16(data)) >= 0 {
+		return
+	}
+	const (
+		argsAreWords   = 0x0001
+		haveScale      = 0x0008
+		moreComponents = 0x0020
+		haveXYScale    = 0x0040
+		haveTwoByTwo   = 0x0080
+	)
+	for i := 10; i+4 <= len(data); {
+		flags := binary.BigEndian.Uint16(data[i:])
+		component := binary.BigEndian.Uint16(data[i+2:])
+		if int(component) < f.numGlyphs && !used[component] {
+			used[component] = true
+			f.components(component, used)
+		}
+		i += 4
+		if flags&argsAreWords != 0 {
+			i += 4
+		} else {
+			i += 2
+		}
+		switch {
+		case flags&haveScale != 0:
+			i += 2
+		case flags&haveXYScale != 0:
+			i += 4
+		case flags&haveTwoByTwo != 0:
+			i += 8
+		}
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+}
+
+// subset returns a copy of the font in which every glyph outside used is empty. Glyph
+// IDs are unchanged, so text can be written with the original IDs. Only the tables a
+// PDF viewer needs to render an embedded CID font are kept.
+func (f *ttFont) subset(used map[uint16]bool) []byte {
+	keep := map[uint16]bool{0: true}
+	for g := range used {
+		keep[g] = true
+		f.components(g, keep)
+	}
+
+	var glyf []byte
+	loca := make([]byte, 0, 4*(f.numGlyphs+1))
+	for g := 0; g < f.numGlyphs; g++ {
+		loca = binary.BigEndian.AppendUint32(loca, uint32(len(glyf)))
+		if keep[uint16(g)] {
+			glyf = append(glyf, f.glyphData(uint16(g))...)
+			for len(glyf)%4 != 0 {
+				glyf = append(glyf, 0)
+			}
+		}
+	}
+	loca = binary.BigEndian.AppendUint32(loca, uint32(len(glyf)))
+
+	head := append([]byte{}, f.tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0)  // checkSumAdjustment, set below
+	binary.BigEndian.PutUint16(head[50:], 1) // Long loca offsets
+
+	tables := map[string][]byte{
+		"glyf": glyf,
+		"head": head,
+		"hhea": f.tables["hhea"],
+		"hmtx": f.tables["hmtx"],
+		"loca": loca,
+		"maxp": f.tables["maxp"],
+	}
+	for _, tag := range []string{"cvt ", "fpgm", "prep"} {
+		if t := f.tables[tag]; t != nil {
+			tables[tag] = t
+		}
+	}
+
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	n := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= n {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+
+	out := binary.BigEndian.AppendUint32(nil, 0x00010000)
+	out = binary.BigEndian.AppendUint16(out, uint16(n))
+	out = binary.BigEndian.AppendUint16(out, uint16(searchRange))
+	out = binary.BigEndian.AppendUint16(out, uint16(entrySelector))
+	out = binary.BigEndian.AppendUint16(out, uint16(16*n-searchRange))
+
+	offset := 12 + 16*n
+	var body []byte
+	headOffset := 0
+	for _, tag := range tags {
+		t := tables[tag]
+		if tag == "head" {
+			headOffset = offset + len(body)
+		}
+		out = append(out, tag...)
+		out = binary.BigEndian.AppendUint32(out, tableChecksum(t))
+		out = binary.BigEndian.AppendUint32(out, uint32(offset+len(body)))
+		out = binary.BigEndian.AppendUint32(out, uint32(len(t)))
+		body = append(body, t...)
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+	}
+	out = append(out, body...)
+	binary.BigEndian.PutUint32(out[headOffset+8:], 0xB1B0AFBA-tableChecksum(out))
+	return out
+}
+
+func tableChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
@@ -0,0 +1,158 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"storage/configuration"
+	"storage/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetReceipt renders the receipt of a reservation in the format requested through the
+// "format" query parameter (pdf, txt or html) or the Accept header. PDF is the default.
+// The language is chosen by receiptLocale.
+func GetReceipt(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.Query("format")
+		if format == "" {
+			format = "pdf"
+			accept := c.GetHeader("Accept")
+			if !strings.Contains(accept, "application/pdf") {
+				if strings.Contains(accept, "text/html") {
+					format = "html"
+				} else if strings.Contains(accept, "text/plain") {
+					format = "txt"
+				}
+			}
+		}
+
+		renderer := RendererFor(format, &conf.Cfg.Receipts)
+		if renderer == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported receipt format, use pdf, txt or html"})
+			return
+		}
+
+		var reservation models.Reservation
+		if err := conf.Db.Preload("Hall").Preload("User").First(&reservation, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+			return
+		}
+
+		data := NewData(&reservation)
+		if conf.Cfg.Payments.Currency != "" {
+			data.Currency = conf.Cfg.Payments.Currency
+		}
+		data.Locale = receiptLocale(c.Query("lang"), reservation.User.Language, c.GetHeader("Accept-Language"))
+
+		var buf bytes.Buffer
+		if err := renderer.Render(&buf, data); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render receipt"})
+			return
+		}
+
+		filename := fmt.Sprintf("receipt_%d%s", reservation.ID, renderer.Extension())
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Data(http.StatusOK, renderer.ContentType(), buf.Bytes())
+	}
+}
+
+// receiptLocale picks the language of a receipt: the "lang" query parameter, then the
+// language the customer chose in their profile, then the Accept-Language header.
+// Users who never chose a language have none stored, so their browser's language applies.
+func receiptLocale(query, profile, acceptLanguage string) Locale {
+	if query != "" {
+		return LocaleFor(query)
+	}
+	if profile != "" {
+		return LocaleFor(profile)
+	}
+	if l, ok := LocaleFromAcceptLanguage(acceptLanguage); ok {
+		return l
+	}
+	return LocaleFor(DefaultLocale)
+}
+
+// PreviewReceipt renders a receipt template for admins editing the template files.
+// The template source can be supplied in the request to try out unsaved changes; otherwise
+// the stored template for the locale is used. Without a reservation ID sample data is shown.
+func PreviewReceipt(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Format        string `json:"format"`
+			Locale        string `json:"locale"`
+			Template      string `json:"template"`
+			ReservationID uint   `json:"reservation_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if req.Format == "" {
+			req.Format = "html"
+		}
+		if _, ok := templateFiles[req.Format]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be txt or html"})
+			return
+		}
+
+		var data Data
+		if req.ReservationID != 0 {
+			var reservation models.Reservation
+			if err := conf.Db.Preload("Hall").Preload("User").First(&reservation, req.ReservationID).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+				return
+			}
+			data = NewData(&reservation)
+		} else {
+			data = sampleData()
+		}
+		if conf.Cfg.Payments.Currency != "" {
+			data.Currency = conf.Cfg.Payments.Currency
+		}
+		if req.Locale != "" {
+			data.Locale = LocaleFor(req.Locale)
+		}
+
+		src := req.Template
+		if src == "" {
+			var err error
+			src, err = LoadTemplate(conf.Cfg.Receipts.TemplateDir, req.Format, data.Locale.Code)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := ExecuteTemplate(&buf, req.Format, src, data); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Template error: " + err.Error()})
+			return
+		}
+
+		contentType := TextRenderer{}.ContentType()
+		if req.Format == "html" {
+			contentType = HTMLRenderer{}.ContentType()
+		}
+		c.Data(http.StatusOK, contentType, buf.Bytes())
+	}
+}
+
+// sampleData is a made-up reservation used to preview templates.
+func sampleData() Data {
+	start := time.Now().AddDate(0, 0, 14).Truncate(24 * time.Hour)
+	reservation := models.Reservation{
+		ID:        1001,
+		Name:      "Jane Doe",
+		Company:   "Example Ltd.",
+		StartDate: start,
+		EndDate:   start.AddDate(0, 0, 9),
+		HallID:    1,
+		Hall:      models.Hall{ID: 1, Capacity: 120, Location: "Sofia, Main Street 1", CostPerDay: 1250},
+	}
+	reservation.CalculateTotalCost(reservation.Hall.CostPerDay)
+	return NewData(&reservation)
+}
@@ -0,0 +1,25 @@
+package receipt
+
+import "testing"
+
+func TestReceiptLocale(t *testing.T) {
+	tests := []struct {
+		query, profile, acceptLanguage string
+		want                           string
+	}{
+		{"", "", "", "en"},
+		{"", "", "bg-BG,bg;q=0.9,en;q=0.8", "bg"},
+		{"", "", "fr-FR, de;q=0.8", "en"},
+		{"", "", "fr-FR, bg;q=0.5", "bg"},
+		{"", "en", "bg-BG", "en"},
+		{"", "bg", "en-US", "bg"},
+		{"bg", "en", "en-US", "bg"},
+		{"en", "bg", "bg-BG", "en"},
+	}
+
+	for _, tt := range tests {
+		if got := receiptLocale(tt.query, tt.profile, tt.acceptLanguage); got.Code != tt.want {
+			t.Errorf("receiptLocale(%q, %q, %q) = %s, want %s", tt.query, tt.profile, tt.acceptLanguage, got.Code, tt.want)
+		}
+	}
+}
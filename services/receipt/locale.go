@@ -0,0 +1,126 @@
+package receipt
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale describes how dates, numbers and currency are printed for a language.
+type Locale struct {
+	Code           string
+	DateLayout     string
+	DateTimeLayout string
+	DecimalSep     string
+	GroupSep       string
+	// CurrencySymbols maps ISO currency codes to local symbols. Unknown codes are printed as is.
+	CurrencySymbols map[string]string
+	SymbolFirst     bool
+}
+
+// DefaultLocale is used when the customer's language is not supported.
+const DefaultLocale = "en"
+
+var locales = map[string]Locale{
+	"en": {
+		Code:            "en",
+		DateLayout:      "02 Jan 2006",
+		DateTimeLayout:  "02 Jan 2006 15:04",
+		DecimalSep:      ".",
+		GroupSep:        ",",
+		CurrencySymbols: map[string]string{"EUR": "€", "USD": "$"},
+		SymbolFirst:     true,
+	},
+	"bg": {
+		Code:            "bg",
+		DateLayout:      "02.01.2006 г.",
+		DateTimeLayout:  "02.01.2006 г. 15:04",
+		DecimalSep:      ",",
+		GroupSep:        " ",
+		CurrencySymbols: map[string]string{"BGN": "лв.", "EUR": "€", "USD": "$"},
+	},
+}
+
+// Locales returns the codes of all supported locales.
+func Locales() []string {
+	codes := make([]string, 0, len(locales))
+	for code := range locales {
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// LocaleFor resolves a language tag such as "bg-BG" to a supported locale, falling back to DefaultLocale.
+func LocaleFor(tag string) Locale {
+	code := strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	if l, ok := locales[code]; ok {
+		return l
+	}
+	return locales[DefaultLocale]
+}
+
+// LocaleFromAcceptLanguage picks the first supported language of an Accept-Language header.
+func LocaleFromAcceptLanguage(header string) (Locale, bool) {
+	for _, part := range strings.Split(header, ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		if tag == "" {
+			continue
+		}
+		if l := LocaleFor(tag); strings.HasPrefix(strings.ToLower(tag), l.Code) {
+			return l, true
+		}
+	}
+	return Locale{}, false
+}
+
+func (l Locale) Date(t time.Time) string {
+	return t.Format(l.DateLayout)
+}
+
+func (l Locale) DateTime(t time.Time) string {
+	return t.Format(l.DateTimeLayout)
+}
+
+// Number formats v with the given number of decimals and locale separators.
+func (l Locale) Number(v float64, decimals int) string {
+	s := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+
+	var b strings.Builder
+	if v < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, d := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(l.GroupSep)
+		}
+		b.WriteRune(d)
+	}
+	if frac != "" {
+		b.WriteString(l.DecimalSep)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// Money formats an amount in a currency, e.g. "€1,234.50" or "1 234,50 лв.".
+func (l Locale) Money(v float64, currency string) string {
+	symbol, ok := l.CurrencySymbols[currency]
+	if !ok {
+		return l.Number(v, 2) + " " + currency
+	}
+	if l.SymbolFirst {
+		if v < 0 {
+			return "-" + symbol + l.Number(-v, 2)
+		}
+		return symbol + l.Number(v, 2)
+	}
+	return l.Number(v, 2) + " " + symbol
+}
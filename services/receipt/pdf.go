@@ -0,0 +1,479 @@
+package receipt
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// A4 page size in PDF points.
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 50.0
+)
+
+// PDFRenderer writes a PDF receipt from the txt template of the receipt's locale, so the
+// PDF reads exactly like the text receipt in the customer's language.
+// The first line of the template becomes the title, lines of dashes become rules,
+// "Label: value" lines are set in two columns and the lines after the last rule form
+// the footer, which is repeated on every page when the body runs over onto more pages. Text is set in the embedded DejaVu Sans fonts, subset to the glyphs used,
+// so no external binaries or font files are needed. LogoPath may point to a JPEG, PNG
+// or GIF image shown in the header; a missing logo is skipped.
+type PDFRenderer struct {
+	TemplateDir string
+	LogoPath    string
+}
+
+func (PDFRenderer) ContentType() string {
+	return "application/pdf"
+}
+
+func (PDFRenderer) Extension() string {
+	return ".pdf"
+}
+
+func (p PDFRenderer) Render(w io.Writer, data Data) error {
+	var text bytes.Buffer
+	if err := renderFile(&text, p.TemplateDir, "txt", data); err != nil {
+		return err
+	}
+	for _, f := range []*ttFont{regularFont, boldFont} {
+		if err := f.load(); err != nil {
+			return fmt.Errorf("failed to load receipt font %s: %v", f.name, err)
+		}
+	}
+
+	doc := &pdfDocument{}
+	catalogID := doc.reserve()
+	pagesID := doc.reserve()
+
+	regular := &pdfFont{font: regularFont, id: doc.reserve()}
+	bold := &pdfFont{font: boldFont, id: doc.reserve()}
+	fonts := map[string]*pdfFont{"F1": regular, "F2": bold}
+	resources := fmt.Sprintf("/Font << /F1 %d 0 R /F2 %d 0 R >>", regular.id, bold.id)
+
+	title, body, footer := splitReceipt(text.String())
+	top := pageHeight - margin
+	contentWidth := pageWidth - 2*margin
+	// The body stops above the footer, which is repeated on every page.
+	bottom := margin + float64(len(footer))*12 + 16
+
+	pages := []*pdfContent{{fonts: fonts}}
+	c := pages[0]
+
+	// Header: logo on the left, title next to it.
+	titleX := margin
+	if logo, err := loadLogo(p.LogoPath); err == nil {
+		imageID := doc.addStream(logo.dict, logo.data)
+		resources += fmt.Sprintf(" /XObject << /Im1 %d 0 R >>", imageID)
+
+		height := 50.0
+		width := height * float64(logo.width) / float64(logo.height)
+		c.image("Im1", margin, top-height, width, height)
+		titleX = margin + width + 15
+	}
+	c.text(titleX, top-30, "F2", 20, title)
+	top -= 60
+	c.fillRect(margin, top, contentWidth, 2, 0.2, 0.3, 0.6)
+
+	// Labels share one column, as wide as the widest label but never more than 40% of the page.
+	labelWidth := 0.0
+	for _, line := range body {
+		if label, _, ok := splitLabel(line); ok {
+			labelWidth = math.Max(labelWidth, boldFont.width(label, 11))
+		}
+	}
+	labelWidth = math.Min(labelWidth+15, contentWidth*0.4)
+
+	// next returns the baseline for a line of body text, starting a new page when the
+	// current one is full. Later pages continue below the top margin without the header.
+	y := top - 28
+	next := func() float64 {
+		if y < bottom {
+			c = &pdfContent{fonts: fonts}
+			pages = append(pages, c)
+			y = pageHeight - margin - 11
+		}
+		line := y
+		y -= 16
+		return line
+	}
+	for _, line := range body {
+		switch label, value, ok := splitLabel(line); {
+		case strings.TrimSpace(line) == "":
+			y -= 8
+		case isRule(line):
+			if y >= bottom {
+				c.fillRect(margin, y+6, contentWidth, 0.8, 0.5, 0.5, 0.5)
+			}
+			y -= 14
+		case ok:
+			for i, part := range wrapText(regularFont, 11, value, contentWidth-labelWidth) {
+				line := next()
+				if i == 0 {
+					c.text(margin, line, "F2", 11, label)
+				}
+				c.text(margin+labelWidth, line, "F1", 11, part)
+			}
+		default:
+			for _, part := range wrapText(regularFont, 11, line, contentWidth) {
+				c.text(margin, next(), "F1", 11, part)
+			}
+		}
+	}
+
+	kids := make([]string, len(pages))
+	for n, page := range pages {
+		y := margin
+		for i := len(footer) - 1; i >= 0; i-- {
+			page.textColor(margin, y, "F1", 9, footer[i], 0.4)
+			y += 12
+		}
+		if len(pages) > 1 {
+			number := fmt.Sprintf("%d / %d", n+1, len(pages))
+			page.textColor(pageWidth-margin-regularFont.width(number, 9), margin, "F1", 9, number, 0.4)
+		}
+
+		contentID := doc.addStream("", page.Bytes())
+		pageID := doc.add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %g %g] /Resources << %s >> /Contents %d 0 R >>",
+			pagesID, pageWidth, pageHeight, resources, contentID))
+		kids[n] = fmt.Sprintf("%d 0 R", pageID)
+	}
+	doc.set(pagesID, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	doc.set(catalogID, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+
+	// Fonts are written last, once the content stream has recorded which glyphs it uses.
+	for _, f := range []*pdfFont{regular, bold} {
+		if err := f.write(doc); err != nil {
+			return err
+		}
+	}
+
+	return doc.write(w, catalogID)
+}
+
+// splitReceipt splits rendered template text into its title, the body lines and the
+// footer lines that follow the last rule.
+func splitReceipt(text string) (title string, body, footer []string) {
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(text, "\r\n", "\n"), "\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	if len(lines) == 0 {
+		return "", nil, nil
+	}
+	title, lines = strings.TrimSpace(lines[0]), lines[1:]
+
+	last := -1
+	for i, line := range lines {
+		if isRule(line) {
+			last = i
+		}
+	}
+	if last > 0 {
+		return title, lines[:last], lines[last+1:]
+	}
+	return title, lines, nil
+}
+
+// isRule reports whether a template line is a horizontal rule such as "-----".
+func isRule(line string) bool {
+	line = strings.TrimSpace(line)
+	return len(line) >= 3 && strings.Trim(line, "-=_") == ""
+}
+
+// splitLabel splits a "Label: value" line.
+func splitLabel(line string) (label, value string, ok bool) {
+	i := strings.Index(line, ": ")
+	if i <= 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(line[:i+1]), strings.TrimSpace(line[i+2:]), true
+}
+
+// wrapText breaks s into lines no wider than width, at spaces. A single word wider than
+// width is left on a line of its own.
+func wrapText(f *ttFont, size float64, s string, width float64) []string {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return []string{""}
+	}
+	var lines []string
+	line := words[0]
+	for _, word := range words[1:] {
+		if f.width(line+" "+word, size) > width {
+			lines = append(lines, line)
+			line = word
+		} else {
+			line += " " + word
+		}
+	}
+	return append(lines, line)
+}
+
+// pdfFont is an embedded TrueType font. It records the glyphs the page uses so that
+// only those are embedded.
+type pdfFont struct {
+	font *ttFont
+	id   int
+	used map[uint16]rune
+}
+
+// encode returns s as a hex string of glyph IDs for the font's Identity-H encoding.
+func (f *pdfFont) encode(s string) string {
+	if f.used == nil {
+		f.used = map[uint16]rune{}
+	}
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range s {
+		g := f.font.glyph(r)
+		if _, ok := f.used[g]; !ok {
+			f.used[g] = r
+		}
+		fmt.Fprintf(&b, "%04X", g)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// write adds the font subset, its descriptor, widths and ToUnicode map to the document.
+func (f *pdfFont) write(doc *pdfDocument) error {
+	glyphs := make([]int, 0, len(f.used))
+	used := make(map[uint16]bool, len(f.used))
+	for g := range f.used {
+		glyphs = append(glyphs, int(g))
+		used[g] = true
+	}
+	sort.Ints(glyphs)
+
+	// Subset fonts are named with a tag derived from their glyphs, as the PDF specification asks.
+	hash := fnv.New32a()
+	for _, g := range glyphs {
+		fmt.Fprintf(hash, "%d,", g)
+	}
+	tag, sum := make([]byte, 6), hash.Sum32()
+	for i := range tag {
+		tag[i] = byte('A' + sum%26)
+		sum /= 26
+	}
+	name := string(tag) + "+" + f.font.name
+
+	raw := f.font.subset(used)
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(raw); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	fileID := doc.addStream(fmt.Sprintf("/Length1 %d /Filter /FlateDecode", len(raw)), compressed.Bytes())
+
+	ft := f.font
+	descriptorID := doc.add(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		name, ft.scale(ft.bbox[0]), ft.scale(ft.bbox[1]), ft.scale(ft.bbox[2]), ft.scale(ft.bbox[3]),
+		ft.scale(ft.ascent), ft.scale(ft.descent), ft.scale(ft.capHeight), fileID))
+
+	var widths strings.Builder
+	for _, g := range glyphs {
+		fmt.Fprintf(&widths, "%d [%d] ", g, ft.scale(int(ft.advances[g])))
+	}
+	cidID := doc.add(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /CIDToGIDMap /Identity /W [%s] >>",
+		name, descriptorID, strings.TrimSpace(widths.String())))
+
+	toUnicodeID := doc.addStream("", toUnicodeCMap(glyphs, f.used))
+	doc.set(f.id, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		name, cidID, toUnicodeID))
+	return nil
+}
+
+// toUnicodeCMap maps glyph IDs back to characters so text can be copied and searched.
+func toUnicodeCMap(glyphs []int, chars map[uint16]rune) []byte {
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(glyphs); start += 100 {
+		chunk := glyphs[start:min(start+100, len(glyphs))]
+		fmt.Fprintf(&b, "%d beginbfchar\n", len(chunk))
+		for _, g := range chunk {
+			fmt.Fprintf(&b, "<%04X> <", g)
+			for _, unit := range utf16.Encode([]rune{chars[uint16(g)]}) {
+				fmt.Fprintf(&b, "%04X", unit)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+	return b.Bytes()
+}
+
+// pdfDocument collects numbered PDF objects and serializes them with a cross-reference table.
+type pdfDocument struct {
+	objects [][]byte
+}
+
+func (d *pdfDocument) reserve() int {
+	d.objects = append(d.objects, nil)
+	return len(d.objects)
+}
+
+func (d *pdfDocument) set(id int, body string) {
+	d.objects[id-1] = []byte(body)
+}
+
+func (d *pdfDocument) add(body string) int {
+	id := d.reserve()
+	d.set(id, body)
+	return id
+}
+
+func (d *pdfDocument) addStream(dict string, data []byte) int {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<< %s /Length %d >>\nstream\n", dict, len(data))
+	buf.Write(data)
+	buf.WriteString("\nendstream")
+
+	id := d.reserve()
+	d.objects[id-1] = buf.Bytes()
+	return id
+}
+
+func (d *pdfDocument) write(w io.Writer, rootID int) error {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(d.objects))
+	for i, body := range d.objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", i+1)
+		buf.Write(body)
+		buf.WriteString("\nendobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.objects)+1, rootID, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// pdfContent builds a page content stream.
+type pdfContent struct {
+	bytes.Buffer
+	fonts map[string]*pdfFont
+}
+
+func (c *pdfContent) text(x, y float64, font string, size float64, s string) {
+	fmt.Fprintf(c, "BT /%s %g Tf %.2f %.2f Td %s Tj ET\n", font, size, x, y, c.fonts[font].encode(s))
+}
+
+// textColor writes text in a shade of grey, 0 being black.
+func (c *pdfContent) textColor(x, y float64, font string, size float64, s string, grey float64) {
+	fmt.Fprintf(c, "q %g g ", grey)
+	c.text(x, y, font, size, s)
+	c.WriteString("Q\n")
+}
+
+func (c *pdfContent) fillRect(x, y, w, h, r, g, b float64) {
+	fmt.Fprintf(c, "q %g %g %g rg %.2f %.2f %.2f %.2f re f Q\n", r, g, b, x, y, w, h)
+}
+
+func (c *pdfContent) image(name string, x, y, w, h float64) {
+	fmt.Fprintf(c, "q %.2f 0 0 %.2f %.2f %.2f cm /%s Do Q\n", w, h, x, y, name)
+}
+
+// pdfImage is an image ready to be embedded as an XObject.
+type pdfImage struct {
+	dict          string
+	data          []byte
+	width, height int
+}
+
+// loadLogo reads the logo file. JPEGs are embedded as-is; other formats are
+// decoded and stored as compressed RGB over a white background.
+func loadLogo(path string) (*pdfImage, error) {
+	if path == "" {
+		return nil, os.ErrNotExist
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "jpeg" {
+		colorSpace := "/DeviceRGB"
+		switch cfg.ColorModel {
+		case color.GrayModel:
+			colorSpace = "/DeviceGray"
+		case color.CMYKModel:
+			// Adobe CMYK JPEGs are stored inverted.
+			colorSpace = "/DeviceCMYK /Decode [1 0 1 0 1 0 1 0]"
+		}
+		// Re-validate the file so a truncated JPEG doesn't produce a broken PDF.
+		if _, err := jpeg.Decode(bytes.NewReader(raw)); err != nil {
+			return nil, err
+		}
+		return &pdfImage{
+			dict:   fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode", cfg.Width, cfg.Height, colorSpace),
+			data:   raw,
+			width:  cfg.Width,
+			height: cfg.Height,
+		}, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	var pixels bytes.Buffer
+	zw := zlib.NewWriter(&pixels)
+	row := make([]byte, 0, bounds.Dx()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row = row[:0]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			// Composite onto white: premultiplied channels plus the uncovered background.
+			bg := 0xffff - a
+			row = append(row, byte((r+bg)>>8), byte((g+bg)>>8), byte((b+bg)>>8))
+		}
+		zw.Write(row)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return &pdfImage{
+		dict:   fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode", bounds.Dx(), bounds.Dy()),
+		data:   pixels.Bytes(),
+		width:  bounds.Dx(),
+		height: bounds.Dy(),
+	}, nil
+}
@@ -0,0 +1,219 @@
+package receipt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testTemplateDir = "../../templates/receipts"
+
+var textOp = regexp.MustCompile(`BT /(F\d) [\d.]+ Tf [\d.-]+ [\d.-]+ Td <([0-9A-F]*)> Tj ET`)
+
+// pageText decodes the text drawn on a rendered receipt, one string per text operator,
+// by mapping the glyph IDs back through the fonts' character maps.
+func pageText(t *testing.T, pdf []byte) []string {
+	t.Helper()
+	fonts := map[string]*ttFont{"F1": regularFont, "F2": boldFont}
+	var lines []string
+	for _, m := range textOp.FindAllSubmatch(pdf, -1) {
+		font := fonts[string(m[1])]
+		reverse := map[uint16]rune{}
+		for r, g := range font.glyphs {
+			if old, ok := reverse[g]; !ok || r < old {
+				reverse[g] = r
+			}
+		}
+		raw, err := hex.DecodeString(string(m[2]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var line []rune
+		for i := 0; i+1 < len(raw); i += 2 {
+			line = append(line, reverse[uint16(raw[i])<<8|uint16(raw[i+1])])
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+func TestPDFRendererUsesLocalizedTemplate(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   []string
+	}{
+		{"en", []string{"Reservation Receipt", "Name:", "Иван Петров", "Total Cost:"}},
+		{"bg", []string{"Разписка за резервация", "Име:", "Иван Петров", "Обща сума:", "лв."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			data := sampleData()
+			data.Reservation.Name = "Иван Петров"
+			data.Locale = LocaleFor(tt.locale)
+
+			var buf bytes.Buffer
+			if err := (PDFRenderer{TemplateDir: testTemplateDir}).Render(&buf, data); err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			pdf := buf.Bytes()
+			checkXref(t, pdf)
+
+			text := strings.Join(pageText(t, pdf), "\n")
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("PDF text does not contain %q:\n%s", want, text)
+				}
+			}
+			if strings.Contains(text, "?") {
+				t.Errorf("PDF text contains replacement characters:\n%s", text)
+			}
+			if !bytes.Contains(pdf, []byte("/Subtype /CIDFontType2")) || !bytes.Contains(pdf, []byte("/FontFile2")) {
+				t.Error("PDF does not embed a TrueType font")
+			}
+		})
+	}
+}
+
+func TestPDFRendererDrawsLogo(t *testing.T) {
+	var buf bytes.Buffer
+	renderer := PDFRenderer{TemplateDir: testTemplateDir, LogoPath: testTemplateDir + "/logo.png"}
+	if err := renderer.Render(&buf, sampleData()); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	pdf := buf.Bytes()
+	checkXref(t, pdf)
+
+	for _, want := range []string{
+		"/Subtype /Image /Width 128 /Height 128 /ColorSpace /DeviceRGB",
+		"/XObject << /Im1 ",
+		"/Im1 Do",
+	} {
+		if !bytes.Contains(pdf, []byte(want)) {
+			t.Errorf("PDF does not contain %q", want)
+		}
+	}
+
+	// A missing logo leaves it out rather than failing the receipt.
+	buf.Reset()
+	renderer.LogoPath = "missing.png"
+	if err := renderer.Render(&buf, sampleData()); err != nil {
+		t.Fatalf("Render() without the logo error = %v", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("/Subtype /Image")) {
+		t.Error("PDF embeds an image although the logo is missing")
+	}
+}
+
+var textPlacement = regexp.MustCompile(`/F\d ([\d.]+) Tf [\d.-]+ ([\d.-]+) Td`)
+
+func TestPDFRendererBreaksPages(t *testing.T) {
+	data := sampleData()
+	data.Reservation.Company = strings.TrimSpace(strings.Repeat("Very Long Company Name ", 400))
+
+	var buf bytes.Buffer
+	if err := (PDFRenderer{TemplateDir: testTemplateDir}).Render(&buf, data); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	pdf := buf.Bytes()
+	checkXref(t, pdf)
+
+	pages := bytes.Count(pdf, []byte("/Type /Page "))
+	if pages < 2 || !bytes.Contains(pdf, []byte(fmt.Sprintf("/Count %d >>", pages))) {
+		t.Fatalf("long receipt has %d pages", pages)
+	}
+
+	var streams [][]byte
+	for _, chunk := range bytes.Split(pdf, []byte("endstream")) {
+		if textPlacement.Match(chunk) {
+			streams = append(streams, chunk)
+		}
+	}
+	if len(streams) != pages {
+		t.Fatalf("%d content streams for %d pages", len(streams), pages)
+	}
+	for i, stream := range streams {
+		text := strings.Join(pageText(t, stream), "\n")
+		if !strings.Contains(text, "Generated on:") || !strings.Contains(text, fmt.Sprintf("%d / %d", i+1, pages)) {
+			t.Errorf("page %d lacks the footer or its number:\n%s", i+1, text)
+		}
+		// The body is set in 11pt and must stay clear of the one-line 9pt footer.
+		for _, m := range textPlacement.FindAllSubmatch(stream, -1) {
+			size, _ := strconv.ParseFloat(string(m[1]), 64)
+			y, _ := strconv.ParseFloat(string(m[2]), 64)
+			if y < margin || y > pageHeight-margin || (size == 11 && y < margin+12+16) {
+				t.Errorf("page %d has %vpt text at y = %v, outside the body", i+1, size, y)
+			}
+		}
+	}
+
+	all := strings.Join(pageText(t, pdf), "\n")
+	if !strings.Contains(all, "Total Cost:") {
+		t.Error("the lines after the overflowing one are missing")
+	}
+}
+
+// checkXref verifies that every cross-reference entry points at its object.
+func checkXref(t *testing.T, pdf []byte) {
+	t.Helper()
+	start := bytes.LastIndex(pdf, []byte("startxref\n"))
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) || start < 0 {
+		t.Fatal("not a PDF file")
+	}
+	xref, err := strconv.Atoi(strings.Fields(string(pdf[start+len("startxref\n"):]))[0])
+	if err != nil || !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref does not point at the xref table")
+	}
+
+	lines := strings.Split(string(pdf[xref:]), "\n")
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	for id := 1; id < count; id++ {
+		offset, err := strconv.Atoi(strings.Fields(lines[2+id])[0])
+		if err != nil {
+			t.Fatalf("bad xref entry %q", lines[2+id])
+		}
+		if want := fmt.Sprintf("%d 0 obj\n", id); !bytes.HasPrefix(pdf[offset:], []byte(want)) {
+			t.Errorf("xref entry of object %d points at %q", id, pdf[offset:offset+10])
+		}
+	}
+}
+
+func TestFontSubset(t *testing.T) {
+	if err := regularFont.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	used := map[uint16]bool{}
+	for _, r := range "Йй Ёё €" {
+		used[regularFont.glyph(r)] = true
+	}
+	raw := regularFont.subset(used)
+
+	if sum := tableChecksum(raw); sum != 0xB1B0AFBA {
+		t.Errorf("font checksum = %#x, want 0xb1b0afba", sum)
+	}
+
+	// The subset keeps glyph IDs, so outlines can be compared glyph by glyph.
+	sub := &ttFont{}
+	if err := sub.parseOutlines(raw); err != nil {
+		t.Fatalf("subset does not parse: %v", err)
+	}
+	if sub.numGlyphs != regularFont.numGlyphs {
+		t.Fatalf("subset has %d glyphs, want %d", sub.numGlyphs, regularFont.numGlyphs)
+	}
+	for g := range used {
+		if !bytes.Equal(sub.glyphData(g), regularFont.glyphData(g)) {
+			t.Errorf("glyph %d differs in the subset", g)
+		}
+	}
+	if unused := regularFont.glyph('Z'); sub.glyphData(unused) != nil && !used[unused] {
+		t.Errorf("unused glyph %d was kept", unused)
+	}
+	if len(raw) > len(regularFont.tables["glyf"])/10 {
+		t.Errorf("subset is %d bytes, expected far less than the full font", len(raw))
+	}
+}
@@ -1,73 +1,109 @@
 package receipt
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
+	"storage/configuration"
 	"storage/models"
+	"storage/services/blob"
+	"strings"
 	"time"
 )
 
-// GenerateReceipt creates a .txt file with reservation details
-func GenerateReceipt(reservation *models.Reservation) error {
-	// Ensure the "receipt" directory exists
-	receiptDir := "receipt"
-	if _, err := os.Stat(receiptDir); os.IsNotExist(err) {
-		err := os.Mkdir(receiptDir, 0755)
-		if err != nil {
-			return fmt.Errorf("failed to create receipt directory: %v", err)
-		}
+// Data is everything a renderer needs to print a receipt.
+type Data struct {
+	Reservation models.Reservation
+	Hall        models.Hall
+	Cost        models.CostBreakdown
+	Currency    string
+	Locale      Locale
+	GeneratedAt time.Time
+}
+
+// NewData prepares receipt data for a reservation. The reservation's Hall should be preloaded
+// for the cost breakdown and hall details to be filled in. The locale follows the language of
+// the customer when the reservation's User is loaded.
+func NewData(reservation *models.Reservation) Data {
+	cost := reservation.CostBreakdown(reservation.Hall.CostPerDay)
+	cost.Total = reservation.TotalCost
+	return Data{
+		Reservation: *reservation,
+		Hall:        reservation.Hall,
+		Cost:        cost,
+		Currency:    "BGN",
+		Locale:      LocaleFor(reservation.User.Language),
+		GeneratedAt: time.Now(),
 	}
+}
 
-	// Generate filename using reservation ID
-	filename := fmt.Sprintf("receipt_%d.txt", reservation.ID)
-	filePath := filepath.Join(receiptDir, filename)
+// Renderer writes a receipt in a particular output format.
+type Renderer interface {
+	ContentType() string
+	Extension() string
+	Render(w io.Writer, data Data) error
+}
 
-	// Create file
-	file, err := os.Create(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to create receipt file: %v", err)
+// RendererFor picks a renderer by format name ("pdf", "txt" or "html") or MIME type.
+// Unknown formats return nil.
+func RendererFor(format string, cfg *configuration.Receipts) Renderer {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "pdf", "application/pdf":
+		return PDFRenderer{TemplateDir: cfg.TemplateDir, LogoPath: cfg.LogoPath}
+	case "txt", "text", "text/plain":
+		return TextRenderer{TemplateDir: cfg.TemplateDir}
+	case "html", "text/html":
+		return HTMLRenderer{TemplateDir: cfg.TemplateDir}
+	default:
+		return nil
 	}
-	defer file.Close()
+}
 
-	// Write reservation details to the file
-	content := fmt.Sprintf(
-		"Reservation Receipt\n"+
-			"--------------------\n"+
-			"Reservation ID: %d\n"+
-			"Name: %s\n"+
-			"Company: %s\n"+
-			"Hall ID: %d\n"+
-			"Start Date: %s\n"+
-			"End Date: %s\n"+
-			"Total Cost: %.2f BGN\n"+
-			"--------------------\n"+
-			"Generated on: %s\n",
-		reservation.ID,
-		reservation.Name,
-		reservation.Company,
-		reservation.HallID,
-		reservation.StartDate.Format("2006-01-02"),
-		reservation.EndDate.Format("2006-01-02"),
-		reservation.TotalCost,
-		time.Now().Format("2006-01-02 15:04:05"),
-	)
+// ReceiptPrefix is the storage prefix under which generated receipts are kept.
+const ReceiptPrefix = "receipts/"
+
+// Key returns the storage key of a reservation's receipt with the given file extension.
+func Key(reservationID uint, ext string) string {
+	return fmt.Sprintf("%sreceipt_%d%s", ReceiptPrefix, reservationID, ext)
+}
+
+// ParseKey extracts the reservation ID from a receipt storage key.
+func ParseKey(key string) (uint, bool) {
+	var id uint
+	name := strings.TrimPrefix(key, ReceiptPrefix)
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	if _, err := fmt.Sscanf(name, "receipt_%d", &id); err != nil {
+		return 0, false
+	}
+	return id, fmt.Sprintf("receipt_%d", id) == name
+}
+
+// GenerateReceipt renders the text receipt of a reservation and keeps it in storage.
+func GenerateReceipt(ctx context.Context, store blob.Store, cfg *configuration.Receipts, reservation *models.Reservation) error {
+	renderer := TextRenderer{TemplateDir: cfg.TemplateDir}
+
+	var buf bytes.Buffer
+	if err := renderer.Render(&buf, NewData(reservation)); err != nil {
+		return fmt.Errorf("failed to render receipt: %v", err)
+	}
 
-	_, err = file.WriteString(content)
-	if err != nil {
-		return fmt.Errorf("failed to write to receipt file: %v", err)
+	key := Key(reservation.ID, renderer.Extension())
+	if err := store.Put(ctx, key, &buf, renderer.ContentType()); err != nil {
+		return fmt.Errorf("failed to store receipt: %v", err)
 	}
 
-	fmt.Println("Receipt generated:", filePath)
+	fmt.Println("Receipt generated:", key)
 	return nil
 }
 
-// ensureDirectoryExists checks if a directory exists and creates it if not
-func ensureDirectoryExists(dir string) error {
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		err := os.Mkdir(dir, 0755)
-		if err != nil {
-			return fmt.Errorf("error creating directory %s: %v", dir, err)
+// DeleteReceipt removes the stored receipt of a reservation in every format.
+func DeleteReceipt(ctx context.Context, store blob.Store, reservationID uint) error {
+	for _, ext := range []string{".txt", ".html", ".pdf"} {
+		if err := store.Delete(ctx, Key(reservationID, ext)); err != nil {
+			return fmt.Errorf("failed to delete receipt: %v", err)
 		}
 	}
 	return nil
@@ -0,0 +1,118 @@
+package receipt
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+)
+
+// DefaultTemplateDir is where receipt templates are looked up when none is configured.
+const DefaultTemplateDir = "templates/receipts"
+
+// Template formats and the file each one is loaded from.
+var templateFiles = map[string]string{
+	"txt":  "receipt.txt.tmpl",
+	"html": "receipt.html.tmpl",
+}
+
+// TextRenderer writes the plain-text receipt from the txt template of the receipt's locale.
+type TextRenderer struct {
+	TemplateDir string
+}
+
+func (TextRenderer) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func (TextRenderer) Extension() string {
+	return ".txt"
+}
+
+func (t TextRenderer) Render(w io.Writer, data Data) error {
+	return renderFile(w, t.TemplateDir, "txt", data)
+}
+
+// HTMLRenderer writes an HTML receipt from the html template of the receipt's locale.
+type HTMLRenderer struct {
+	TemplateDir string
+}
+
+func (HTMLRenderer) ContentType() string {
+	return "text/html; charset=utf-8"
+}
+
+func (HTMLRenderer) Extension() string {
+	return ".html"
+}
+
+func (h HTMLRenderer) Render(w io.Writer, data Data) error {
+	return renderFile(w, h.TemplateDir, "html", data)
+}
+
+// LoadTemplate reads the template source for a format and locale, falling back to the
+// default locale when the locale has no template of its own. Templates are read on every
+// call so that edits take effect without restarting the server.
+func LoadTemplate(dir, format, locale string) (string, error) {
+	name, ok := templateFiles[format]
+	if !ok {
+		return "", fmt.Errorf("unsupported template format %q", format)
+	}
+	if dir == "" {
+		dir = DefaultTemplateDir
+	}
+
+	src, err := os.ReadFile(filepath.Join(dir, locale, name))
+	if os.IsNotExist(err) && locale != DefaultLocale {
+		src, err = os.ReadFile(filepath.Join(dir, DefaultLocale, name))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load receipt template: %v", err)
+	}
+	return string(src), nil
+}
+
+// ExecuteTemplate parses src as a receipt template of the given format and renders data with it.
+// HTML templates are escaped contextually.
+func ExecuteTemplate(w io.Writer, format, src string, data Data) error {
+	funcs := map[string]any{
+		"date":     data.Locale.Date,
+		"datetime": data.Locale.DateTime,
+		"number":   data.Locale.Number,
+		"money": func(v float64) string {
+			return data.Locale.Money(v, data.Currency)
+		},
+		"days": func(d float64) string {
+			return data.Locale.Number(d, 0)
+		},
+		"now": time.Now,
+	}
+
+	switch format {
+	case "txt":
+		tmpl, err := template.New("receipt").Funcs(funcs).Option("missingkey=error").Parse(src)
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(w, data)
+	case "html":
+		tmpl, err := htmltemplate.New("receipt").Funcs(funcs).Option("missingkey=error").Parse(src)
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(w, data)
+	default:
+		return fmt.Errorf("unsupported template format %q", format)
+	}
+}
+
+func renderFile(w io.Writer, dir, format string, data Data) error {
+	src, err := LoadTemplate(dir, format, data.Locale.Code)
+	if err != nil {
+		return err
+	}
+	return ExecuteTemplate(w, format, src, data)
+}
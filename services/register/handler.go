@@ -3,12 +3,14 @@ package register
 import (
 	"net/http"
 	"storage/configuration"
+	"storage/services/account"
 	"storage/services/user"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // UserModel represents the user model in the database
@@ -22,6 +24,8 @@ type UserModel struct {
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Language string `json:"language" binding:"omitempty,oneof=en bg"`
 }
 
 // RegisterHandler handles user registration
@@ -35,6 +39,12 @@ func RegisterHandler(conf *configuration.Dependencies) gin.HandlerFunc {
 			return
 		}
 		req.Username = strings.TrimSpace(strings.ToLower(req.Username))
+		req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+
+		if err := user.ValidatePassword(req.Password, req.Username); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		// Hash the password
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -49,22 +59,34 @@ func RegisterHandler(conf *configuration.Dependencies) gin.HandlerFunc {
 			c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
 			return
 		}
+		if err := conf.Db.Where("lower(email) = ?", req.Email).First(&existingUser).Error; err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email address already registered"})
+			return
+		}
 
 		// Create the user model
 		user := user.User{
+			Email:     &req.Email,
 			Username:  req.Username,
 			Password:  string(hashedPassword),
 			IsActive:  true, // Set default values as necessary
 			LastLogin: time.Now(),
+			Language:  req.Language,
 		}
 
-		// Save the user in the database
-		if err := conf.Db.Create(&user).Error; err != nil {
+		// Save the user in the database, together with the verification email
+		err = conf.Db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			return account.SendVerification(tx, conf, &user)
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user: " + err.Error()})
 			return
 		}
 
 		// Respond with success
-		c.JSON(http.StatusOK, gin.H{"message": "User registered successfully"})
+		c.JSON(http.StatusOK, gin.H{"message": "User registered successfully. Check your email to verify your address"})
 	}
 }
@@ -0,0 +1,294 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"storage/configuration"
+	"storage/models"
+	"storage/services/invoice"
+	"storage/services/notification"
+	"storage/services/payment"
+	"storage/services/schedule"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Actions that can be taken on reservations affected by a hall becoming unavailable.
+const (
+	ActionNotify = "notify"
+	ActionCancel = "cancel"
+	ActionMove   = "move"
+)
+
+// AffectedReservations returns the upcoming and ongoing reservations of a hall that no
+// longer fit its Available flag and AvailableFrom/AvailableTo window.
+func AffectedReservations(db *gorm.DB, hall *models.Hall, now time.Time) ([]models.Reservation, error) {
+	var reservations []models.Reservation
+	if err := db.Scopes(models.NotCancelled).Where("hall_id = ? AND end_date > ?", hall.ID, now).
+		Order("start_date asc").
+		Find(&reservations).Error; err != nil {
+		return nil, err
+	}
+
+	affected := make([]models.Reservation, 0, len(reservations))
+	for _, r := range reservations {
+		if hall.CheckBookable(r.StartDate, r.EndDate) != nil {
+			affected = append(affected, r)
+		}
+	}
+	return affected, nil
+}
+
+// SetHallAvailability activates or deactivates a hall and/or changes its availability
+// window. The response reports the reservations already booked in the period the hall
+// is no longer available, so they can be handled with ResolveAffectedReservations.
+func SetHallAvailability(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var hall models.Hall
+		if err := conf.Db.First(&hall, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+
+		var req struct {
+			Available     *bool      `json:"available"`
+			AvailableFrom *time.Time `json:"available_from"`
+			AvailableTo   *time.Time `json:"available_to"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		updates := map[string]interface{}{}
+		if req.Available != nil {
+			hall.Available = *req.Available
+			updates["available"] = hall.Available
+		}
+		if req.AvailableFrom != nil {
+			hall.AvailableFrom = *req.AvailableFrom
+			updates["available_from"] = hall.AvailableFrom
+		}
+		if req.AvailableTo != nil {
+			hall.AvailableTo = *req.AvailableTo
+			updates["available_to"] = hall.AvailableTo
+		}
+		if !hall.AvailableFrom.IsZero() && !hall.AvailableTo.IsZero() && !hall.AvailableFrom.Before(hall.AvailableTo) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "AvailableFrom must be before AvailableTo"})
+			return
+		}
+
+		if len(updates) > 0 {
+			updates["version"] = gorm.Expr("version + 1")
+			result := conf.Db.Model(&models.Hall{}).Where("id = ? AND version = ?", hall.ID, hall.Version).Updates(updates)
+			if result.Error != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update hall availability"})
+				return
+			}
+			if result.RowsAffected == 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Hall has been modified; reload it and retry"})
+				return
+			}
+			hall.Version++
+		}
+
+		affected, err := AffectedReservations(conf.Db, &hall, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reservations"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"hall":                  hall,
+			"affected_reservations": affected,
+		})
+	}
+}
+
+// GetAffectedReservations reports the reservations of a hall that fall outside its current availability.
+func GetAffectedReservations(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var hall models.Hall
+		if err := conf.Db.First(&hall, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+
+		affected, err := AffectedReservations(conf.Db, &hall, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reservations"})
+			return
+		}
+		c.JSON(http.StatusOK, affected)
+	}
+}
+
+// ResolutionResult is the outcome of an action on one affected reservation.
+type ResolutionResult struct {
+	ReservationID uint   `json:"reservation_id"`
+	Status        string `json:"status"` // notified, cancelled, moved or failed
+	HallID        uint   `json:"hall_id,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// ResolveAffectedReservations applies one action to reservations affected by the hall's
+// availability: notify their owners, cancel them, or move them to another hall at the
+// price already agreed. Without reservation_ids every affected reservation is handled.
+// Each reservation is handled on its own, so one failure does not stop the rest.
+func ResolveAffectedReservations(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var hall models.Hall
+		if err := conf.Db.First(&hall, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+
+		var req struct {
+			Action         string `json:"action" binding:"required,oneof=notify cancel move"`
+			ReservationIDs []uint `json:"reservation_ids"`
+			TargetHallID   uint   `json:"target_hall_id"`
+			Message        string `json:"message"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Action must be notify, cancel or move"})
+			return
+		}
+		if req.Action == ActionMove && (req.TargetHallID == 0 || req.TargetHallID == hall.ID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target_hall_id must name another hall"})
+			return
+		}
+
+		affected, err := AffectedReservations(conf.Db, &hall, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reservations"})
+			return
+		}
+		if len(req.ReservationIDs) > 0 {
+			affected = selectReservations(affected, req.ReservationIDs)
+		}
+
+		results := make([]ResolutionResult, 0, len(affected))
+		for i := range affected {
+			r := &affected[i]
+			result := ResolutionResult{ReservationID: r.ID}
+
+			switch req.Action {
+			case ActionNotify:
+				err = notification.Notify(conf.Db, r, notice(req.Message,
+					fmt.Sprintf("Hall %d is not available for your reservation from %s to %s.",
+						hall.ID, r.StartDate.Format("2006-01-02"), r.EndDate.Format("2006-01-02"))))
+				result.Status = "notified"
+			case ActionCancel:
+				err = Cancel(c.Request.Context(), conf, r, notice(req.Message,
+					fmt.Sprintf("Your reservation from %s to %s was cancelled because hall %d is not available.",
+						r.StartDate.Format("2006-01-02"), r.EndDate.Format("2006-01-02"), hall.ID)))
+				result.Status = "cancelled"
+			case ActionMove:
+				err = Move(conf, r, req.TargetHallID, notice(req.Message,
+					fmt.Sprintf("Your reservation from %s to %s was moved from hall %d to hall %d.",
+						r.StartDate.Format("2006-01-02"), r.EndDate.Format("2006-01-02"), hall.ID, req.TargetHallID)))
+				result.Status = "moved"
+				result.HallID = req.TargetHallID
+			}
+
+			if err != nil {
+				result = ResolutionResult{ReservationID: r.ID, Status: "failed", Error: err.Error()}
+			}
+			results = append(results, result)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"action": req.Action, "results": results})
+	}
+}
+
+// Cancel marks a reservation cancelled and notifies its owner. The reservation is kept for
+// reports and accounting: its confirmed payments are refunded and an outstanding invoice is
+// credited. The receipt stays as a record of the original booking.
+func Cancel(ctx context.Context, conf *configuration.Dependencies, r *models.Reservation, message string) error {
+	now := time.Now()
+	err := conf.Db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(r).Where("status <> ?", models.ReservationStatusCancelled).
+			Updates(map[string]interface{}{"status": models.ReservationStatusCancelled, "cancelled_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("reservation is already cancelled")
+		}
+		return notification.Notify(tx, r, message)
+	})
+	if err != nil {
+		return err
+	}
+	r.Status = models.ReservationStatusCancelled
+	r.CancelledAt = &now
+
+	if _, err := payment.RefundAll(ctx, conf, r.ID); err != nil {
+		return fmt.Errorf("reservation cancelled, but refunding its payments failed: %w", err)
+	}
+	if err := invoice.CreditReservation(conf.Db, conf.Cfg, r.ID, "Reservation cancelled"); err != nil {
+		return fmt.Errorf("reservation cancelled, but crediting its invoice failed: %w", err)
+	}
+	return nil
+}
+
+// Move reassigns a reservation to another hall, keeping its dates and price, and notifies
+// its owner. The target hall must be bookable for the dates and not already booked.
+func Move(conf *configuration.Dependencies, r *models.Reservation, targetHallID uint, message string) error {
+	target, targetSchedule, err := schedule.LoadHall(conf.Db, targetHallID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("target hall not found")
+	}
+	if err != nil {
+		return err
+	}
+	if err := target.CheckBookable(r.StartDate, r.EndDate); err != nil {
+		return err
+	}
+	if err := targetSchedule.Check(r.StartDate, r.EndDate); err != nil {
+		return err
+	}
+
+	return conf.Db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Reservation{}).Scopes(models.NotCancelled).
+			Where("hall_id = ? AND id != ? AND start_date < ? AND end_date > ?", target.ID, r.ID, r.EndDate, r.StartDate).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("target hall is already booked for these dates")
+		}
+
+		if err := tx.Model(r).Update("hall_id", target.ID).Error; err != nil {
+			return err
+		}
+		return notification.Notify(tx, r, message)
+	})
+}
+
+func selectReservations(reservations []models.Reservation, ids []uint) []models.Reservation {
+	wanted := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	selected := reservations[:0]
+	for _, r := range reservations {
+		if wanted[r.ID] {
+			selected = append(selected, r)
+		}
+	}
+	return selected
+}
+
+func notice(custom, fallback string) string {
+	if custom != "" {
+		return custom
+	}
+	return fallback
+}
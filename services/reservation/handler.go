@@ -1,30 +1,52 @@
 package reservation
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"net/http"
-	"os"
-	"path/filepath"
 	"storage/configuration"
 	"storage/models"
+	"storage/services/auth"
+	"storage/services/payment"
 	"storage/services/receipt"
+	"storage/services/schedule"
 	"strings"
 	"time"
 )
 
+// reservationRequest holds the fields a client may set on a reservation. Costs, payment
+// tracking and status are managed by the server and cannot be posted.
+type reservationRequest struct {
+	Name      string    `json:"name"`
+	Company   string    `json:"company"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	HallID    uint      `json:"hall_id"`
+}
+
+// applyTo copies the request onto a reservation.
+func (req *reservationRequest) applyTo(r *models.Reservation) {
+	r.Name = req.Name
+	r.Company = req.Company
+	r.StartDate = req.StartDate
+	r.EndDate = req.EndDate
+	r.HallID = req.HallID
+}
+
 // CreateReservation handles creating a new reservation.
 func CreateReservation(conf *configuration.Dependencies) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var reservation models.Reservation
 
-		// Extract authenticated user ID (from middleware)
-		userID, exists := c.Get("userID")
-		if !exists {
+		// The authenticated user owns the reservation
+		principal, ok := auth.CurrentPrincipal(c)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
-		reservation.UserID = userID.(int64) // Store the UserID
 
 		// Skip DB operations if DB is not initialized
 		if conf.Db == nil {
@@ -32,11 +54,14 @@ func CreateReservation(conf *configuration.Dependencies) gin.HandlerFunc {
 			return
 		}
 
-		// Parse request body into reservation model
-		if err := c.ShouldBindJSON(&reservation); err != nil {
+		// Parse request body; only the client-settable fields reach the reservation
+		var req reservationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
 			return
 		}
+		req.applyTo(&reservation)
+		reservation.UserID = principal.UserID // Never taken from the request body
 
 		// Ensure the start date is before the end date.
 		if !reservation.StartDate.Before(reservation.EndDate) {
@@ -50,9 +75,28 @@ func CreateReservation(conf *configuration.Dependencies) gin.HandlerFunc {
 			return
 		}
 
+		// Fetch the hall and make sure it can be booked and the booking falls within its opening hours.
+		hall, hallSchedule, err := schedule.LoadHall(conf.Db, reservation.HallID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load hall schedule"})
+			return
+		}
+		if err := hall.CheckBookable(reservation.StartDate, reservation.EndDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := hallSchedule.Check(reservation.StartDate, reservation.EndDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// Prevent double booking by checking overlapping reservations.
 		var count int64
-		conf.Db.Model(&models.Reservation{}).
+		conf.Db.Model(&models.Reservation{}).Scopes(models.NotCancelled).
 			Where("hall_id = ? AND ((start_date BETWEEN ? AND ?) OR (end_date BETWEEN ? AND ?))",
 				reservation.HallID, reservation.StartDate, reservation.EndDate,
 				reservation.StartDate, reservation.EndDate).
@@ -72,13 +116,9 @@ func CreateReservation(conf *configuration.Dependencies) gin.HandlerFunc {
 			return
 		}
 
-		// Fetch hall price to calculate total cost.
-		var hall models.Hall
-		if err := conf.Db.First(&hall, reservation.HallID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
-			return
-		}
+		// Use the hall price to calculate total cost.
 		reservation.CalculateTotalCost(hall.CostPerDay)
+		reservation.ApplyDepositPolicy(time.Now())
 
 		// Save the reservation in the database.
 		if err := conf.Db.Create(&reservation).Error; err != nil {
@@ -86,8 +126,12 @@ func CreateReservation(conf *configuration.Dependencies) gin.HandlerFunc {
 			return
 		}
 
-		// Generate a receipt after successful reservation creation.
-		if err := receipt.GenerateReceipt(&reservation); err != nil {
+		// Generate a receipt after successful reservation creation, in the customer's language.
+		reservation.Hall = *hall
+		if err := conf.Db.First(&reservation.User, reservation.UserID).Error; err != nil {
+			fmt.Printf("Warning: Failed to load the customer of reservation ID %d: %v\n", reservation.ID, err)
+		}
+		if err := receipt.GenerateReceipt(c.Request.Context(), conf.Storage, &conf.Cfg.Receipts, &reservation); err != nil {
 			// Log error but still return success since reservation was created.
 			fmt.Printf("Warning: Failed to generate receipt for reservation ID %d: %v\n", reservation.ID, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Reservation created, but failed to generate receipt"})
@@ -129,9 +173,13 @@ func UpdateReservation(conf *configuration.Dependencies) gin.HandlerFunc {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
 			return
 		}
+		if reservation.IsCancelled() {
+			c.JSON(http.StatusConflict, gin.H{"error": "Reservation has been cancelled"})
+			return
+		}
 
-		// Bind the incoming JSON to the reservation struct
-		var updatedReservation models.Reservation
+		// Bind the incoming JSON to the client-settable fields
+		var updatedReservation reservationRequest
 		if err := c.ShouldBindJSON(&updatedReservation); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
 			return
@@ -143,9 +191,28 @@ func UpdateReservation(conf *configuration.Dependencies) gin.HandlerFunc {
 			return
 		}
 
+		// Fetch the hall and make sure it can be booked and the new dates fall within its opening hours
+		hall, hallSchedule, err := schedule.LoadHall(conf.Db, updatedReservation.HallID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load hall schedule"})
+			return
+		}
+		if err := hall.CheckBookable(updatedReservation.StartDate, updatedReservation.EndDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := hallSchedule.Check(updatedReservation.StartDate, updatedReservation.EndDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// Check for overlapping reservations (prevent double booking)
 		var count int64
-		conf.Db.Model(&models.Reservation{}).
+		conf.Db.Model(&models.Reservation{}).Scopes(models.NotCancelled).
 			Where("hall_id = ? AND id != ? AND ((start_date BETWEEN ? AND ?) OR (end_date BETWEEN ? AND ?))",
 				updatedReservation.HallID, id,
 				updatedReservation.StartDate, updatedReservation.EndDate,
@@ -157,28 +224,37 @@ func UpdateReservation(conf *configuration.Dependencies) gin.HandlerFunc {
 			return
 		}
 
-		// Fetch the hall's cost per day
-		var hall models.Hall
-		if err := conf.Db.First(&hall, updatedReservation.HallID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
-			return
-		}
-
 		// Update reservation fields
-		reservation.Name = updatedReservation.Name
-		reservation.Company = updatedReservation.Company
-		reservation.HallID = updatedReservation.HallID
-		reservation.StartDate = updatedReservation.StartDate
-		reservation.EndDate = updatedReservation.EndDate
+		updatedReservation.applyTo(&reservation)
 
 		// Calculate the updated total cost using the hall's price per day
 		reservation.CalculateTotalCost(hall.CostPerDay)
 
-		// Save the updated reservation
-		if err := conf.Db.Save(&reservation).Error; err != nil {
+		// The deposit follows the new cost and dates, but an update never pushes its due date back.
+		previousDue := reservation.DepositDueDate
+		reservation.ApplyDepositPolicy(time.Now())
+		if !previousDue.IsZero() && previousDue.Before(reservation.DepositDueDate) {
+			reservation.DepositDueDate = previousDue
+		}
+
+		// Save the updated reservation and bring its payment status in line with the new total
+		err = conf.Db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&reservation).Error; err != nil {
+				return err
+			}
+			ledger, err := payment.SyncReservation(tx, reservation.ID)
+			if err != nil {
+				return err
+			}
+			reservation.AmountPaid = ledger.Paid - ledger.Refunded
+			reservation.PaymentStatus = ledger.Status
+			return nil
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reservation"})
 			return
 		}
+		reservation.DepositOverdue = reservation.IsDepositOverdue(time.Now())
 
 		c.JSON(http.StatusOK, reservation)
 	}
@@ -196,29 +272,16 @@ func GetReservations(conf *configuration.Dependencies) gin.HandlerFunc {
 			return
 		}
 
-		// Extract user ID and roles from authentication
-		userID, exists := c.Get("user_id")
-		if !exists {
+		// Extract the authenticated user
+		principal, ok := auth.CurrentPrincipal(c)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
 
-		roles, _ := c.Get("roles") // Get user roles from middleware
-		isAdmin := false
-
-		// Check if user has admin role
-		if rolesList, ok := roles.([]string); ok {
-			for _, role := range rolesList {
-				if strings.ToLower(role) == "admin" {
-					isAdmin = true
-					break
-				}
-			}
-		}
-
 		// Restrict non-admin users to their own reservations
-		if !isAdmin {
-			query = query.Where("user_id = ?", userID)
+		if !principal.IsAdmin() {
+			query = query.Where("user_id = ?", principal.UserID)
 		}
 
 		// Filter by a specific date (Format: "YYYY-MM-DD")
@@ -268,6 +331,9 @@ func GetReservations(conf *configuration.Dependencies) gin.HandlerFunc {
 	}
 }
 
+// errHasLedger refuses to delete a reservation that payments or invoices refer to.
+var errHasLedger = errors.New("reservation has payments or invoices")
+
 // DeleteReservation removes a reservation and its receipt file
 func DeleteReservation(conf *configuration.Dependencies) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -282,19 +348,38 @@ func DeleteReservation(conf *configuration.Dependencies) gin.HandlerFunc {
 			return
 		}
 
-		if err := conf.Db.First(&reservation, id).Error; err != nil {
+		// Delete the reservation unless money has moved for it. Payments and invoices have to
+		// stay in the ledger, so such reservations are cancelled instead, which refunds and credits them.
+		err := conf.Db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, id).Error; err != nil {
+				return err
+			}
+			var payments, invoices int64
+			if err := tx.Model(&models.Payment{}).Where("reservation_id = ?", reservation.ID).Count(&payments).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Invoice{}).Where("reservation_id = ?", reservation.ID).Count(&invoices).Error; err != nil {
+				return err
+			}
+			if payments > 0 || invoices > 0 {
+				return errHasLedger
+			}
+			return tx.Delete(&reservation).Error
+		})
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
 			c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
 			return
-		}
-
-		// Delete the reservation from the database
-		if err := conf.Db.Delete(&reservation).Error; err != nil {
+		case errors.Is(err, errHasLedger):
+			c.JSON(http.StatusConflict, gin.H{"error": "Reservation has payments or invoices, cancel it instead"})
+			return
+		case err != nil:
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete reservation"})
 			return
 		}
 
 		// Try deleting the associated receipt file
-		if err := deleteReceiptFile(reservation.ID); err != nil {
+		if err := receipt.DeleteReceipt(c.Request.Context(), conf.Storage, reservation.ID); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Reservation deleted, but failed to delete receipt"})
 			return
 		}
@@ -303,26 +388,6 @@ func DeleteReservation(conf *configuration.Dependencies) gin.HandlerFunc {
 	}
 }
 
-// deleteReceiptFile removes the receipt file associated with a reservation
-func deleteReceiptFile(reservationID uint) error {
-	receiptDir := "receipts"
-	filename := fmt.Sprintf("receipt_%d.txt", reservationID)
-	filePath := filepath.Join(receiptDir, filename)
-
-	// Check if the file exists before trying to delete it
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return nil // If file doesn't exist, there's nothing to delete
-	}
-
-	// Remove the file
-	if err := os.Remove(filePath); err != nil {
-		return fmt.Errorf("failed to delete receipt file: %v", err)
-	}
-
-	fmt.Println("Receipt file deleted:", filePath)
-	return nil
-}
-
 // GetCategorizedReservations groups reservations into Past, Current, and Upcoming.
 func GetCategorizedReservations(conf *configuration.Dependencies) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -335,7 +400,7 @@ func GetCategorizedReservations(conf *configuration.Dependencies) gin.HandlerFun
 		}
 
 		// Preload the Hall association if you need hall details in the response.
-		if err := conf.Db.Preload("Hall").Find(&reservations).Error; err != nil {
+		if err := conf.Db.Preload("Hall").Scopes(models.NotCancelled).Find(&reservations).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reservations"})
 			return
 		}
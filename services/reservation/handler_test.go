@@ -0,0 +1,81 @@
+package reservation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"storage/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReservationRequestIgnoresServerManagedFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := `{
+		"id": 99,
+		"user_id": 7,
+		"name": "Annual meeting",
+		"company": "ACME",
+		"start_date": "2030-05-01T09:00:00Z",
+		"end_date": "2030-05-02T17:00:00Z",
+		"hall_id": 3,
+		"total_cost": 1,
+		"deposit_amount": 0,
+		"amount_paid": 5000,
+		"payment_status": "paid",
+		"deposit_overdue": false,
+		"status": "cancelled",
+		"cancelled_at": "2030-01-01T00:00:00Z"
+	}`
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/reservations", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	var req reservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		t.Fatalf("ShouldBindJSON() error = %v", err)
+	}
+	var r models.Reservation
+	req.applyTo(&r)
+
+	want := models.Reservation{
+		Name:      "Annual meeting",
+		Company:   "ACME",
+		StartDate: time.Date(2030, 5, 1, 9, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2030, 5, 2, 17, 0, 0, 0, time.UTC),
+		HallID:    3,
+	}
+	if r.ID != 0 || r.UserID != 0 || r.TotalCost != 0 || r.AmountPaid != 0 || r.PaymentStatus != "" ||
+		r.Status != "" || r.CancelledAt != nil {
+		t.Errorf("server-managed fields were taken from the request: %+v", r)
+	}
+	if r.Name != want.Name || r.Company != want.Company || r.HallID != want.HallID ||
+		!r.StartDate.Equal(want.StartDate) || !r.EndDate.Equal(want.EndDate) {
+		t.Errorf("reservation = %+v, want the client fields of %+v", r, want)
+	}
+
+	// New reservations start unpaid whatever the request said.
+	r.TotalCost = 1000
+	r.ApplyDepositPolicy(time.Date(2030, 4, 1, 0, 0, 0, 0, time.UTC))
+	if r.PaymentStatus != models.ReservationUnpaid || r.DepositAmount != 300 {
+		t.Errorf("after ApplyDepositPolicy() status = %q, deposit = %v", r.PaymentStatus, r.DepositAmount)
+	}
+}
+
+func TestReservationRequestKeepsPaymentTrackingOnUpdate(t *testing.T) {
+	paidAt := time.Date(2030, 4, 2, 0, 0, 0, 0, time.UTC)
+	r := models.Reservation{ID: 5, UserID: 7, AmountPaid: 300, PaymentStatus: models.ReservationDepositPaid, Status: models.ReservationStatusActive, DepositDueDate: paidAt}
+	req := reservationRequest{Name: "New name", HallID: 4}
+	req.applyTo(&r)
+
+	if r.ID != 5 || r.UserID != 7 || r.AmountPaid != 300 || r.PaymentStatus != models.ReservationDepositPaid ||
+		r.Status != models.ReservationStatusActive || !r.DepositDueDate.Equal(paidAt) {
+		t.Errorf("update changed server-managed fields: %+v", r)
+	}
+	if r.Name != "New name" || r.HallID != 4 {
+		t.Errorf("update did not apply the client fields: %+v", r)
+	}
+}
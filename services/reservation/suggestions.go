@@ -4,18 +4,25 @@ package reservation
 import (
 	"storage/configuration"
 	"storage/models"
+	"storage/services/schedule"
 	"time"
 )
 
 // SuggestAlternativeDates queries reservations for a given hall and returns available date ranges
 // that can accommodate a reservation of the same duration as the requested one.
+// Suggestions respect the hall's opening hours, so each one can be booked as is.
 func SuggestAlternativeDates(conf *configuration.Dependencies, hallID uint, requestedStart, requestedEnd time.Time) ([]models.DateRange, error) {
 	// Define a wider window to search for available gaps (e.g., 30 days before/after the requested dates)
 	startWindow := requestedStart.AddDate(0, 0, -30)
 	endWindow := requestedEnd.AddDate(0, 0, 30)
 
+	_, hallSchedule, err := schedule.LoadHall(conf.Db, hallID)
+	if err != nil {
+		return nil, err
+	}
+
 	var reservations []models.Reservation
-	if err := conf.Db.
+	if err := conf.Db.Scopes(models.NotCancelled).
 		Where("hall_id = ? AND start_date >= ? AND end_date <= ?", hallID, startWindow, endWindow).
 		Order("start_date asc").
 		Find(&reservations).Error; err != nil {
@@ -25,35 +32,32 @@ func SuggestAlternativeDates(conf *configuration.Dependencies, hallID uint, requ
 	var suggestions []models.DateRange
 	requestedDuration := requestedEnd.Sub(requestedStart)
 
-	// If no reservations exist, suggest the requested dates as available.
+	// suggest adds the earliest bookable range within a free gap, if there is one.
+	suggest := func(gapStart, gapEnd time.Time) {
+		if gapEnd.Sub(gapStart) < requestedDuration {
+			return
+		}
+		if start, ok := hallSchedule.NextFit(gapStart, gapEnd, requestedDuration); ok {
+			suggestions = append(suggestions, models.DateRange{Start: start, End: start.Add(requestedDuration)})
+		}
+	}
+
+	// If no reservations exist, suggest the earliest fit from the requested start onwards.
 	if len(reservations) == 0 {
-		suggestions = append(suggestions, models.DateRange{Start: requestedStart, End: requestedStart.Add(requestedDuration)})
+		suggest(requestedStart, endWindow)
 		return suggestions, nil
 	}
 
 	// Check gap before the first reservation.
-	if reservations[0].StartDate.Sub(startWindow) >= requestedDuration {
-		suggestions = append(suggestions, models.DateRange{Start: startWindow, End: startWindow.Add(requestedDuration)})
-	}
+	suggest(startWindow, reservations[0].StartDate)
 
 	// Check gaps between reservations.
 	for i := 0; i < len(reservations)-1; i++ {
-		gap := reservations[i+1].StartDate.Sub(reservations[i].EndDate)
-		if gap >= requestedDuration {
-			suggestions = append(suggestions, models.DateRange{
-				Start: reservations[i].EndDate,
-				End:   reservations[i].EndDate.Add(requestedDuration),
-			})
-		}
+		suggest(reservations[i].EndDate, reservations[i+1].StartDate)
 	}
 
 	// Check gap after the last reservation.
-	if endWindow.Sub(reservations[len(reservations)-1].EndDate) >= requestedDuration {
-		suggestions = append(suggestions, models.DateRange{
-			Start: reservations[len(reservations)-1].EndDate,
-			End:   reservations[len(reservations)-1].EndDate.Add(requestedDuration),
-		})
-	}
+	suggest(reservations[len(reservations)-1].EndDate, endWindow)
 
 	return suggestions, nil
 }
@@ -3,14 +3,18 @@ package reservation
 
 import (
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"storage/configuration"
 	"storage/models"
+	"storage/services/site"
+	"strconv"
 )
 
 // GetReservationSummary aggregates reservation data for dashboard display.
+// Optional filters: site_id and building_id. group_by=building adds a per-building breakdown.
 func GetReservationSummary(conf *configuration.Dependencies) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var reservations []models.Reservation
@@ -21,33 +25,88 @@ func GetReservationSummary(conf *configuration.Dependencies) gin.HandlerFunc {
 			return
 		}
 
-		if err := conf.Db.Find(&reservations).Error; err != nil {
+		var siteID, buildingID uint64
+		var err error
+		if s := c.Query("site_id"); s != "" {
+			if siteID, err = strconv.ParseUint(s, 10, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid site_id"})
+				return
+			}
+		}
+		if b := c.Query("building_id"); b != "" {
+			if buildingID, err = strconv.ParseUint(b, 10, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid building_id"})
+				return
+			}
+		}
+		groupBy := c.Query("group_by")
+		if groupBy != "" && groupBy != "building" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be building"})
+			return
+		}
+
+		query := conf.Db.Scopes(models.NotCancelled)
+		if siteID != 0 || buildingID != 0 {
+			query = query.Where("hall_id IN (?)", site.HallIDs(conf.Db, uint(siteID), uint(buildingID)))
+		}
+		if err := query.Find(&reservations).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reservations"})
 			return
 		}
 
 		now := time.Now()
-		var pastCount, currentCount, upcomingCount int
-		var totalRevenue float64
-
-		for _, r := range reservations {
-			totalRevenue += r.TotalCost
-			if r.EndDate.Before(now) {
-				pastCount++
-			} else if r.StartDate.After(now) {
-				upcomingCount++
-			} else {
-				currentCount++
+		summary := summarize(reservations, now)
+
+		if groupBy == "building" {
+			halls, err := site.BuildingHalls(conf.Db, uint(siteID))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve buildings"})
+				return
 			}
-		}
 
-		summary := gin.H{
-			"total_reservations":    len(reservations),
-			"past_reservations":     pastCount,
-			"current_reservations":  currentCount,
-			"upcoming_reservations": upcomingCount,
-			"total_revenue":         totalRevenue,
+			byBuilding := make(map[uint][]models.Reservation)
+			for _, r := range reservations {
+				if buildingID, ok := halls[r.HallID]; ok {
+					byBuilding[buildingID] = append(byBuilding[buildingID], r)
+				}
+			}
+
+			buildings := make([]gin.H, 0, len(byBuilding))
+			for id, rs := range byBuilding {
+				b := summarize(rs, now)
+				b["building_id"] = id
+				buildings = append(buildings, b)
+			}
+			sort.Slice(buildings, func(i, j int) bool {
+				return buildings[i]["building_id"].(uint) < buildings[j]["building_id"].(uint)
+			})
+			summary["buildings"] = buildings
 		}
+
 		c.JSON(http.StatusOK, summary)
 	}
 }
+
+func summarize(reservations []models.Reservation, now time.Time) gin.H {
+	var pastCount, currentCount, upcomingCount int
+	var totalRevenue float64
+
+	for _, r := range reservations {
+		totalRevenue += r.TotalCost
+		if r.EndDate.Before(now) {
+			pastCount++
+		} else if r.StartDate.After(now) {
+			upcomingCount++
+		} else {
+			currentCount++
+		}
+	}
+
+	return gin.H{
+		"total_reservations":    len(reservations),
+		"past_reservations":     pastCount,
+		"current_reservations":  currentCount,
+		"upcoming_reservations": upcomingCount,
+		"total_revenue":         totalRevenue,
+	}
+}
@@ -0,0 +1,157 @@
+package review
+
+import (
+	"errors"
+	"net/http"
+	"storage/configuration"
+	"storage/models"
+	"storage/services/auth"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errAlreadyReviewed = errors.New("This reservation has already been reviewed")
+
+// CreateReview lets the user who booked a reservation rate the hall once the reservation has ended.
+func CreateReview(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.CurrentPrincipal(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var req struct {
+			Rating  int    `json:"rating" binding:"required"`
+			Comment string `json:"comment" binding:"max=2000"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if req.Rating < models.MinRating || req.Rating > models.MaxRating {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Rating must be between 1 and 5"})
+			return
+		}
+
+		var reservation models.Reservation
+		if err := conf.Db.First(&reservation, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+			return
+		}
+		if reservation.UserID != principal.UserID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the user who booked the reservation can review it"})
+			return
+		}
+		if reservation.IsCancelled() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A cancelled reservation cannot be reviewed"})
+			return
+		}
+		if reservation.EndDate.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The reservation can be reviewed once it has ended"})
+			return
+		}
+
+		review := models.Review{
+			ReservationID: reservation.ID,
+			HallID:        reservation.HallID,
+			UserID:        principal.UserID,
+			Rating:        req.Rating,
+			Comment:       strings.TrimSpace(req.Comment),
+		}
+		err := conf.Db.Transaction(func(tx *gorm.DB) error {
+			var count int64
+			if err := tx.Model(&models.Review{}).Where("reservation_id = ?", reservation.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return errAlreadyReviewed
+			}
+			if err := tx.Create(&review).Error; err != nil {
+				// A concurrent request for the same reservation got past the count first.
+				if errors.Is(err, gorm.ErrDuplicatedKey) {
+					return errAlreadyReviewed
+				}
+				return err
+			}
+			return RefreshHallRating(tx, review.HallID)
+		})
+		if errors.Is(err, errAlreadyReviewed) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review"})
+			return
+		}
+
+		c.JSON(http.StatusOK, review)
+	}
+}
+
+// GetHallReviews lists the visible reviews of a hall, newest first.
+// Admins can pass include_hidden=true to see moderated reviews as well.
+func GetHallReviews(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := conf.Db.Where("hall_id = ?", c.Param("id"))
+		principal, _ := auth.CurrentPrincipal(c)
+		if !(c.Query("include_hidden") == "true" && principal != nil && principal.IsAdmin()) {
+			query = query.Where("hidden = ?", false)
+		}
+
+		var reviews []models.Review
+		if err := query.Order("created_at desc").Find(&reviews).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reviews"})
+			return
+		}
+		c.JSON(http.StatusOK, reviews)
+	}
+}
+
+// HideReview hides an abusive review from listings and ratings.
+func HideReview(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Reason string `json:"reason" binding:"max=255"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		now := time.Now()
+		moderate(c, conf, map[string]interface{}{"hidden": true, "hidden_reason": req.Reason, "hidden_at": &now})
+	}
+}
+
+// UnhideReview makes a hidden review visible again.
+func UnhideReview(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		moderate(c, conf, map[string]interface{}{"hidden": false, "hidden_reason": "", "hidden_at": nil})
+	}
+}
+
+func moderate(c *gin.Context, conf *configuration.Dependencies, updates map[string]interface{}) {
+	var review models.Review
+	if err := conf.Db.First(&review, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+
+	err := conf.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&review).Updates(updates).Error; err != nil {
+			return err
+		}
+		return RefreshHallRating(tx, review.HallID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update review"})
+		return
+	}
+
+	conf.Db.First(&review, review.ID)
+	c.JSON(http.StatusOK, review)
+}
@@ -0,0 +1,28 @@
+package review
+
+import (
+	"storage/models"
+
+	"gorm.io/gorm"
+)
+
+// RefreshHallRating recalculates the average rating and review count of a hall from its
+// visible reviews. Call it in the same transaction as any change to the hall's reviews.
+func RefreshHallRating(tx *gorm.DB, hallID uint) error {
+	var stats struct {
+		Average float64
+		Count   int
+	}
+	if err := tx.Model(&models.Review{}).
+		Select("COALESCE(AVG(rating), 0) AS average, COUNT(*) AS count").
+		Where("hall_id = ? AND hidden = ?", hallID, false).
+		Scan(&stats).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Hall{}).Where("id = ?", hallID).Updates(map[string]interface{}{
+		"average_rating": stats.Average,
+		"review_count":   stats.Count,
+		"version":        gorm.Expr("version + 1"),
+	}).Error
+}
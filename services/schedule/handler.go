@@ -0,0 +1,152 @@
+package schedule
+
+import (
+	"net/http"
+	"storage/configuration"
+	"storage/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetHallSchedule returns the weekly opening hours and date exceptions of a hall.
+func GetHallSchedule(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var hall models.Hall
+		if err := conf.Db.Preload("Floor.Building.Site").First(&hall, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+
+		s, err := Load(conf.Db, &hall)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve schedule"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"hall_id":       hall.ID,
+			"timezone":      s.Location.String(),
+			"opening_hours": s.Weekly,
+			"exceptions":    s.Exceptions,
+		})
+	}
+}
+
+// SetOpeningHours replaces the weekly opening hours of a hall. Weekdays left out are
+// closed days; an empty list makes the hall open around the clock.
+func SetOpeningHours(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var hall models.Hall
+		if err := conf.Db.First(&hall, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+
+		var weekly []models.OpeningHours
+		if err := c.ShouldBindJSON(&weekly); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		seen := make(map[int]bool)
+		for i := range weekly {
+			w := &weekly[i]
+			if w.Weekday < 0 || w.Weekday > 6 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Weekday must be between 0 (Sunday) and 6 (Saturday)"})
+				return
+			}
+			if seen[w.Weekday] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Each weekday may appear only once"})
+				return
+			}
+			seen[w.Weekday] = true
+			if err := ValidateRange(w.Opens, w.Closes); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			w.ID = 0
+			w.HallID = hall.ID
+		}
+
+		err := conf.Db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("hall_id = ?", hall.ID).Delete(&models.OpeningHours{}).Error; err != nil {
+				return err
+			}
+			if len(weekly) == 0 {
+				return nil
+			}
+			return tx.Create(&weekly).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update opening hours"})
+			return
+		}
+
+		if weekly == nil {
+			weekly = []models.OpeningHours{}
+		}
+		c.JSON(http.StatusOK, weekly)
+	}
+}
+
+// AddScheduleException closes a hall on a date or gives it special hours for that day.
+// An existing exception for the same date is replaced.
+func AddScheduleException(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var hall models.Hall
+		if err := conf.Db.First(&hall, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hall not found"})
+			return
+		}
+
+		var exception models.ScheduleException
+		if err := c.ShouldBindJSON(&exception); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if _, err := time.Parse("2006-01-02", exception.Date); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Date must be in YYYY-MM-DD format"})
+			return
+		}
+		if exception.Closed {
+			exception.Opens, exception.Closes = "", ""
+		} else if err := ValidateRange(exception.Opens, exception.Closes); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		exception.ID = 0
+		exception.HallID = hall.ID
+
+		err := conf.Db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("hall_id = ? AND date = ?", hall.ID, exception.Date).
+				Delete(&models.ScheduleException{}).Error; err != nil {
+				return err
+			}
+			return tx.Create(&exception).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save schedule exception"})
+			return
+		}
+		c.JSON(http.StatusOK, exception)
+	}
+}
+
+// DeleteScheduleException removes a date exception, so the weekly hours apply again.
+func DeleteScheduleException(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := conf.Db.Where("id = ? AND hall_id = ?", c.Param("exceptionId"), c.Param("id")).
+			Delete(&models.ScheduleException{})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete schedule exception"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule exception not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Schedule exception deleted successfully"})
+	}
+}
@@ -0,0 +1,215 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"storage/models"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var clockPattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$|^24:00$`)
+
+// ParseClock converts "HH:MM" into the offset from midnight. "24:00" is accepted as the end of the day.
+func ParseClock(s string) (time.Duration, error) {
+	if !clockPattern.MatchString(s) {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	h, _ := strconv.Atoi(s[:2])
+	m, _ := strconv.Atoi(s[3:])
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// ValidateRange checks that opens and closes are valid and opens is before closes.
+// Hours past midnight are not supported; split them over two days instead.
+func ValidateRange(opens, closes string) error {
+	o, err := ParseClock(opens)
+	if err != nil {
+		return err
+	}
+	c, err := ParseClock(closes)
+	if err != nil {
+		return err
+	}
+	if o >= c {
+		return fmt.Errorf("opening time %s must be before closing time %s", opens, closes)
+	}
+	return nil
+}
+
+// Hours describes when a hall is open on a particular day.
+type Hours struct {
+	Open   bool
+	Opens  time.Duration // Offset from local midnight
+	Closes time.Duration
+}
+
+func (h Hours) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(h.Opens) + "-" + clock(h.Closes)
+}
+
+// Schedule is the weekly opening hours of a hall together with its date exceptions.
+type Schedule struct {
+	Weekly     []models.OpeningHours
+	Exceptions []models.ScheduleException
+	Location   *time.Location
+}
+
+// Load reads the schedule of a hall. The hall's Floor.Building.Site chain should be
+// preloaded so the schedule is evaluated in the hall's timezone.
+func Load(db *gorm.DB, hall *models.Hall) (*Schedule, error) {
+	loc, err := time.LoadLocation(hall.Timezone())
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Schedule{Location: loc}
+	if err := db.Where("hall_id = ?", hall.ID).Find(&s.Weekly).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("hall_id = ?", hall.ID).Find(&s.Exceptions).Error; err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadHall fetches a hall with its location hierarchy and loads its schedule.
+func LoadHall(db *gorm.DB, hallID uint) (*models.Hall, *Schedule, error) {
+	var hall models.Hall
+	if err := db.Preload("Floor.Building.Site").First(&hall, hallID).Error; err != nil {
+		return nil, nil, err
+	}
+	s, err := Load(db, &hall)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &hall, s, nil
+}
+
+// On returns the opening hours for the local date of day.
+func (s *Schedule) On(day time.Time) Hours {
+	date := day.In(s.Location).Format("2006-01-02")
+	for _, e := range s.Exceptions {
+		if e.Date != date {
+			continue
+		}
+		if e.Closed {
+			return Hours{}
+		}
+		return hours(e.Opens, e.Closes)
+	}
+
+	if len(s.Weekly) == 0 {
+		return Hours{Open: true, Closes: 24 * time.Hour}
+	}
+	weekday := int(day.In(s.Location).Weekday())
+	for _, w := range s.Weekly {
+		if w.Weekday == weekday {
+			return hours(w.Opens, w.Closes)
+		}
+	}
+	return Hours{}
+}
+
+func hours(opens, closes string) Hours {
+	o, err1 := ParseClock(opens)
+	c, err2 := ParseClock(closes)
+	if err1 != nil || err2 != nil || o >= c {
+		return Hours{}
+	}
+	return Hours{Open: true, Opens: o, Closes: c}
+}
+
+// ClosedError reports why a booking does not fit the opening hours of a hall.
+type ClosedError struct {
+	Date  time.Time
+	Hours Hours
+	msg   string
+}
+
+func (e *ClosedError) Error() string {
+	return e.msg
+}
+
+// Check verifies that a booking from start to end fits the schedule: every day it
+// touches must be an open day, it must start within the first day's hours and end
+// within the last day's hours. An end exactly at midnight counts as the end of the
+// previous day.
+func (s *Schedule) Check(start, end time.Time) error {
+	if !start.Before(end) {
+		return errors.New("start must be before end")
+	}
+
+	start, end = start.In(s.Location), end.In(s.Location)
+	firstDay := midnight(start)
+	lastDay := midnight(end)
+	if end.Equal(lastDay) {
+		lastDay = lastDay.AddDate(0, 0, -1)
+	}
+
+	for day := firstDay; !day.After(lastDay); day = day.AddDate(0, 0, 1) {
+		h := s.On(day)
+		if !h.Open {
+			return &ClosedError{Date: day, Hours: h, msg: fmt.Sprintf("Hall is closed on %s", describe(day))}
+		}
+
+		opens, closes := at(day, h.Opens), at(day, h.Closes)
+		outside := false
+		if day.Equal(firstDay) {
+			outside = outside || start.Before(opens) || !start.Before(closes)
+		}
+		if day.Equal(lastDay) {
+			outside = outside || end.After(closes) || !end.After(opens)
+		}
+		if outside {
+			return &ClosedError{Date: day, Hours: h,
+				msg: fmt.Sprintf("Hall is only open %s on %s", h, describe(day))}
+		}
+	}
+	return nil
+}
+
+// NextFit returns the earliest start at or after from such that a booking of the given
+// duration fits the schedule and ends no later than until. Candidates are from itself
+// and the opening time of each following day.
+func (s *Schedule) NextFit(from, until time.Time, duration time.Duration) (time.Time, bool) {
+	from = from.In(s.Location)
+	candidates := []time.Time{from}
+	for day := midnight(from); !day.After(until); day = day.AddDate(0, 0, 1) {
+		if h := s.On(day); h.Open {
+			if open := at(day, h.Opens); open.After(from) {
+				candidates = append(candidates, open)
+			}
+		}
+	}
+
+	for _, start := range candidates {
+		if start.Add(duration).After(until) {
+			break
+		}
+		if s.Check(start, start.Add(duration)) == nil {
+			return start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// at returns the local wall-clock time offset from the midnight of day. Adding the offset to
+// midnight instead would be an hour off on the days daylight saving time starts or ends.
+func at(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, day.Location())
+}
+
+func describe(day time.Time) string {
+	return day.Format("Monday, 2006-01-02")
+}
@@ -0,0 +1,127 @@
+package schedule
+
+import (
+	"errors"
+	"storage/models"
+	"testing"
+	"time"
+	_ "time/tzdata" // The tests must not depend on the zone database of the machine.
+)
+
+// testSchedule is open 08:00-18:00 on weekdays and 10:00-14:00 on Saturdays, in Sofia.
+// Daylight saving time starts there on Sunday 2025-03-30 (03:00 becomes 04:00) and ends
+// on Sunday 2025-10-26 (04:00 becomes 03:00); both Sundays are opened 09:00-17:00 by exceptions.
+func testSchedule(t *testing.T) *Schedule {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Sofia")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Schedule{Location: loc}
+	for weekday := 1; weekday <= 5; weekday++ {
+		s.Weekly = append(s.Weekly, models.OpeningHours{Weekday: weekday, Opens: "08:00", Closes: "18:00"})
+	}
+	s.Weekly = append(s.Weekly, models.OpeningHours{Weekday: 6, Opens: "10:00", Closes: "14:00"})
+	s.Exceptions = []models.ScheduleException{
+		{Date: "2025-06-04", Closed: true, Reason: "Maintenance"},
+		{Date: "2025-06-05", Opens: "12:00", Closes: "24:00", Reason: "Late event"},
+		{Date: "2025-03-30", Opens: "09:00", Closes: "17:00"},
+		{Date: "2025-10-26", Opens: "09:00", Closes: "17:00"},
+	}
+	return s
+}
+
+func TestCheck(t *testing.T) {
+	s := testSchedule(t)
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2025, month, day, hour, min, 0, 0, s.Location)
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		closed     bool // a ClosedError is expected
+	}{
+		{"within weekday hours", at(6, 2, 9, 0), at(6, 2, 17, 0), false},
+		{"exactly the weekday hours", at(6, 2, 8, 0), at(6, 2, 18, 0), false},
+		{"starts before opening", at(6, 2, 7, 59), at(6, 2, 10, 0), true},
+		{"ends after closing", at(6, 2, 17, 0), at(6, 2, 18, 1), true},
+		{"Saturday hours", at(6, 7, 10, 0), at(6, 7, 14, 0), false},
+		{"past the Saturday closing", at(6, 7, 13, 0), at(6, 7, 15, 0), true},
+		{"Sunday has no hours", at(6, 8, 10, 0), at(6, 8, 12, 0), true},
+		{"closed by an exception", at(6, 4, 9, 0), at(6, 4, 10, 0), true},
+		{"special hours of an exception", at(6, 5, 20, 0), at(6, 5, 23, 0), false},
+		{"weekly hours replaced by an exception", at(6, 5, 9, 0), at(6, 5, 11, 0), true},
+		{"until midnight of a day open to 24:00", at(6, 5, 22, 0), at(6, 6, 0, 0), false},
+		{"over midnight into the next opening", at(6, 5, 22, 0), at(6, 6, 9, 0), false},
+		{"over midnight between open days", at(6, 2, 17, 0), at(6, 3, 9, 0), false},
+		{"over midnight before the next opening", at(6, 6, 17, 0), at(6, 7, 9, 0), true},
+		{"over midnight into a closed day", at(6, 7, 13, 0), at(6, 8, 1, 0), true},
+		{"several days", at(6, 2, 9, 0), at(6, 3, 17, 0), false},
+		{"several days across a closed day", at(6, 3, 9, 0), at(6, 5, 14, 0), true},
+		{"several days across a Sunday", at(6, 7, 10, 0), at(6, 9, 12, 0), true},
+		{"DST start: from the opening", at(3, 30, 9, 0), at(3, 30, 10, 0), false},
+		{"DST start: to the closing", at(3, 30, 16, 0), at(3, 30, 17, 0), false},
+		{"DST start: after the closing", at(3, 30, 17, 0), at(3, 30, 17, 30), true},
+		{"DST end: before the opening", at(10, 26, 8, 30), at(10, 26, 9, 30), true},
+		{"DST end: from the opening", at(10, 26, 9, 0), at(10, 26, 10, 0), false},
+		{"DST end: past the closing", at(10, 26, 16, 30), at(10, 26, 17, 30), true},
+	}
+
+	for _, tt := range tests {
+		err := s.Check(tt.start, tt.end)
+		var closed *ClosedError
+		if got := errors.As(err, &closed); got != tt.closed || (err != nil && !got) {
+			t.Errorf("%s: Check(%s, %s) = %v, want closed = %v", tt.name,
+				tt.start.Format("Mon 01-02 15:04"), tt.end.Format("Mon 01-02 15:04"), err, tt.closed)
+		}
+	}
+
+	if err := s.Check(at(6, 2, 10, 0), at(6, 2, 10, 0)); err == nil {
+		t.Error("Check() accepted an empty booking")
+	}
+}
+
+func TestCheckInOtherTimezone(t *testing.T) {
+	s := testSchedule(t)
+	// 06:00 UTC is 09:00 in Sofia in summer.
+	start := time.Date(2025, 6, 2, 6, 0, 0, 0, time.UTC)
+	if err := s.Check(start, start.Add(2*time.Hour)); err != nil {
+		t.Errorf("Check() in UTC = %v", err)
+	}
+	if err := s.Check(start.Add(-2*time.Hour), start); err == nil {
+		t.Error("Check() accepted 07:00-09:00 local time given in UTC")
+	}
+}
+
+func TestNextFit(t *testing.T) {
+	s := testSchedule(t)
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2025, month, day, hour, min, 0, 0, s.Location)
+	}
+
+	tests := []struct {
+		name        string
+		from, until time.Time
+		duration    time.Duration
+		want        time.Time // zero when nothing fits
+	}{
+		{"fits right away", at(6, 2, 9, 30), at(6, 3, 0, 0), 2 * time.Hour, at(6, 2, 9, 30)},
+		{"waits for the opening", at(6, 2, 6, 0), at(6, 3, 0, 0), 2 * time.Hour, at(6, 2, 8, 0)},
+		{"moves to the next day", at(6, 2, 17, 0), at(6, 4, 0, 0), 2 * time.Hour, at(6, 3, 8, 0)},
+		{"skips the closed exception day", at(6, 3, 17, 0), at(6, 7, 0, 0), 2 * time.Hour, at(6, 5, 12, 0)},
+		{"skips the weekend", at(6, 7, 13, 0), at(6, 10, 0, 0), 2 * time.Hour, at(6, 9, 8, 0)},
+		{"runs out of time", at(6, 2, 17, 0), at(6, 3, 9, 0), 2 * time.Hour, time.Time{}},
+		{"too long for any day", at(6, 2, 0, 0), at(6, 4, 0, 0), 11 * time.Hour, time.Time{}},
+		{"DST start opening", at(3, 30, 0, 0), at(3, 31, 0, 0), time.Hour, at(3, 30, 9, 0)},
+		{"DST end opening", at(10, 26, 0, 0), at(10, 27, 0, 0), time.Hour, at(10, 26, 9, 0)},
+	}
+
+	for _, tt := range tests {
+		got, ok := s.NextFit(tt.from, tt.until, tt.duration)
+		if ok != !tt.want.IsZero() || (ok && !got.Equal(tt.want)) {
+			t.Errorf("%s: NextFit() = %s, %v, want %s", tt.name, got.Format("Mon 01-02 15:04 MST"), ok, tt.want.Format("Mon 01-02 15:04 MST"))
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package session
+
+import "time"
+
+// Session is one login of a user. Its ID is the jti of every access token issued
+// for it, so revoking the session invalidates those tokens straight away.
+type Session struct {
+	ID            string     `gorm:"primaryKey;size:32" json:"id"`
+	UserID        int64      `gorm:"not null;index" json:"user_id"`
+	UserAgent     string     `gorm:"size:255" json:"user_agent"`
+	IP            string     `gorm:"size:64" json:"ip"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastUsedAt    time.Time  `json:"last_used_at"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `gorm:"size:100" json:"revoked_reason,omitempty"`
+}
+
+// RefreshToken is one link in a session's chain of refresh tokens. Only the SHA-256
+// of the token is stored. A token can be used once; using it again means it leaked.
+type RefreshToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	SessionID string    `gorm:"not null;size:32;index"`
+	TokenHash string    `gorm:"not null;size:64;uniqueIndex"`
+	CreatedAt time.Time `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+func (Session) TableName() string {
+	return "hall_res_project.sessions"
+}
+
+func (RefreshToken) TableName() string {
+	return "hall_res_project.refresh_tokens"
+}
+
+// Active reports whether the session can still be used at the given time.
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
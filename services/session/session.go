@@ -0,0 +1,200 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidToken is returned for refresh tokens that are unknown or expired, or whose session has ended.
+	ErrInvalidToken = errors.New("invalid or expired refresh token")
+	// ErrTokenReuse is returned when a refresh token is presented a second time.
+	// The whole session is revoked, since either the client or an attacker holds a stolen token.
+	ErrTokenReuse = errors.New("refresh token reuse detected; session revoked")
+)
+
+// Reasons recorded when a session is revoked.
+const (
+	ReasonLogout         = "logout"
+	ReasonReuse          = "refresh token reuse"
+	ReasonRevokedByUser  = "revoked by user"
+	ReasonPasswordChange = "password changed"
+	ReasonPasswordReset  = "password reset"
+)
+
+// Create starts a session for a user and returns it with its first refresh token.
+// The session lasts as long as its refresh tokens keep being rotated within ttl.
+func Create(db *gorm.DB, userID int64, userAgent, ip string, ttl time.Duration) (*Session, string, error) {
+	id, err := randomString(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	s := &Session{
+		ID:         id,
+		UserID:     userID,
+		UserAgent:  truncate(userAgent, 255),
+		IP:         truncate(ip, 64),
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+
+	var token string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(s).Error; err != nil {
+			return err
+		}
+		token, err = issue(tx, s.ID, now, ttl)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return s, token, nil
+}
+
+// Rotate exchanges a refresh token for a new one. The presented token is spent; presenting
+// it again revokes the session and returns ErrTokenReuse.
+func Rotate(db *gorm.DB, token string, ttl time.Duration) (*Session, string, error) {
+	now := time.Now()
+	var s Session
+	var next string
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", Hash(token)).First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.First(&s, "id = ?", current.SessionID).Error; err != nil {
+			return err
+		}
+		if current.UsedAt != nil {
+			return ErrTokenReuse
+		}
+		if !s.Active(now) || !now.Before(current.ExpiresAt) {
+			return ErrInvalidToken
+		}
+
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		s.LastUsedAt = now
+		s.ExpiresAt = now.Add(ttl)
+		if err := tx.Model(&s).Updates(map[string]interface{}{"last_used_at": s.LastUsedAt, "expires_at": s.ExpiresAt}).Error; err != nil {
+			return err
+		}
+
+		next, err = issue(tx, s.ID, now, ttl)
+		return err
+	})
+
+	if errors.Is(err, ErrTokenReuse) {
+		// The transaction was rolled back; revoke outside it so the revocation sticks.
+		if revokeErr := Revoke(db, s.ID, ReasonReuse); revokeErr != nil {
+			return nil, "", revokeErr
+		}
+		return nil, "", ErrTokenReuse
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &s, next, nil
+}
+
+// Revoke ends a session. Access tokens carrying its ID are rejected from then on.
+func Revoke(db *gorm.DB, sessionID, reason string) error {
+	return db.Model(&Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason}).Error
+}
+
+// RevokeAll ends every live session of a user.
+func RevokeAll(db *gorm.DB, userID int64, reason string) error {
+	return db.Model(&Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason}).Error
+}
+
+// RevokeOthers ends every live session of a user except one, e.g. after a password change.
+func RevokeOthers(db *gorm.DB, userID int64, keepID, reason string) error {
+	return db.Model(&Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason}).Error
+}
+
+// ListActive returns the live sessions of a user, most recently used first.
+func ListActive(db *gorm.DB, userID int64, now time.Time) ([]Session, error) {
+	var sessions []Session
+	err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_used_at desc").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Find loads a session by ID. It is a primary-key lookup, cheap enough for every request.
+func Find(db *gorm.DB, sessionID string) (*Session, error) {
+	var s Session
+	if err := db.First(&s, "id = ?", sessionID).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Hash returns the hex SHA-256 of a token, as stored in the database.
+// Tokens are long random strings, so a fast unsalted hash is sufficient.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func issue(tx *gorm.DB, sessionID string, now time.Time, ttl time.Duration) (string, error) {
+	token, err := RandomToken()
+	if err != nil {
+		return "", err
+	}
+	err = tx.Create(&RefreshToken{
+		SessionID: sessionID,
+		TokenHash: Hash(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}).Error
+	return token, err
+}
+
+// RandomToken returns 32 random bytes, URL-safe base64 encoded.
+func RandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,242 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms.
+const (
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// minRSABits is the smallest RSA modulus accepted for signing keys.
+const minRSABits = 2048
+
+// KeyConfig names a private key file and the period it is used in. A key is published
+// and accepted for verification from the moment it is loaded until RetireAt, but only
+// signs tokens from ActiveFrom on. Scheduling the next key's ActiveFrom ahead of time
+// lets other services pick it up from the JWKS before the first token uses it.
+type KeyConfig struct {
+	ID         string    `json:"kid"`
+	File       string    `json:"file"`                  // PEM encoded PKCS#8 (RSA or Ed25519) or PKCS#1 (RSA) private key
+	ActiveFrom time.Time `json:"active_from,omitempty"` // Zero means immediately
+	RetireAt   time.Time `json:"retire_at,omitempty"`   // Zero means never; keep it past the last token's expiry
+}
+
+// Key is a loaded signing key.
+type Key struct {
+	ID         string
+	Algorithm  string
+	ActiveFrom time.Time
+	RetireAt   time.Time
+	signer     crypto.Signer
+}
+
+// Public returns the public half of the key.
+func (k *Key) Public() crypto.PublicKey {
+	return k.signer.Public()
+}
+
+func (k *Key) retired(now time.Time) bool {
+	return !k.RetireAt.IsZero() && !now.Before(k.RetireAt)
+}
+
+func (k *Key) signing(now time.Time) bool {
+	return !now.Before(k.ActiveFrom) && !k.retired(now)
+}
+
+// KeySet holds every configured key. The key that signs is chosen at the time of
+// signing, so rotation happens on schedule without a restart.
+type KeySet struct {
+	keys []*Key // Sorted by ActiveFrom, newest first
+}
+
+// Load reads the configured keys. It fails unless every key is valid and at least
+// one of them can sign right now, so a server never starts without key material.
+func Load(configs []KeyConfig, now time.Time) (*KeySet, error) {
+	if len(configs) == 0 {
+		return nil, errors.New("no signing keys configured")
+	}
+
+	ks := &KeySet{}
+	seen := make(map[string]bool)
+	for _, cfg := range configs {
+		if cfg.ID == "" {
+			return nil, errors.New("signing key without kid")
+		}
+		if seen[cfg.ID] {
+			return nil, fmt.Errorf("duplicate signing key %q", cfg.ID)
+		}
+		seen[cfg.ID] = true
+		if !cfg.RetireAt.IsZero() && !cfg.ActiveFrom.Before(cfg.RetireAt) {
+			return nil, fmt.Errorf("signing key %q retires before it becomes active", cfg.ID)
+		}
+
+		data, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", cfg.ID, err)
+		}
+		signer, alg, err := ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", cfg.ID, err)
+		}
+
+		ks.keys = append(ks.keys, &Key{
+			ID:         cfg.ID,
+			Algorithm:  alg,
+			ActiveFrom: cfg.ActiveFrom,
+			RetireAt:   cfg.RetireAt,
+			signer:     signer,
+		})
+	}
+
+	sort.SliceStable(ks.keys, func(i, j int) bool { return ks.keys[i].ActiveFrom.After(ks.keys[j].ActiveFrom) })
+	if _, err := ks.SigningKey(now); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// SigningKey returns the key that signs tokens at the given time: the most recently
+// activated key that has not been retired.
+func (ks *KeySet) SigningKey(now time.Time) (*Key, error) {
+	for _, k := range ks.keys {
+		if k.signing(now) {
+			return k, nil
+		}
+	}
+	return nil, errors.New("no signing key is active")
+}
+
+// Sign signs the claims with the current signing key and names it in the kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key, err := ks.SigningKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signer)
+}
+
+// Keyfunc resolves the verification key of a token from its kid header, for use with
+// the jwt parser. Unknown and retired keys, and algorithms not matching the key, are refused.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key := ks.find(kid, time.Now())
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return key.Public(), nil
+}
+
+// Algorithms lists the algorithms of the configured keys, for the parser's allow-list.
+func (ks *KeySet) Algorithms() []string {
+	var algs []string
+	seen := make(map[string]bool)
+	for _, k := range ks.keys {
+		if !seen[k.Algorithm] {
+			seen[k.Algorithm] = true
+			algs = append(algs, k.Algorithm)
+		}
+	}
+	return algs
+}
+
+// Published returns the keys that verifiers should accept: every key not yet retired,
+// including those scheduled to start signing later.
+func (ks *KeySet) Published(now time.Time) []*Key {
+	var keys []*Key
+	for _, k := range ks.keys {
+		if !k.retired(now) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (ks *KeySet) find(kid string, now time.Time) *Key {
+	for _, k := range ks.keys {
+		if k.ID == kid && !k.retired(now) {
+			return k
+		}
+	}
+	return nil
+}
+
+// ParsePrivateKey decodes a PEM private key and returns it with its JWT algorithm.
+func ParsePrivateKey(data []byte) (crypto.Signer, string, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, "", errors.New("no PEM data found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, "", fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSABits {
+			return nil, "", fmt.Errorf("RSA key has %d bits, at least %d are required", key.N.BitLen(), minRSABits)
+		}
+		if err := key.Validate(); err != nil {
+			return nil, "", err
+		}
+		return key, RS256, nil
+	case ed25519.PrivateKey:
+		return key, EdDSA, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported key type %T; use RSA or Ed25519", parsed)
+	}
+}
+
+// GenerateKey creates a new private key for the algorithm, PEM encoded as PKCS#8.
+func GenerateKey(alg string) ([]byte, error) {
+	var key interface{}
+	var err error
+	switch alg {
+	case RS256:
+		key, err = rsa.GenerateKey(rand.Reader, 3072)
+	case EdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q; use RS256 or EdDSA", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,288 @@
+package site
+
+import (
+	"net/http"
+	"storage/configuration"
+	"storage/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSites lists all sites with their buildings and floors.
+func GetSites(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var sites []models.Site
+		if err := conf.Db.Preload("Buildings.Floors").Order("name asc").Find(&sites).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sites"})
+			return
+		}
+		c.JSON(http.StatusOK, sites)
+	}
+}
+
+// CreateSite adds a site.
+func CreateSite(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var s models.Site
+		if err := c.ShouldBindJSON(&s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		s.ID = 0
+		s.Buildings = nil
+		if !validSite(c, &s) {
+			return
+		}
+
+		if err := conf.Db.Create(&s).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create site"})
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	}
+}
+
+// UpdateSite changes the details of a site.
+func UpdateSite(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var s models.Site
+		if err := conf.Db.First(&s, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Site not found"})
+			return
+		}
+
+		id := s.ID
+		if err := c.ShouldBindJSON(&s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		s.ID = id
+		s.Buildings = nil
+		if !validSite(c, &s) {
+			return
+		}
+
+		if err := conf.Db.Save(&s).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update site"})
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	}
+}
+
+// DeleteSite removes a site that has no buildings left.
+func DeleteSite(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var s models.Site
+		if err := conf.Db.First(&s, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Site not found"})
+			return
+		}
+
+		var count int64
+		conf.Db.Model(&models.Building{}).Where("site_id = ?", s.ID).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Site still has buildings"})
+			return
+		}
+
+		if err := conf.Db.Delete(&s).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete site"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Site deleted successfully"})
+	}
+}
+
+// GetBuildings lists buildings, optionally only those of one site (site_id query parameter).
+func GetBuildings(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := conf.Db.Preload("Site").Preload("Floors")
+		if siteID := c.Query("site_id"); siteID != "" {
+			query = query.Where("site_id = ?", siteID)
+		}
+
+		var buildings []models.Building
+		if err := query.Order("name asc").Find(&buildings).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve buildings"})
+			return
+		}
+		c.JSON(http.StatusOK, buildings)
+	}
+}
+
+// CreateBuilding adds a building to a site.
+func CreateBuilding(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var b models.Building
+		if err := c.ShouldBindJSON(&b); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		b.ID = 0
+		b.Site = nil
+		b.Floors = nil
+		if !validBuilding(c, conf, &b) {
+			return
+		}
+
+		if err := conf.Db.Create(&b).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create building"})
+			return
+		}
+		c.JSON(http.StatusOK, b)
+	}
+}
+
+// UpdateBuilding changes the details of a building, including moving it to another site.
+func UpdateBuilding(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var b models.Building
+		if err := conf.Db.First(&b, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Building not found"})
+			return
+		}
+
+		id := b.ID
+		if err := c.ShouldBindJSON(&b); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		b.ID = id
+		b.Site = nil
+		b.Floors = nil
+		if !validBuilding(c, conf, &b) {
+			return
+		}
+
+		if err := conf.Db.Save(&b).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update building"})
+			return
+		}
+		c.JSON(http.StatusOK, b)
+	}
+}
+
+// DeleteBuilding removes a building that has no floors left.
+func DeleteBuilding(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var b models.Building
+		if err := conf.Db.First(&b, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Building not found"})
+			return
+		}
+
+		var count int64
+		conf.Db.Model(&models.Floor{}).Where("building_id = ?", b.ID).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Building still has floors"})
+			return
+		}
+
+		if err := conf.Db.Delete(&b).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete building"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Building deleted successfully"})
+	}
+}
+
+// CreateFloor adds a floor to a building.
+func CreateFloor(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buildingID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid building ID"})
+			return
+		}
+
+		var f models.Floor
+		if err := c.ShouldBindJSON(&f); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		f.ID = 0
+		f.BuildingID = uint(buildingID)
+		f.Building = nil
+
+		var count int64
+		conf.Db.Model(&models.Building{}).Where("id = ?", f.BuildingID).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Building not found"})
+			return
+		}
+		conf.Db.Model(&models.Floor{}).Where("building_id = ? AND level = ?", f.BuildingID, f.Level).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Building already has a floor at this level"})
+			return
+		}
+
+		if err := conf.Db.Create(&f).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create floor"})
+			return
+		}
+		c.JSON(http.StatusOK, f)
+	}
+}
+
+// DeleteFloor removes a floor that no hall is placed on.
+func DeleteFloor(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var f models.Floor
+		if err := conf.Db.First(&f, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Floor not found"})
+			return
+		}
+
+		var count int64
+		conf.Db.Model(&models.Hall{}).Where("floor_id = ?", f.ID).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Halls are still located on this floor"})
+			return
+		}
+
+		if err := conf.Db.Delete(&f).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete floor"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Floor deleted successfully"})
+	}
+}
+
+func validSite(c *gin.Context, s *models.Site) bool {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Site name is required"})
+		return false
+	}
+	if s.Timezone == "" {
+		s.Timezone = models.DefaultTimezone
+	}
+	if err := ValidateTimezone(s.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+func validBuilding(c *gin.Context, conf *configuration.Dependencies, b *models.Building) bool {
+	b.Name = strings.TrimSpace(b.Name)
+	if b.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Building name is required"})
+		return false
+	}
+	if err := ValidateTimezone(b.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	var count int64
+	conf.Db.Model(&models.Site{}).Where("id = ?", b.SiteID).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Site not found"})
+		return false
+	}
+	return true
+}
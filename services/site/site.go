@@ -0,0 +1,61 @@
+package site
+
+import (
+	"fmt"
+	"storage/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ValidateTimezone checks that tz is a known IANA timezone name.
+func ValidateTimezone(tz string) error {
+	if tz == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return fmt.Errorf("unknown timezone %q", tz)
+	}
+	return nil
+}
+
+// HallIDs returns a subquery selecting the IDs of halls in a site and/or building.
+// Zero IDs are ignored.
+func HallIDs(db *gorm.DB, siteID, buildingID uint) *gorm.DB {
+	q := db.Session(&gorm.Session{NewDB: true}).
+		Table(models.Hall{}.TableName() + " AS h").
+		Select("h.id").
+		Joins("JOIN " + models.Floor{}.TableName() + " AS f ON f.id = h.floor_id").
+		Joins("JOIN " + models.Building{}.TableName() + " AS b ON b.id = f.building_id")
+	if siteID != 0 {
+		q = q.Where("b.site_id = ?", siteID)
+	}
+	if buildingID != 0 {
+		q = q.Where("b.id = ?", buildingID)
+	}
+	return q
+}
+
+// BuildingHalls maps the ID of every hall placed in a building to that building's ID.
+func BuildingHalls(db *gorm.DB, siteID uint) (map[uint]uint, error) {
+	var rows []struct {
+		HallID     uint
+		BuildingID uint
+	}
+	q := db.Table(models.Hall{}.TableName() + " AS h").
+		Select("h.id AS hall_id, b.id AS building_id").
+		Joins("JOIN " + models.Floor{}.TableName() + " AS f ON f.id = h.floor_id").
+		Joins("JOIN " + models.Building{}.TableName() + " AS b ON b.id = f.building_id")
+	if siteID != 0 {
+		q = q.Where("b.site_id = ?", siteID)
+	}
+	if err := q.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	halls := make(map[uint]uint, len(rows))
+	for _, r := range rows {
+		halls[r.HallID] = r.BuildingID
+	}
+	return halls, nil
+}
@@ -0,0 +1,107 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of RFC 6238 Appendix B, "12345678901234567890", in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; 6-digit codes are their last six digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tt.want[len(tt.want)-Digits:]; got != want {
+			t.Errorf("Code() at %d = %s, want %s", tt.unix, got, want)
+		}
+	}
+}
+
+func TestCodeRejectsInvalidSecret(t *testing.T) {
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code() accepted an invalid secret")
+	}
+	if _, err := Code(strings.ToLower(rfcSecret)+" ", 1); err != nil {
+		t.Errorf("Code() with a lower-case secret = %v", err)
+	}
+}
+
+func TestValidateWindow(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	for offset := int64(-3); offset <= 3; offset++ {
+		code, _ := Code(rfcSecret, current+offset)
+		step, ok := Validate(rfcSecret, code, now, 0)
+		if want := offset >= -Skew && offset <= Skew; ok != want {
+			t.Errorf("code of step %+d accepted = %v, want %v", offset, ok, want)
+		}
+		if ok && step != current+offset {
+			t.Errorf("code of step %+d matched step %d", offset, step-current)
+		}
+	}
+}
+
+func TestValidateRefusesReusedSteps(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+	code, _ := Code(rfcSecret, current)
+
+	step, ok := Validate(rfcSecret, code, now, 0)
+	if !ok || step != current {
+		t.Fatalf("Validate() = %d, %v, want step %d", step, ok, current)
+	}
+	if _, ok := Validate(rfcSecret, code, now, step); ok {
+		t.Error("the code of the last accepted step was accepted again")
+	}
+	// A code from before the last accepted one is refused too, even within the skew.
+	previous, _ := Code(rfcSecret, current-1)
+	if _, ok := Validate(rfcSecret, previous, now, step); ok {
+		t.Error("a code older than the last accepted step was accepted")
+	}
+	next, _ := Code(rfcSecret, current+1)
+	if got, ok := Validate(rfcSecret, next, now, step); !ok || got != current+1 {
+		t.Errorf("code of the next step = %d, %v", got, ok)
+	}
+}
+
+func TestValidateInput(t *testing.T) {
+	now := time.Unix(2000000000, 0)
+	code, _ := Code(rfcSecret, Step(now))
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{code, true},
+		{" " + code[:3] + " " + code[3:] + " ", true},
+		{code[:5], false},
+		{code + "0", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if _, ok := Validate(rfcSecret, tt.input, now, 0); ok != tt.want {
+			t.Errorf("Validate(%q) = %v, want %v", tt.input, ok, tt.want)
+		}
+	}
+	if _, ok := Validate("not base32!", code, now, 0); ok {
+		t.Error("Validate() accepted a code for an invalid secret")
+	}
+}
@@ -0,0 +1,313 @@
+package twofactor
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"storage/configuration"
+	"storage/services/audit"
+	"storage/services/auth"
+	"storage/services/lockout"
+	"storage/services/totp"
+	"storage/services/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type codeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// GetStatus tells the authenticated user whether 2FA is on and whether their roles require it.
+func GetStatus(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := auth.CurrentPrincipal(c)
+
+		var u user.User
+		if err := conf.Db.Preload("Roles").Where("id = ?", principal.UserID).First(&u).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		enrolled, err := Enrolled(conf.Db, u.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve two-factor status"})
+			return
+		}
+
+		var left int64
+		conf.Db.Model(&RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", u.UserID).Count(&left)
+
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":             enrolled,
+			"required":            Required(u.Roles),
+			"recovery_codes_left": left,
+		})
+	}
+}
+
+// Enroll creates a new TOTP secret for the authenticated user. The returned otpauth URI is
+// shown as a QR code; 2FA is switched on once Confirm receives a first valid code.
+func Enroll(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := auth.CurrentPrincipal(c)
+
+		enrolled, err := Enrolled(conf.Db, principal.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve two-factor status"})
+			return
+		}
+		if enrolled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+			return
+		}
+
+		// Starting over replaces an unconfirmed secret.
+		cred := Credential{UserID: principal.UserID, Secret: secret}
+		if err := conf.Db.Save(&cred).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"secret":           secret,
+			"provisioning_uri": totp.ProvisioningURI(conf.Cfg.Auth.TokenIssuer(), principal.Username, secret),
+		})
+	}
+}
+
+// Confirm switches 2FA on after checking a code from the newly enrolled authenticator,
+// and returns the recovery codes. They are shown only this once.
+func Confirm(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := auth.CurrentPrincipal(c)
+
+		var req codeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var codes []string
+		err := conf.Db.Transaction(func(tx *gorm.DB) error {
+			var cred Credential
+			if err := tx.Where("user_id = ? AND confirmed_at IS NULL", principal.UserID).First(&cred).Error; err != nil {
+				return err
+			}
+			step, ok := totp.Validate(cred.Secret, req.Code, time.Now(), 0)
+			if !ok {
+				return ErrInvalidCode
+			}
+			if err := tx.Model(&cred).Updates(map[string]interface{}{"confirmed_at": time.Now(), "last_step": step}).Error; err != nil {
+				return err
+			}
+			var err error
+			codes, err = replaceRecoveryCodes(tx, principal.UserID)
+			return err
+		})
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusConflict, gin.H{"error": "No enrollment in progress"})
+			return
+		case errors.Is(err, ErrInvalidCode):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+			return
+		}
+
+		audit.Record(conf.Db, audit.AuthEvent{Type: audit.EventTwoFactorOn, Username: principal.Username, UserID: &principal.UserID, IP: c.ClientIP()})
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the authenticated user. A current code is required.
+func RegenerateRecoveryCodes(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := auth.CurrentPrincipal(c)
+
+		var req codeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		attempt, ok := beginAttempt(c, conf, principal.Username)
+		if !ok {
+			return
+		}
+		defer attempt.Release(c.Request.Context())
+		if err := Verify(conf.Db, principal.UserID, req.Code, time.Now()); err != nil {
+			attemptFailed(c, conf, attempt, principal.Username, principal.UserID)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+			return
+		}
+
+		var codes []string
+		err := conf.Db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			codes, err = replaceRecoveryCodes(tx, principal.UserID)
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// Disable switches 2FA off for the authenticated user, who must give their password and a
+// current code. It is refused while one of their roles requires 2FA.
+func Disable(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, _ := auth.CurrentPrincipal(c)
+
+		var req struct {
+			Password string `json:"password" binding:"required"`
+			Code     string `json:"code" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var u user.User
+		if err := conf.Db.Preload("Roles").Where("id = ?", principal.UserID).First(&u).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if Required(u.Roles) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is required for your role"})
+			return
+		}
+		username := strings.ToLower(u.Username)
+		attempt, ok := beginAttempt(c, conf, username)
+		if !ok {
+			return
+		}
+		defer attempt.Release(c.Request.Context())
+		if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.Password)); err != nil {
+			attemptFailed(c, conf, attempt, username, u.UserID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+			return
+		}
+		if err := Verify(conf.Db, u.UserID, req.Code, time.Now()); err != nil {
+			attemptFailed(c, conf, attempt, username, u.UserID)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+			return
+		}
+
+		if err := remove(conf.Db, u.UserID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+			return
+		}
+		audit.Record(conf.Db, audit.AuthEvent{Type: audit.EventTwoFactorOff, Username: principal.Username, UserID: &u.UserID, IP: c.ClientIP()})
+		c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+	}
+}
+
+// ResetUser removes the 2FA setup of a user who lost their authenticator and recovery codes.
+// If their roles require 2FA, they must enroll again at their next login.
+func ResetUser(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var u user.User
+		if err := conf.Db.Where("id = ?", userID).First(&u).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err := remove(conf.Db, u.UserID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+			return
+		}
+
+		event := audit.AuthEvent{Type: audit.EventTwoFactorReset, Username: u.Username, UserID: &u.UserID, IP: c.ClientIP()}
+		if principal, ok := auth.CurrentPrincipal(c); ok {
+			event.ActorID = &principal.UserID
+		}
+		audit.Record(conf.Db, event)
+		c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+	}
+}
+
+// SetRoleRequirement makes 2FA mandatory, or optional again, for the members of a role.
+func SetRoleRequirement(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Required *bool `json:"required" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var role user.Role
+		if err := conf.Db.Where("id = ?", c.Param("id")).First(&role).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+			return
+		}
+		if err := conf.Db.Model(&role).Update("require_two_factor", *req.Required).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+			return
+		}
+		c.JSON(http.StatusOK, role)
+	}
+}
+
+func remove(db *gorm.DB, userID int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&Credential{}).Error
+	})
+}
+
+// beginAttempt admits an attempt of the user, or answers the request and returns false
+// while their logins are locked or throttled. The endpoints that take a password or code
+// share the login lockout, so they cannot be used to guess either of them around it.
+func beginAttempt(c *gin.Context, conf *configuration.Dependencies, username string) (*lockout.Attempt, bool) {
+	attempt, verdict, err := conf.Logins.Begin(c.Request.Context(), username, c.ClientIP(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify attempt"})
+		return nil, false
+	}
+	if verdict.Allowed() {
+		return attempt, true
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(verdict.RetryAfter.Seconds()))))
+	if verdict.Locked {
+		c.JSON(http.StatusLocked, gin.H{"error": "Account is temporarily locked after too many failed attempts"})
+	} else {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+	}
+	return nil, false
+}
+
+// attemptFailed settles an attempt with a wrong password or code as a failed login of the user.
+func attemptFailed(c *gin.Context, conf *configuration.Dependencies, attempt *lockout.Attempt, username string, userID int64) {
+	locked, err := attempt.Fail(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to record failed attempt of %q: %v", username, err)
+	}
+	if locked {
+		audit.Record(conf.Db, audit.AuthEvent{Type: audit.EventAccountLocked, Username: username, UserID: &userID, IP: c.ClientIP(), Detail: "too many failed two-factor attempts"})
+	}
+}
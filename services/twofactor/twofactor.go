@@ -0,0 +1,146 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"storage/services/session"
+	"storage/services/totp"
+	"storage/services/user"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecoveryCodeCount is how many one-time recovery codes a user gets.
+const RecoveryCodeCount = 10
+
+// ErrInvalidCode is returned for wrong, reused or expired codes.
+var ErrInvalidCode = errors.New("invalid two-factor code")
+
+// Credential is the TOTP secret of a user. It only protects logins once confirmed
+// with a first valid code, so a half-finished enrollment cannot lock the user out.
+//
+// Secret is stored in plaintext on purpose: unlike a password it cannot be hashed, since
+// every check computes codes from it, and encrypting it with a key held by the same server
+// would not protect it from anyone who can read both. Treat the table like the signing
+// keys: restrict access to it and to its backups.
+type Credential struct {
+	UserID      int64      `gorm:"primaryKey" json:"-"`
+	Secret      string     `gorm:"not null;size:64" json:"-"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	LastStep    int64      `gorm:"not null;default:0" json:"-"` // Time step of the last accepted code, to refuse replays
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// RecoveryCode stands in for a TOTP code once, e.g. after losing the phone. Only the SHA-256 is stored.
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    int64  `gorm:"not null;index"`
+	CodeHash  string `gorm:"not null;size:64;uniqueIndex"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (Credential) TableName() string {
+	return "hall_res_project.user_totp"
+}
+
+func (RecoveryCode) TableName() string {
+	return "hall_res_project.user_recovery_codes"
+}
+
+// Enrolled reports whether the user has confirmed a TOTP credential.
+func Enrolled(db *gorm.DB, userID int64) (bool, error) {
+	var n int64
+	err := db.Model(&Credential{}).Where("user_id = ? AND confirmed_at IS NOT NULL", userID).Count(&n).Error
+	return n > 0, err
+}
+
+// Required reports whether any of the roles makes two-factor authentication mandatory.
+func Required(roles []user.Role) bool {
+	for _, r := range roles {
+		if r.RequireTwoFactor {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks a TOTP code or, failing that, an unused recovery code, and spends it.
+func Verify(db *gorm.DB, userID int64, code string, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var cred Credential
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND confirmed_at IS NOT NULL", userID).First(&cred).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidCode
+		}
+		if err != nil {
+			return err
+		}
+
+		if step, ok := totp.Validate(cred.Secret, code, now, cred.LastStep); ok {
+			return tx.Model(&cred).Update("last_step", step).Error
+		}
+
+		res := tx.Model(&RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, session.Hash(normalizeRecoveryCode(code))).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalidCode
+		}
+		return nil
+	})
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and returns a fresh set.
+func replaceRecoveryCodes(tx *gorm.DB, userID int64) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, RecoveryCodeCount)
+	rows := make([]RecoveryCode, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		rows = append(rows, RecoveryCode{UserID: userID, CodeHash: session.Hash(normalizeRecoveryCode(code))})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// recoveryAlphabet leaves out characters that are easily confused when read back.
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// newRecoveryCode returns a code like "k7q2m-x9d4h", about 49 bits of entropy.
+func newRecoveryCode() (string, error) {
+	var sb strings.Builder
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			sb.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(recoveryAlphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -5,14 +5,23 @@ import (
 )
 
 type User struct {
-	UserID    int64     `gorm:"column:id;primaryKey" json:"id"`
-	Username  string    `gorm:"column:username;size:50;unique;not null" json:"username"`
-	Password  string    `gorm:"column:password;size:255;not null" json:"-"`
-	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
-	LastLogin time.Time `gorm:"column:last_login" json:"last_login"`
-	IsActive  bool      `gorm:"column:is_active" json:"is_active"`
-	Roles     []Role    `gorm:"many2many:hall_res_project.users_roles;joinForeignKey:UserID;joinReferences:RoleID" json:"roles,omitempty"`
+	UserID          int64      `gorm:"column:id;primaryKey" json:"id"`
+	Username        string     `gorm:"column:username;size:50;unique;not null" json:"username"`
+	Password        string     `gorm:"column:password;size:255;not null" json:"-"`
+	Email           *string    `gorm:"column:email;size:255;uniqueIndex" json:"email,omitempty"` // Stored lower case; unset for accounts from before registration asked for it
+	EmailVerifiedAt *time.Time `gorm:"column:email_verified_at" json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt       time.Time  `gorm:"column:updated_at" json:"updated_at"`
+	LastLogin       time.Time  `gorm:"column:last_login" json:"last_login"`
+	IsActive        bool       `gorm:"column:is_active" json:"is_active"`
+	Language        string     `gorm:"column:language;size:10" json:"language"` // Used for receipts; empty until chosen, so the browser's language applies
+	Roles           []Role     `gorm:"many2many:hall_res_project.users_roles;joinForeignKey:UserID;joinReferences:RoleID" json:"roles,omitempty"`
+}
+
+// NeedsVerification reports whether the user has an email address they have not confirmed yet.
+// Such users can read but not change anything outside their own account.
+func (u *User) NeedsVerification() bool {
+	return u.Email != nil && u.EmailVerifiedAt == nil
 }
 
 type UserRoles struct {
@@ -21,10 +30,11 @@ type UserRoles struct {
 }
 
 type Role struct {
-	RoleID    int64     `gorm:"column:id;primaryKey" json:"role_id"`
-	RoleName  string    `gorm:"column:role_name;unique;size:255;not null" json:"role_name"`
-	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
-	Users     []User    `gorm:"many2many:hall_res_project.users_roles;joinForeignKey:RoleID;joinReferences:UserID" json:"users,omitempty"`
+	RoleID           int64     `gorm:"column:id;primaryKey" json:"role_id"`
+	RoleName         string    `gorm:"column:role_name;unique;size:255;not null" json:"role_name"`
+	CreatedAt        time.Time `gorm:"column:created_at" json:"created_at"`
+	RequireTwoFactor bool      `gorm:"column:require_two_factor;not null;default:false" json:"require_two_factor"` // Members must set up 2FA
+	Users            []User    `gorm:"many2many:hall_res_project.users_roles;joinForeignKey:RoleID;joinReferences:UserID" json:"users,omitempty"`
 }
 
 type ChangePassword struct {
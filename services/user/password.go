@@ -0,0 +1,42 @@
+package user
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// Password policy limits. bcrypt only uses the first 72 bytes, so longer passwords are refused
+// rather than silently truncated.
+const (
+	MinPasswordLength = 10
+	MaxPasswordBytes  = 72
+)
+
+// ValidatePassword checks a new password against the password policy.
+func ValidatePassword(password, username string) error {
+	if len([]rune(password)) < MinPasswordLength {
+		return errors.New("password must be at least 10 characters long")
+	}
+	if len(password) > MaxPasswordBytes {
+		return errors.New("password must be at most 72 bytes long")
+	}
+
+	var letter, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	if !letter || !digit {
+		return errors.New("password must contain both letters and digits")
+	}
+
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return errors.New("password must not contain the username")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package user
+
+import (
+	"net/http"
+	"storage/configuration"
+	"storage/services/audit"
+	"storage/services/auth"
+	"storage/services/session"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ReasonDeactivated is recorded on the sessions ended by deactivating their user.
+const ReasonDeactivated = "account deactivated"
+
+// HandlerDeactivateUser blocks a user from logging in and ends their live sessions.
+// Their data is kept, so the account can be reactivated later.
+func HandlerDeactivateUser(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, ok := userParam(c, conf.Db)
+		if !ok {
+			return
+		}
+		principal, _ := auth.CurrentPrincipal(c)
+		if principal != nil && principal.UserID == u.UserID {
+			c.JSON(http.StatusConflict, gin.H{"error": "You cannot deactivate your own account"})
+			return
+		}
+
+		if err := RepoSetActive(conf.Db, u.UserID, false); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate user: " + err.Error()})
+			return
+		}
+		if err := session.RevokeAll(conf.Db, u.UserID, ReasonDeactivated); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "User deactivated, but failed to end sessions: " + err.Error()})
+			return
+		}
+
+		recordStatusChange(c, conf.Db, u, audit.EventDeactivated)
+		c.JSON(http.StatusOK, gin.H{"message": "User deactivated successfully"})
+	}
+}
+
+// HandlerReactivateUser lets a deactivated user log in again.
+func HandlerReactivateUser(conf *configuration.Dependencies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, ok := userParam(c, conf.Db)
+		if !ok {
+			return
+		}
+
+		if err := RepoSetActive(conf.Db, u.UserID, true); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate user: " + err.Error()})
+			return
+		}
+
+		recordStatusChange(c, conf.Db, u, audit.EventReactivated)
+		c.JSON(http.StatusOK, gin.H{"message": "User reactivated successfully"})
+	}
+}
+
+func RepoSetActive(db *gorm.DB, userId int64, active bool) error {
+	return db.Model(&User{}).Where("id = ?", userId).Update("is_active", active).Error
+}
+
+// userParam loads the user named by the :id route parameter, answering the request if there is none.
+func userParam(c *gin.Context, db *gorm.DB) (*User, bool) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false
+	}
+
+	var u User
+	if err := db.Where("id = ?", userID).First(&u).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return &u, true
+}
+
+func recordStatusChange(c *gin.Context, db *gorm.DB, u *User, eventType string) {
+	event := audit.AuthEvent{Type: eventType, Username: u.Username, UserID: &u.UserID, IP: c.ClientIP()}
+	if principal, ok := auth.CurrentPrincipal(c); ok {
+		event.ActorID = &principal.UserID
+	}
+	audit.Record(db, event)
+}